@@ -0,0 +1,643 @@
+// Package client is a Go client for the Tasqueue UI REST API.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// requestIDHeader carries the request ID in requests and responses
+const requestIDHeader = "X-Request-ID"
+
+// Options configures a Client
+type Options struct {
+	// HTTPClient sends requests; http.DefaultClient is used when nil
+	HTTPClient *http.Client
+
+	// Timeout bounds every request on top of the caller's context; zero disables it
+	Timeout time.Duration
+
+	// Token is sent as a bearer token, for servers behind an authenticating proxy
+	Token string
+
+	// Username and Password are sent with HTTP basic auth when Username is set
+	Username string
+	Password string
+
+	// Headers are added to every request
+	Headers http.Header
+
+	// UserAgent overrides the default User-Agent header
+	UserAgent string
+}
+
+// Client calls the Tasqueue UI API
+type Client struct {
+	baseURL string
+	opts    Options
+	hc      *http.Client
+}
+
+// New creates a client for the server at baseURL, e.g. http://localhost:8080
+func New(baseURL string, opts Options) (*Client, error) {
+	u, err := url.Parse(strings.TrimRight(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid base URL: scheme must be http or https")
+	}
+
+	hc := opts.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+
+	return &Client{
+		baseURL: u.String(),
+		opts:    opts,
+		hc:      hc,
+	}, nil
+}
+
+// HealthCheck calls GET /health
+func (c *Client) HealthCheck(ctx context.Context) error {
+	return c.do(ctx, http.MethodGet, "/health", nil, nil)
+}
+
+// ReadyCheck calls GET /ready. When a backend is down the report is
+// returned along with an *APIError matching ErrServer.
+func (c *Client) ReadyCheck(ctx context.Context) (ReadinessReport, error) {
+	var out ReadinessReport
+	err := c.do(ctx, http.MethodGet, "/ready", nil, &out)
+	return out, err
+}
+
+// GetOpenAPI calls GET /api/openapi.json and returns the raw document
+func (c *Client) GetOpenAPI(ctx context.Context) (json.RawMessage, error) {
+	var out json.RawMessage
+	err := c.do(ctx, http.MethodGet, "/api/openapi.json", nil, &out)
+	return out, err
+}
+
+// GetDashboardStats calls GET /api/stats
+func (c *Client) GetDashboardStats(ctx context.Context) (DashboardStats, error) {
+	var out DashboardStats
+	err := c.do(ctx, http.MethodGet, "/api/stats", nil, &out)
+	return out, err
+}
+
+// Search calls GET /api/search, looking id up as a job, chain or group
+func (c *Client) Search(ctx context.Context, id string) (SearchResult, error) {
+	var out SearchResult
+	err := c.do(ctx, http.MethodGet, "/api/search", url.Values{"q": {id}}, &out)
+	return out, err
+}
+
+// GetJob calls GET /api/jobs/{id}
+func (c *Client) GetJob(ctx context.Context, id string) (JobDetail, error) {
+	var out JobDetail
+	err := c.do(ctx, http.MethodGet, "/api/jobs/"+url.PathEscape(id), nil, &out)
+	return out, err
+}
+
+// DeleteJob calls DELETE /api/jobs/{id}
+func (c *Client) DeleteJob(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/api/jobs/"+url.PathEscape(id), nil, nil)
+}
+
+// GetJobsByStatus calls GET /api/jobs, returning the IDs of jobs with the
+// given status (successful or failed), newest first
+func (c *Client) GetJobsByStatus(ctx context.Context, status string) ([]string, error) {
+	var out jobIDList
+	if err := c.do(ctx, http.MethodGet, "/api/jobs", url.Values{"status": {status}}, &out); err != nil {
+		return nil, err
+	}
+	return out.JobIDs, nil
+}
+
+// ListJobs calls GET /api/jobs with details, returning the messages of jobs
+// with the given status that match the filter, newest first
+func (c *Client) ListJobs(ctx context.Context, status string, f JobFilter) ([]JobMessage, error) {
+	q := filterQuery(status, f)
+	q.Set("details", "true")
+
+	var out jobIDList
+	if err := c.do(ctx, http.MethodGet, "/api/jobs", q, &out); err != nil {
+		return nil, err
+	}
+	return out.Jobs, nil
+}
+
+// PurgeJobs calls DELETE /api/jobs, deleting every job with the given
+// status that matches the filter. It returns the number of jobs deleted.
+func (c *Client) PurgeJobs(ctx context.Context, status string, f JobFilter) (int, error) {
+	var out struct {
+		Deleted int `json:"deleted"`
+	}
+	if err := c.do(ctx, http.MethodDelete, "/api/jobs", filterQuery(status, f), &out); err != nil {
+		return 0, err
+	}
+	return out.Deleted, nil
+}
+
+// RetryJob calls POST /api/jobs/{id}/retry and returns the ID of the new job.
+// Retrying a job that hasn't failed returns an *APIError with status 409.
+func (c *Client) RetryJob(ctx context.Context, id string) (string, error) {
+	var out struct {
+		NewID string `json:"new_id"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/jobs/"+url.PathEscape(id)+"/retry", nil, &out); err != nil {
+		return "", err
+	}
+	return out.NewID, nil
+}
+
+// CancelJob calls POST /api/jobs/{id}/cancel, removing a pending job from
+// its queue. Cancelling a job that isn't pending, or that a worker has
+// already picked up, returns an *APIError with status 409.
+func (c *Client) CancelJob(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodPost, "/api/jobs/"+url.PathEscape(id)+"/cancel", nil, nil)
+}
+
+// ExportJobs calls GET /api/jobs/export and copies the exported jobs to w
+// as they arrive, returning the number of bytes written. The Timeout
+// option bounds the whole export, so large exports may need a longer one.
+// An export the server aborts part way through returns an error.
+func (c *Client) ExportJobs(ctx context.Context, opts ExportOptions, w io.Writer) (int64, error) {
+	if c.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.opts.Timeout)
+		defer cancel()
+	}
+
+	q := filterQuery(opts.Status, opts.JobFilter)
+	if opts.Format != "" {
+		q.Set("format", opts.Format)
+	}
+	if len(opts.Columns) > 0 {
+		q.Set("columns", strings.Join(opts.Columns, ","))
+	}
+	if !opts.Since.IsZero() {
+		q.Set("since", opts.Since.Format(time.RFC3339))
+	}
+	if !opts.Until.IsZero() {
+		q.Set("until", opts.Until.Format(time.RFC3339))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/api/jobs/export?"+q.Encode(), nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	c.setHeaders(req)
+	req.Header.Set("Accept", "application/x-ndjson, text/csv")
+
+	resp, err := c.hc.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to call GET /api/jobs/export: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read response: %w", err)
+		}
+		return 0, newAPIError(resp, body, nil)
+	}
+
+	n, err := io.Copy(w, resp.Body)
+	if err != nil {
+		return n, fmt.Errorf("failed to read export: %w", err)
+	}
+	return n, nil
+}
+
+// ImportJobs calls POST /api/jobs/import with the job messages in r, one
+// per line, and calls fn with the outcome of each line as the server reports
+// it. It returns the counts of each outcome. The Timeout option bounds the
+// whole import, including any rate limiting.
+func (c *Client) ImportJobs(ctx context.Context, r io.Reader, opts ImportOptions, fn func(ImportResult) error) (ImportSummary, error) {
+	summary := ImportSummary{DryRun: opts.DryRun}
+
+	if c.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.opts.Timeout)
+		defer cancel()
+	}
+
+	q := url.Values{}
+	q["queue"] = rewriteQuery(opts.Queues)
+	q["task"] = rewriteQuery(opts.Tasks)
+	if opts.Rate > 0 {
+		q.Set("rate", strconv.FormatFloat(opts.Rate, 'f', -1, 64))
+	}
+	if opts.DryRun {
+		q.Set("dry_run", "true")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/api/jobs/import?"+q.Encode(), r)
+	if err != nil {
+		return summary, fmt.Errorf("failed to create request: %w", err)
+	}
+	c.setHeaders(req)
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	req.Header.Set("Accept", "application/x-ndjson")
+
+	resp, err := c.hc.Do(req)
+	if err != nil {
+		return summary, fmt.Errorf("failed to call POST /api/jobs/import: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return summary, fmt.Errorf("failed to read response: %w", err)
+		}
+		return summary, newAPIError(resp, body, nil)
+	}
+
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var res ImportResult
+		err := dec.Decode(&res)
+		if errors.Is(err, io.EOF) {
+			return summary, nil
+		}
+		if err != nil {
+			return summary, fmt.Errorf("failed to read import report: %w", err)
+		}
+
+		summary.Add(res)
+		if err := fn(res); err != nil {
+			return summary, err
+		}
+	}
+}
+
+// rewriteQuery encodes rewrite rules as query values
+func rewriteQuery(rw Rewrite) []string {
+	var rules []string
+	for from, to := range rw {
+		if from == "*" {
+			rules = append(rules, to)
+		} else {
+			rules = append(rules, from+"="+to)
+		}
+	}
+	return rules
+}
+
+// GetPendingJobs calls GET /api/jobs/pending/{queue}
+// Deprecated: Use GetPendingJobsPaginated, which doesn't load the whole queue
+func (c *Client) GetPendingJobs(ctx context.Context, queue string) ([]JobMessage, error) {
+	var out []JobMessage
+	err := c.do(ctx, http.MethodGet, "/api/jobs/pending/"+url.PathEscape(queue), nil, &out)
+	return out, err
+}
+
+// GetPendingJobsPaginated calls GET /api/jobs/pending/{queue}/paginated
+func (c *Client) GetPendingJobsPaginated(ctx context.Context, queue string, offset, limit int) (PendingJobsResult, error) {
+	var out PendingJobsResult
+	q := url.Values{
+		"offset": {strconv.Itoa(offset)},
+		"limit":  {strconv.Itoa(limit)},
+	}
+	err := c.do(ctx, http.MethodGet, "/api/jobs/pending/"+url.PathEscape(queue)+"/paginated", q, &out)
+	return out, err
+}
+
+// GetPendingCount calls GET /api/jobs/pending/{queue}/count
+func (c *Client) GetPendingCount(ctx context.Context, queue string) (int64, error) {
+	var out pendingCount
+	if err := c.do(ctx, http.MethodGet, "/api/jobs/pending/"+url.PathEscape(queue)+"/count", nil, &out); err != nil {
+		return 0, err
+	}
+	return out.Count, nil
+}
+
+// ListQueues calls GET /api/queues
+func (c *Client) ListQueues(ctx context.Context) ([]string, error) {
+	var out struct {
+		Queues []string `json:"queues"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/queues", nil, &out); err != nil {
+		return nil, err
+	}
+	return out.Queues, nil
+}
+
+// ListTasks calls GET /api/tasks
+func (c *Client) ListTasks(ctx context.Context) (TaskStatsReport, error) {
+	var out TaskStatsReport
+	err := c.do(ctx, http.MethodGet, "/api/tasks", nil, &out)
+	return out, err
+}
+
+// GetTask calls GET /api/tasks/{name}
+func (c *Client) GetTask(ctx context.Context, name string) (TaskStats, error) {
+	var out TaskStats
+	err := c.do(ctx, http.MethodGet, "/api/tasks/"+url.PathEscape(name), nil, &out)
+	return out, err
+}
+
+// GetGraph calls GET /api/graph/{id}. kind is job, chain or group, or
+// empty to look for each in turn.
+func (c *Client) GetGraph(ctx context.Context, id, kind string) (Graph, error) {
+	q := url.Values{}
+	if kind != "" {
+		q.Set("kind", kind)
+	}
+	var out Graph
+	err := c.do(ctx, http.MethodGet, "/api/graph/"+url.PathEscape(id), q, &out)
+	return out, err
+}
+
+// ListCatalog calls GET /api/catalog
+func (c *Client) ListCatalog(ctx context.Context) ([]CatalogTask, error) {
+	var out struct {
+		Tasks []CatalogTask `json:"tasks"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/catalog", nil, &out); err != nil {
+		return nil, err
+	}
+	return out.Tasks, nil
+}
+
+// GetCatalogTask calls GET /api/catalog/{name}
+func (c *Client) GetCatalogTask(ctx context.Context, name string) (CatalogTask, error) {
+	var out CatalogTask
+	err := c.do(ctx, http.MethodGet, "/api/catalog/"+url.PathEscape(name), nil, &out)
+	return out, err
+}
+
+// ListWorkers calls GET /api/workers
+func (c *Client) ListWorkers(ctx context.Context) ([]Worker, error) {
+	var out struct {
+		Workers []Worker `json:"workers"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/workers", nil, &out); err != nil {
+		return nil, err
+	}
+	return out.Workers, nil
+}
+
+// GetAlerts calls GET /api/alerts
+func (c *Client) GetAlerts(ctx context.Context) (AlertsStatus, error) {
+	var out AlertsStatus
+	err := c.do(ctx, http.MethodGet, "/api/alerts", nil, &out)
+	return out, err
+}
+
+// GetRetention calls GET /api/retention
+func (c *Client) GetRetention(ctx context.Context) (RetentionStatus, error) {
+	var out RetentionStatus
+	err := c.do(ctx, http.MethodGet, "/api/retention", nil, &out)
+	return out, err
+}
+
+// ListArchive calls GET /api/archive, returning the archived days, newest
+// first
+func (c *Client) ListArchive(ctx context.Context) ([]ArchiveDay, error) {
+	var out struct {
+		Days []ArchiveDay `json:"days"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/archive", nil, &out); err != nil {
+		return nil, err
+	}
+	return out.Days, nil
+}
+
+// GetArchivedJob calls GET /api/archive/{day}/jobs/{id}
+func (c *Client) GetArchivedJob(ctx context.Context, day, id string) (ArchivedJob, error) {
+	var out ArchivedJob
+	err := c.do(ctx, http.MethodGet, "/api/archive/"+url.PathEscape(day)+"/jobs/"+url.PathEscape(id), nil, &out)
+	return out, err
+}
+
+// ListSilences calls GET /api/silences
+func (c *Client) ListSilences(ctx context.Context) ([]Silence, error) {
+	var out struct {
+		Silences []Silence `json:"silences"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/silences", nil, &out); err != nil {
+		return nil, err
+	}
+	return out.Silences, nil
+}
+
+// CreateSilence calls POST /api/silences and returns the stored silence.
+// The server sets the ID, creation time and status.
+func (c *Client) CreateSilence(ctx context.Context, sl Silence) (Silence, error) {
+	var out Silence
+	err := c.send(ctx, http.MethodPost, "/api/silences", nil, sl, &out)
+	return out, err
+}
+
+// GetSilence calls GET /api/silences/{id}
+func (c *Client) GetSilence(ctx context.Context, id string) (Silence, error) {
+	var out Silence
+	err := c.do(ctx, http.MethodGet, "/api/silences/"+url.PathEscape(id), nil, &out)
+	return out, err
+}
+
+// ExpireSilence calls DELETE /api/silences/{id} and returns the expired silence
+func (c *Client) ExpireSilence(ctx context.Context, id string) (Silence, error) {
+	var out Silence
+	err := c.do(ctx, http.MethodDelete, "/api/silences/"+url.PathEscape(id), nil, &out)
+	return out, err
+}
+
+// GetChain calls GET /api/chains/{id}
+func (c *Client) GetChain(ctx context.Context, id string) (ChainDetail, error) {
+	var out ChainDetail
+	err := c.do(ctx, http.MethodGet, "/api/chains/"+url.PathEscape(id), nil, &out)
+	return out, err
+}
+
+// ResumeChain calls POST /api/chains/{id}/resume. It enqueues a copy of the
+// chain's failed job, or with skip the step after it. Resuming a chain
+// that hasn't failed returns an *APIError with status 409.
+func (c *Client) ResumeChain(ctx context.Context, id string, skip bool) (ChainResume, error) {
+	var out ChainResume
+	err := c.do(ctx, http.MethodPost, "/api/chains/"+url.PathEscape(id)+"/resume",
+		url.Values{"skip": {strconv.FormatBool(skip)}}, &out)
+	return out, err
+}
+
+// ListChains calls GET /api/chains
+func (c *Client) ListChains(ctx context.Context) ([]string, error) {
+	var out struct {
+		Chains []string `json:"chains"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/chains", nil, &out); err != nil {
+		return nil, err
+	}
+	return out.Chains, nil
+}
+
+// GetGroup calls GET /api/groups/{id}
+func (c *Client) GetGroup(ctx context.Context, id string) (GroupDetail, error) {
+	var out GroupDetail
+	err := c.do(ctx, http.MethodGet, "/api/groups/"+url.PathEscape(id), nil, &out)
+	return out, err
+}
+
+// RerunGroup calls POST /api/groups/{id}/rerun. It enqueues copies of the
+// group's failed jobs as a new group, with maxRetries, when set, as their
+// retry limit. A group without failed jobs to re-run returns an *APIError
+// with status 409.
+func (c *Client) RerunGroup(ctx context.Context, id string, maxRetries *uint32) (GroupRerun, error) {
+	var q url.Values
+	if maxRetries != nil {
+		q = url.Values{"max_retries": {strconv.FormatUint(uint64(*maxRetries), 10)}}
+	}
+	var out GroupRerun
+	err := c.do(ctx, http.MethodPost, "/api/groups/"+url.PathEscape(id)+"/rerun", q, &out)
+	return out, err
+}
+
+// ListGroups calls GET /api/groups
+func (c *Client) ListGroups(ctx context.Context) ([]string, error) {
+	var out struct {
+		Groups []string `json:"groups"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/groups", nil, &out); err != nil {
+		return nil, err
+	}
+	return out.Groups, nil
+}
+
+// do sends a request to the escaped path and decodes the JSON response into
+// out, if non-nil. Non-2xx responses are returned as *APIError; the body is
+// still decoded into out when it isn't an ErrorResponse, as with /ready.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, out any) error {
+	return c.send(ctx, method, path, query, nil, out)
+}
+
+// send is do with in, if non-nil, encoded as the JSON request body
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, in, out any) error {
+	var reqBody io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reqBody = bytes.NewReader(b)
+	}
+
+	if c.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.opts.Timeout)
+		defer cancel()
+	}
+
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	c.setHeaders(req)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.hc.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call %s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return newAPIError(resp, body, out)
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return nil
+}
+
+// newAPIError builds the error for a non-2xx response. When the body isn't
+// an ErrorResponse it's decoded into out, if set, as some endpoints return
+// their usual body with an error status.
+func newAPIError(resp *http.Response, body []byte, out any) error {
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		Message:    http.StatusText(resp.StatusCode),
+		RequestID:  resp.Header.Get(requestIDHeader),
+	}
+
+	var errResp ErrorResponse
+	if json.Unmarshal(body, &errResp) == nil && errResp.Error != "" {
+		apiErr.Message = errResp.Error
+		if errResp.RequestID != "" {
+			apiErr.RequestID = errResp.RequestID
+		}
+	} else if out != nil {
+		json.Unmarshal(body, out)
+	}
+
+	return apiErr
+}
+
+// filterQuery encodes a status and job filter as query parameters
+func filterQuery(status string, f JobFilter) url.Values {
+	q := url.Values{"status": {status}}
+	if f.Task != "" {
+		q.Set("task", f.Task)
+	}
+	if f.Queue != "" {
+		q.Set("queue", f.Queue)
+	}
+	if f.Limit > 0 {
+		q.Set("limit", strconv.Itoa(f.Limit))
+	}
+
+	return q
+}
+
+// setHeaders adds the auth and custom headers to req
+func (c *Client) setHeaders(req *http.Request) {
+	req.Header.Set("Accept", "application/json")
+
+	for k, vs := range c.opts.Headers {
+		for _, v := range vs {
+			req.Header.Add(k, v)
+		}
+	}
+
+	if c.opts.UserAgent != "" {
+		req.Header.Set("User-Agent", c.opts.UserAgent)
+	}
+
+	switch {
+	case c.opts.Token != "":
+		req.Header.Set("Authorization", "Bearer "+c.opts.Token)
+	case c.opts.Username != "":
+		req.SetBasicAuth(c.opts.Username, c.opts.Password)
+	}
+}
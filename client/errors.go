@@ -0,0 +1,52 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Errors matched by an *APIError with errors.Is, by response status
+var (
+	// ErrBadRequest matches 400 responses
+	ErrBadRequest = errors.New("bad request")
+
+	// ErrNotFound matches 404 responses
+	ErrNotFound = errors.New("not found")
+
+	// ErrUnauthorized matches 401 and 403 responses
+	ErrUnauthorized = errors.New("unauthorized")
+
+	// ErrServer matches 5xx responses
+	ErrServer = errors.New("server error")
+)
+
+// APIError is returned for every non-2xx response
+type APIError struct {
+	StatusCode int
+	Message    string // Error message sent by the server, or the status text
+	RequestID  string // Server-assigned request ID, useful for finding logs
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("tasqueue-ui: %d %s", e.StatusCode, e.Message)
+	if e.RequestID != "" {
+		msg += " (request " + e.RequestID + ")"
+	}
+	return msg
+}
+
+// Is reports whether the error's status matches one of the sentinel errors
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	case ErrServer:
+		return e.StatusCode >= 500
+	}
+	return false
+}
@@ -0,0 +1,582 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Response types returned by the API. They mirror the JSON the server
+// encodes, field for field, so the client doesn't depend on the server's
+// packages; types_test.go checks that the two stay in sync.
+
+// DashboardStats holds overview statistics
+type DashboardStats struct {
+	TotalPending    int            `json:"total_pending"`
+	TotalSuccess    int            `json:"total_success"`
+	TotalFailed     int            `json:"total_failed"`
+	QueueStats      map[string]int `json:"queue_stats"`
+	RegisteredTasks []string       `json:"registered_tasks"`
+}
+
+// JobMessage is a job as stored in the broker and results store
+type JobMessage struct {
+	Meta
+	Job *Job
+}
+
+// Meta holds the state of a job, updated as it is processed
+type Meta struct {
+	ID            string
+	OnSuccessIDs  []string
+	Status        string
+	Queue         string
+	Schedule      string
+	MaxRetry      uint32
+	Retried       uint32
+	PrevErr       string
+	ProcessedAt   time.Time
+	PrevJobResult []byte // Result set by the previous job of a chain
+}
+
+// Job is a job's task, payload and options, along with the jobs enqueued
+// when it succeeds or fails
+type Job struct {
+	OnSuccess []*Job
+	Task      string
+	Payload   []byte
+	OnError   []*Job
+	Opts      JobOpts
+}
+
+// JobOpts holds the options a job was enqueued with
+type JobOpts struct {
+	ID         string
+	ETA        time.Time
+	Queue      string
+	MaxRetries uint32
+	Schedule   string
+	Timeout    time.Duration
+}
+
+// JobDetail is a job message along with its result data
+type JobDetail struct {
+	JobMessage
+	ResultData []byte `json:"result_data,omitempty"`
+}
+
+// ChainMessage is a chain as stored in the results store
+type ChainMessage struct {
+	ID       string
+	Status   string
+	JobID    string   // Current job of the chain
+	PrevJobs []string // Jobs of the chain that have finished
+}
+
+// ChainDetail is a chain message along with the jobs it has run and
+// every step, including those not yet enqueued
+type ChainDetail struct {
+	ChainMessage
+	Jobs  []JobMessage `json:"jobs"`
+	Steps []ChainStep  `json:"steps"`
+
+	// BlockedAt is the position of the step the chain is waiting on or
+	// failed at, or 0 once every step has succeeded
+	BlockedAt int `json:"blocked_at"`
+}
+
+// ChainStep is a job of a chain, whether or not it has been enqueued yet
+type ChainStep struct {
+	Position    int        `json:"position"`
+	JobID       string     `json:"job_id,omitempty"`
+	Task        string     `json:"task,omitempty"`
+	Queue       string     `json:"queue,omitempty"`
+	Status      string     `json:"status"`
+	Retried     uint32     `json:"retried,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	ResumedFrom []string   `json:"resumed_from,omitempty"`
+	ProcessedAt *time.Time `json:"processed_at,omitempty"`
+	Timing      *Timing    `json:"timing,omitempty"`
+	DurationMS  float64    `json:"duration_ms,omitempty"`
+	Result      []byte     `json:"result,omitempty"`
+}
+
+// Timing is when a job's handler started and finished
+type Timing struct {
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+}
+
+// ChainResume is the outcome of resuming a failed chain
+type ChainResume struct {
+	ChainID string    `json:"chain_id"`
+	Failed  string    `json:"failed"`
+	Skipped bool      `json:"skipped,omitempty"`
+	JobID   string    `json:"job_id,omitempty"`
+	At      time.Time `json:"at"`
+}
+
+// GroupMessage is a group as stored in the results store
+type GroupMessage struct {
+	ID        string
+	Status    string
+	JobStatus map[string]string // Status of each job by ID
+	Group     *Group
+}
+
+// Group is the jobs a group was enqueued with
+type Group struct {
+	Jobs []Job
+	Opts GroupOpts
+}
+
+// GroupOpts holds the options a group was enqueued with
+type GroupOpts struct {
+	ID string
+}
+
+// GroupDetail is a group message along with its member jobs, its
+// re-runs and their combined outcome
+type GroupDetail struct {
+	GroupMessage
+	Jobs     []JobMessage  `json:"jobs"`
+	RerunOf  string        `json:"rerun_of,omitempty"`
+	Reruns   []GroupRerun  `json:"reruns,omitempty"`
+	Combined *GroupOutcome `json:"combined,omitempty"`
+}
+
+// GroupRerun records the failed jobs of a group being re-run
+type GroupRerun struct {
+	Group      string            `json:"group"`
+	RerunGroup string            `json:"rerun_group"`
+	Jobs       map[string]string `json:"jobs"`
+	MaxRetries *uint32           `json:"max_retries,omitempty"`
+	At         time.Time         `json:"at"`
+}
+
+// GroupOutcome is the effective outcome of a group and its re-runs
+type GroupOutcome struct {
+	Status     string               `json:"status"`
+	Successful int                  `json:"successful"`
+	Failed     int                  `json:"failed"`
+	Pending    int                  `json:"pending"`
+	Jobs       []GroupMemberOutcome `json:"jobs"`
+}
+
+// GroupMemberOutcome is a job of a group across its re-runs
+type GroupMemberOutcome struct {
+	JobID    string   `json:"job_id"`
+	Attempts []string `json:"attempts"`
+	Status   string   `json:"status"`
+}
+
+// SearchResult holds the job, chain or group matching a search
+type SearchResult struct {
+	Job   *JobDetail   `json:"job,omitempty"`
+	Chain *ChainDetail `json:"chain,omitempty"`
+	Group *GroupDetail `json:"group,omitempty"`
+	Type  string       `json:"type"`
+}
+
+// PendingJobsResult holds a page of pending jobs
+type PendingJobsResult struct {
+	Jobs   []JobMessage `json:"jobs"`
+	Total  int64        `json:"total"`
+	Offset int          `json:"offset"`
+	Limit  int          `json:"limit"`
+}
+
+// JobFilter narrows the jobs listed or purged by status. Empty fields
+// match every job.
+type JobFilter struct {
+	Task  string `json:"task,omitempty"`
+	Queue string `json:"queue,omitempty"`
+	Limit int    `json:"limit,omitempty"` // Maximum number of jobs; zero means no limit
+}
+
+// ExportOptions selects the jobs exported and their format
+type ExportOptions struct {
+	JobFilter
+	Status  string    // successful, failed or pending
+	Since   time.Time // Bounds the time jobs were processed, [Since, Until)
+	Until   time.Time
+	Format  string   // ndjson or csv
+	Columns []string // Fields written, in order
+}
+
+// ImportOptions controls how imported jobs are rewritten and enqueued
+type ImportOptions struct {
+	Queues Rewrite // Rewrites the queue of each job
+	Tasks  Rewrite // Rewrites the task of each job
+	Rate   float64 // Maximum jobs enqueued per second; zero means no limit
+	DryRun bool    // Validate and rewrite every line without enqueueing
+}
+
+// Rewrite maps queue or task names to replacements. The "*" key, if
+// present, replaces every name without an exact match.
+type Rewrite map[string]string
+
+// Outcomes of an imported line
+const (
+	ImportEnqueued = "enqueued"
+	ImportValid    = "valid"
+	ImportFailed   = "failed"
+)
+
+// ImportResult reports the outcome of a single imported line
+type ImportResult struct {
+	Line   int    `json:"line"`
+	Status string `json:"status"`
+	ID     string `json:"id,omitempty"`
+	NewID  string `json:"new_id,omitempty"`
+	Task   string `json:"task,omitempty"`
+	Queue  string `json:"queue,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ImportSummary counts the outcomes of an import
+type ImportSummary struct {
+	Lines    int  `json:"lines"`
+	Enqueued int  `json:"enqueued"`
+	Valid    int  `json:"valid"`
+	Failed   int  `json:"failed"`
+	DryRun   bool `json:"dry_run"`
+}
+
+// Add counts a line's outcome
+func (s *ImportSummary) Add(res ImportResult) {
+	s.Lines++
+	switch res.Status {
+	case ImportEnqueued:
+		s.Enqueued++
+	case ImportValid:
+		s.Valid++
+	default:
+		s.Failed++
+	}
+}
+
+// TaskStatsReport holds the statistics of every task
+type TaskStatsReport struct {
+	Tasks     []TaskStats `json:"tasks"`
+	Jobs      int64       `json:"jobs"`
+	Complete  bool        `json:"complete"`
+	UpdatedAt *time.Time  `json:"updated_at"`
+}
+
+// TaskStats summarises the finished jobs of a task
+type TaskStats struct {
+	Name        string         `json:"name"`
+	Successful  int64          `json:"successful"`
+	Failed      int64          `json:"failed"`
+	Retried     int64          `json:"retried"`
+	Retries     int64          `json:"retries"`
+	RetryRate   float64        `json:"retry_rate"`
+	FailureRate float64        `json:"failure_rate"`
+	LastSuccess *time.Time     `json:"last_success,omitempty"`
+	LastFailure *TaskFailure   `json:"last_failure,omitempty"`
+	Duration    *DurationStats `json:"duration,omitempty"`
+}
+
+// TaskFailure is the most recent failure of a task
+type TaskFailure struct {
+	JobID string    `json:"job_id"`
+	Queue string    `json:"queue"`
+	Error string    `json:"error"`
+	At    time.Time `json:"at"`
+}
+
+// DurationStats summarises processing times in milliseconds
+type DurationStats struct {
+	Samples int64   `json:"samples"`
+	Avg     float64 `json:"avg_ms"`
+	Min     float64 `json:"min_ms"`
+	Max     float64 `json:"max_ms"`
+	P50     float64 `json:"p50_ms"`
+	P90     float64 `json:"p90_ms"`
+	P95     float64 `json:"p95_ms"`
+	P99     float64 `json:"p99_ms"`
+}
+
+// Graph is a job, chain or group and every job it leads to
+type Graph struct {
+	Root      string      `json:"root"`
+	Kind      string      `json:"kind"`
+	Nodes     []GraphNode `json:"nodes"`
+	Edges     []GraphEdge `json:"edges"`
+	Truncated bool        `json:"truncated,omitempty"`
+}
+
+// GraphNode is a job, chain or group
+type GraphNode struct {
+	ID     string `json:"id"`
+	Kind   string `json:"kind"`
+	Task   string `json:"task,omitempty"`
+	Queue  string `json:"queue,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// GraphEdge links two nodes
+type GraphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Kind string `json:"kind"`
+}
+
+// CatalogTask is a task in the task catalog
+type CatalogTask struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Owner       string          `json:"owner,omitempty"`
+	Queue       string          `json:"queue,omitempty"`
+	Schema      json.RawMessage `json:"schema,omitempty"` // JSON Schema of the task's payload
+	Concurrency uint32          `json:"concurrency,omitempty"`
+	Source      string          `json:"source"` // config, worker or registered
+}
+
+// Worker is a worker's last heartbeat and its state
+type Worker struct {
+	ID        string     `json:"id"`
+	Hostname  string     `json:"hostname"`
+	PID       int        `json:"pid"`
+	Tasks     []TaskInfo `json:"tasks"`
+	Queues    []string   `json:"queues"`
+	StartedAt time.Time  `json:"started_at"`
+	SeenAt    time.Time  `json:"seen_at"`
+	Uptime    int64      `json:"uptime_seconds"`
+	Stopped   bool       `json:"stopped,omitempty"`
+	Status    string     `json:"status"` // live, stopped or dead
+}
+
+// TaskInfo is a task registered by a worker
+type TaskInfo struct {
+	Name        string `json:"name"`
+	Queue       string `json:"queue"`
+	Concurrency uint32 `json:"concurrency"`
+}
+
+// AlertsStatus holds the alert rules, the alerts pending or firing and
+// the failure rules
+type AlertsStatus struct {
+	Enabled    bool                `json:"enabled"`
+	Evaluating bool                `json:"evaluating"`
+	Rules      []AlertRuleStatus   `json:"rules"`
+	Alerts     []Alert             `json:"alerts"`
+	Failures   []FailureRuleStatus `json:"failures"`
+}
+
+// AlertRule is an alert rule as configured
+type AlertRule struct {
+	Name      string   `json:"name"`
+	Type      string   `json:"type"`
+	Severity  string   `json:"severity,omitempty"`
+	Queue     string   `json:"queue,omitempty"`
+	Threshold float64  `json:"threshold,omitempty"`
+	Window    Duration `json:"window,omitempty"`
+	MaxAge    Duration `json:"max_age,omitempty"`
+	For       Duration `json:"for,omitempty"`
+	Webhooks  []string `json:"webhooks,omitempty"`
+}
+
+// AlertRuleStatus reports the last evaluation of a rule
+type AlertRuleStatus struct {
+	AlertRule
+	LastEvaluation time.Time `json:"last_evaluation"`
+	Error          string    `json:"error,omitempty"`
+}
+
+// Alert is an alert, as listed and as posted to webhooks
+type Alert struct {
+	Rule        string            `json:"rule"`
+	Type        string            `json:"type"`
+	Severity    string            `json:"severity,omitempty"`
+	Status      string            `json:"status"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Value       float64           `json:"value"`
+	Threshold   float64           `json:"threshold"`
+	Summary     string            `json:"summary"`
+	Fingerprint string            `json:"fingerprint"`
+	ActiveAt    time.Time         `json:"active_at"`
+	StartsAt    *time.Time        `json:"starts_at,omitempty"`
+	EndsAt      *time.Time        `json:"ends_at,omitempty"`
+	SilencedBy  []string          `json:"silenced_by,omitempty"`
+}
+
+// FailureRule is a failure notification rule as configured
+type FailureRule struct {
+	Name     string   `json:"name"`
+	Tasks    []string `json:"tasks,omitempty"`
+	Queues   []string `json:"queues,omitempty"`
+	Dedup    Duration `json:"dedup,omitempty"`
+	Webhooks []string `json:"webhooks,omitempty"`
+}
+
+// FailureRuleStatus reports the notifications sent for a failure rule
+type FailureRuleStatus struct {
+	FailureRule
+	Notified         int        `json:"notified"`
+	Suppressed       int        `json:"suppressed"`
+	Silenced         int        `json:"silenced"`
+	LastNotification *time.Time `json:"last_notification,omitempty"`
+}
+
+// FailureNotification is the body of failed job webhooks
+type FailureNotification struct {
+	Rule       string    `json:"rule"`
+	JobID      string    `json:"job_id"`
+	Task       string    `json:"task"`
+	Queue      string    `json:"queue"`
+	Error      string    `json:"error"`
+	Retried    uint32    `json:"retried"`
+	MaxRetry   uint32    `json:"max_retry"`
+	FailedAt   time.Time `json:"failed_at"`
+	URL        string    `json:"url,omitempty"`
+	Suppressed int       `json:"suppressed,omitempty"`
+	Summary    bool      `json:"summary,omitempty"`
+}
+
+// RetentionStatus holds the retention policies and the jobs deleted by them
+type RetentionStatus struct {
+	Enabled  bool              `json:"enabled"`
+	DryRun   bool              `json:"dry_run"`
+	Interval string            `json:"interval,omitempty"`
+	Policies []RetentionPolicy `json:"policies"`
+	Runs     int64             `json:"runs"`
+	Skipped  int64             `json:"skipped"`
+	Errors   int64             `json:"errors"`
+	LastRun  *RetentionRun     `json:"last_run,omitempty"`
+	Deleted  []RetentionCount  `json:"deleted"`
+}
+
+// RetentionPolicy sets how long finished jobs with a status and a matching
+// task are kept
+type RetentionPolicy struct {
+	Status string   `json:"status"`
+	Tasks  []string `json:"tasks,omitempty"`
+	MaxAge Duration `json:"max_age,omitempty"`
+}
+
+// RetentionRun is a single pass of the retention janitor
+type RetentionRun struct {
+	StartedAt  time.Time        `json:"started_at"`
+	FinishedAt time.Time        `json:"finished_at"`
+	Scanned    int              `json:"scanned"`
+	Deleted    int              `json:"deleted"`
+	Error      string           `json:"error,omitempty"`
+	Jobs       []RetentionCount `json:"jobs"`
+}
+
+// RetentionCount is the number of jobs with a status and task deleted
+type RetentionCount struct {
+	Status string `json:"status"`
+	Task   string `json:"task"`
+	Jobs   int64  `json:"jobs"`
+}
+
+// ArchiveDay lists the tasks whose jobs were archived on a day
+type ArchiveDay struct {
+	Day   string        `json:"day"`
+	Tasks []ArchiveTask `json:"tasks"`
+}
+
+// ArchiveTask is the archive of a task's jobs on a day
+type ArchiveTask struct {
+	Task  string `json:"task"`
+	Files int    `json:"files"`
+	Bytes int64  `json:"bytes"`
+}
+
+// ArchivedJob is a job found in the archive, with the file it is in
+type ArchivedJob struct {
+	Day  string    `json:"day"`
+	Task string    `json:"task"`
+	File string    `json:"file"`
+	Job  JobDetail `json:"job"`
+}
+
+// Silence mutes matching alerts and failure notifications, once or on a schedule
+type Silence struct {
+	ID        string    `json:"id"`
+	Rule      string    `json:"rule,omitempty"`
+	Queue     string    `json:"queue,omitempty"`
+	Task      string    `json:"task,omitempty"`
+	StartsAt  time.Time `json:"starts_at"`
+	EndsAt    time.Time `json:"ends_at"`
+	Schedule  string    `json:"schedule,omitempty"`
+	Duration  Duration  `json:"duration"`
+	Reason    string    `json:"reason"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+	Status    string    `json:"status"`
+
+	// CreatedBySource is "proxy" when CreatedBy was set by an
+	// authenticating proxy, and "request" when taken from the request
+	CreatedBySource string `json:"created_by_source,omitempty"`
+
+	// ExpiredBy is who expired the silence early, when the request came
+	// through the proxy, and ExpiredBySource is "proxy" or "request" as
+	// for CreatedBySource
+	ExpiredBy       string `json:"expired_by,omitempty"`
+	ExpiredBySource string `json:"expired_by_source,omitempty"`
+}
+
+// Duration is a time.Duration encoded in JSON as a string such as "2h"
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalJSON parses a duration string
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"30s\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+	return nil
+}
+
+// MarshalJSON formats the duration as a string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// ReadinessReport holds the status of each backend
+type ReadinessReport struct {
+	Ready        bool               `json:"ready"`
+	Dependencies []DependencyStatus `json:"dependencies"`
+	CheckedAt    time.Time          `json:"checked_at"`
+}
+
+// DependencyStatus reports the health of a single backend
+type DependencyStatus struct {
+	Name      string  `json:"name"`
+	Type      string  `json:"type"`
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// ErrorResponse is the body of every error response
+type ErrorResponse struct {
+	Error     string `json:"error"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// jobIDList is the response of GET /api/jobs
+type jobIDList struct {
+	Status string       `json:"status"`
+	JobIDs []string     `json:"job_ids"`
+	Count  int          `json:"count"`
+	Jobs   []JobMessage `json:"jobs"`
+}
+
+// pendingCount is the response of GET /api/jobs/pending/{queue}/count
+type pendingCount struct {
+	Queue string `json:"queue"`
+	Count int64  `json:"count"`
+}
@@ -0,0 +1,191 @@
+package client
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kalbhor/tasqueue-ui/internal/api"
+	"github.com/kalbhor/tasqueue-ui/internal/config"
+	"github.com/kalbhor/tasqueue-ui/internal/service"
+)
+
+// TestTypesMatchServer checks that every response type of the client
+// encodes to the same JSON fields, with the same types, as the type the
+// server encodes
+func TestTypesMatchServer(t *testing.T) {
+	pairs := []struct {
+		client, server any
+	}{
+		{DashboardStats{}, service.DashboardStats{}},
+		{JobDetail{}, service.JobDetail{}},
+		{ChainDetail{}, service.ChainDetail{}},
+		{ChainResume{}, service.ChainResume{}},
+		{GroupDetail{}, service.GroupDetail{}},
+		{GroupRerun{}, service.GroupRerun{}},
+		{SearchResult{}, service.SearchResult{}},
+		{PendingJobsResult{}, service.PendingJobsResult{}},
+		{JobFilter{}, service.JobFilter{}},
+		{ExportOptions{}, service.ExportOptions{}},
+		{ImportOptions{}, service.ImportOptions{}},
+		{ImportResult{}, service.ImportResult{}},
+		{ImportSummary{}, service.ImportSummary{}},
+		{TaskStatsReport{}, service.TaskStatsReport{}},
+		{Graph{}, service.Graph{}},
+		{CatalogTask{}, service.CatalogTask{}},
+		{Worker{}, service.Worker{}},
+		{AlertsStatus{}, service.AlertsStatus{}},
+		{FailureNotification{}, service.FailureNotification{}},
+		{RetentionStatus{}, service.RetentionStatus{}},
+		{ArchiveDay{}, service.ArchiveDay{}},
+		{ArchivedJob{}, service.ArchivedJob{}},
+		{Silence{}, service.Silence{}},
+		{Duration{}, config.Duration{}},
+		{ReadinessReport{}, service.ReadinessReport{}},
+		{ErrorResponse{}, api.ErrorResponse{}},
+	}
+
+	for _, p := range pairs {
+		ct, st := reflect.TypeOf(p.client), reflect.TypeOf(p.server)
+		t.Run(ct.Name(), func(t *testing.T) {
+			got, want := jsonShape(ct), jsonShape(st)
+			for _, path := range sortedKeys(want) {
+				if _, ok := got[path]; !ok {
+					t.Errorf("missing %s %s", path, want[path])
+				} else if got[path] != want[path] {
+					t.Errorf("%s is %s, server has %s", path, got[path], want[path])
+				}
+			}
+			for _, path := range sortedKeys(got) {
+				if _, ok := want[path]; !ok {
+					t.Errorf("%s %s is not sent by the server", path, got[path])
+				}
+			}
+		})
+	}
+}
+
+// TestWebhookConstants checks the webhook headers, events and signature
+// against those the server sends
+func TestWebhookConstants(t *testing.T) {
+	consts := []struct{ client, server string }{
+		{WebhookEventHeader, service.WebhookEventHeader},
+		{WebhookDeliveryHeader, service.WebhookDeliveryHeader},
+		{WebhookTimestampHeader, service.WebhookTimestampHeader},
+		{WebhookSignatureHeader, service.WebhookSignatureHeader},
+		{WebhookEventAlert, service.WebhookEventAlert},
+		{WebhookEventJobFailed, service.WebhookEventJobFailed},
+		{ImportEnqueued, service.ImportEnqueued},
+		{ImportValid, service.ImportValid},
+		{ImportFailed, service.ImportFailed},
+	}
+	for _, c := range consts {
+		if c.client != c.server {
+			t.Errorf("client has %q, server has %q", c.client, c.server)
+		}
+	}
+
+	body := []byte(`{"rule":"r"}`)
+	if got, want := signWebhook("secret", "1700000000", body), service.SignWebhook("secret", "1700000000", body); got != want {
+		t.Errorf("signature is %s, server signs %s", got, want)
+	}
+}
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	rawType       = reflect.TypeOf(json.RawMessage{})
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// jsonShape describes the JSON encoding of a type as the type of each
+// field, by path
+func jsonShape(t reflect.Type) map[string]string {
+	shape := make(map[string]string)
+	addShape(shape, "", t, map[reflect.Type]bool{})
+	return shape
+}
+
+// addShape adds the fields of t under path. seen holds the struct types
+// being walked, so recursive types such as a job's follow-ups end.
+func addShape(shape map[string]string, path string, t reflect.Type, seen map[reflect.Type]bool) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		shape[path] = "time"
+		return
+	case t == rawType:
+		shape[path] = "raw"
+		return
+	case t.Implements(marshalerType):
+		shape[path] = "custom " + t.Kind().String()
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if seen[t] {
+			shape[path] = "recursive"
+			return
+		}
+		seen[t] = true
+		defer delete(seen, t)
+		addFields(shape, path, t, seen)
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			shape[path] = "bytes"
+			return
+		}
+		addShape(shape, path+"[]", t.Elem(), seen)
+	case reflect.Map:
+		addShape(shape, path+"{}", t.Elem(), seen)
+	default:
+		shape[path] = t.Kind().String()
+	}
+}
+
+// addFields adds the encoded fields of a struct, flattening embedded
+// structs as encoding/json does
+func addFields(shape map[string]string, path string, t reflect.Type, seen map[reflect.Type]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" || (!f.IsExported() && !f.Anonymous) {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+		ft := f.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			addFields(shape, path, ft, seen)
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		field := path + "." + name
+		if strings.Contains(opts, "omitempty") {
+			shape[field+",omitempty"] = "option"
+		}
+		addShape(shape, field, f.Type, seen)
+	}
+}
+
+// sortedKeys returns the keys of a shape in order, for stable output
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,62 @@
+package client
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Headers set on webhook requests sent by the UI
+const (
+	WebhookEventHeader     = "X-Tasqueue-Event"
+	WebhookDeliveryHeader  = "X-Tasqueue-Delivery"
+	WebhookTimestampHeader = "X-Tasqueue-Timestamp"
+	WebhookSignatureHeader = "X-Tasqueue-Signature"
+)
+
+// Values of the event header
+const (
+	WebhookEventAlert     = "alert"
+	WebhookEventJobFailed = "job_failed"
+)
+
+// VerifyWebhook checks the signature of a webhook request sent by the UI
+// and returns its body. Requests whose timestamp is more than maxSkew from
+// now are rejected so captured requests can't be replayed later.
+func VerifyWebhook(r *http.Request, secret string, maxSkew time.Duration) ([]byte, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhook body: %w", err)
+	}
+
+	ts := r.Header.Get(WebhookTimestampHeader)
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook timestamp: %q", ts)
+	}
+	if skew := time.Since(time.Unix(sec, 0)); skew > maxSkew || skew < -maxSkew {
+		return nil, fmt.Errorf("webhook timestamp is %s away from now", skew.Round(time.Second))
+	}
+
+	want := signWebhook(secret, ts, body)
+	if !hmac.Equal([]byte(want), []byte(r.Header.Get(WebhookSignatureHeader))) {
+		return nil, fmt.Errorf("invalid webhook signature")
+	}
+
+	return body, nil
+}
+
+// signWebhook returns the signature the UI sends with a webhook body: the
+// hex HMAC-SHA256 of the timestamp, a dot and the body
+func signWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
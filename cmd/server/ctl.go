@@ -0,0 +1,796 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/kalbhor/tasqueue/v2"
+
+	"github.com/kalbhor/tasqueue-ui/client"
+	"github.com/kalbhor/tasqueue-ui/internal/config"
+	"github.com/kalbhor/tasqueue-ui/internal/service"
+)
+
+// ctlBackend is the set of operations ctl commands use. It is implemented
+// by *service.Service when talking to the broker directly, and by
+// remoteBackend when talking to a running UI.
+type ctlBackend interface {
+	GetDashboardStats(ctx context.Context) (service.DashboardStats, error)
+	GetJob(ctx context.Context, id string) (service.JobDetail, error)
+	GetPendingJobsWithPagination(ctx context.Context, queue string, offset, limit int) (service.PendingJobsResult, error)
+	ListJobs(ctx context.Context, status string, f service.JobFilter) ([]tasqueue.JobMessage, error)
+	RetryJob(ctx context.Context, id string) (string, error)
+	ResumeChain(ctx context.Context, id string, skip bool) (service.ChainResume, error)
+	RerunGroup(ctx context.Context, id string, maxRetries *uint32) (service.GroupRerun, error)
+	CancelJob(ctx context.Context, id string) error
+	DeleteJob(ctx context.Context, id string) error
+	PurgeJobs(ctx context.Context, status string, f service.JobFilter) (int, error)
+	Search(ctx context.Context, id string) (service.SearchResult, error)
+	ExportJobs(ctx context.Context, w io.Writer, opts service.ExportOptions) (int, error)
+	ImportJobs(ctx context.Context, r io.Reader, opts service.ImportOptions, fn func(service.ImportResult) error) (service.ImportSummary, error)
+}
+
+// remoteBackend adapts the API client to ctlBackend. The client's types
+// mirror the JSON of the service's, so responses are converted through it.
+type remoteBackend struct {
+	c *client.Client
+}
+
+// fromAPI converts a response of the API client to the service type it
+// mirrors
+func fromAPI[T any](v any, err error) (T, error) {
+	var out T
+	if err != nil {
+		return out, err
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return out, fmt.Errorf("failed to convert response: %w", err)
+	}
+	if err := json.Unmarshal(b, &out); err != nil {
+		return out, fmt.Errorf("failed to convert response: %w", err)
+	}
+	return out, nil
+}
+
+func (r remoteBackend) GetDashboardStats(ctx context.Context) (service.DashboardStats, error) {
+	return fromAPI[service.DashboardStats](r.c.GetDashboardStats(ctx))
+}
+
+func (r remoteBackend) GetJob(ctx context.Context, id string) (service.JobDetail, error) {
+	return fromAPI[service.JobDetail](r.c.GetJob(ctx, id))
+}
+
+func (r remoteBackend) GetPendingJobsWithPagination(ctx context.Context, queue string, offset, limit int) (service.PendingJobsResult, error) {
+	return fromAPI[service.PendingJobsResult](r.c.GetPendingJobsPaginated(ctx, queue, offset, limit))
+}
+
+func (r remoteBackend) ListJobs(ctx context.Context, status string, f service.JobFilter) ([]tasqueue.JobMessage, error) {
+	return fromAPI[[]tasqueue.JobMessage](r.c.ListJobs(ctx, status, client.JobFilter(f)))
+}
+
+func (r remoteBackend) RetryJob(ctx context.Context, id string) (string, error) {
+	return r.c.RetryJob(ctx, id)
+}
+
+func (r remoteBackend) ResumeChain(ctx context.Context, id string, skip bool) (service.ChainResume, error) {
+	res, err := r.c.ResumeChain(ctx, id, skip)
+	return service.ChainResume(res), err
+}
+
+func (r remoteBackend) RerunGroup(ctx context.Context, id string, maxRetries *uint32) (service.GroupRerun, error) {
+	res, err := r.c.RerunGroup(ctx, id, maxRetries)
+	return service.GroupRerun(res), err
+}
+
+func (r remoteBackend) CancelJob(ctx context.Context, id string) error {
+	return r.c.CancelJob(ctx, id)
+}
+
+func (r remoteBackend) DeleteJob(ctx context.Context, id string) error {
+	return r.c.DeleteJob(ctx, id)
+}
+
+func (r remoteBackend) PurgeJobs(ctx context.Context, status string, f service.JobFilter) (int, error) {
+	return r.c.PurgeJobs(ctx, status, client.JobFilter(f))
+}
+
+func (r remoteBackend) Search(ctx context.Context, id string) (service.SearchResult, error) {
+	return fromAPI[service.SearchResult](r.c.Search(ctx, id))
+}
+
+// ExportJobs streams an export to w. The API doesn't report the number of
+// jobs exported, so the count is always zero; ctl doesn't use it.
+func (r remoteBackend) ExportJobs(ctx context.Context, w io.Writer, opts service.ExportOptions) (int, error) {
+	_, err := r.c.ExportJobs(ctx, client.ExportOptions{
+		JobFilter: client.JobFilter(opts.JobFilter),
+		Status:    opts.Status,
+		Since:     opts.Since,
+		Until:     opts.Until,
+		Format:    opts.Format,
+		Columns:   opts.Columns,
+	}, w)
+	return 0, err
+}
+
+func (r remoteBackend) ImportJobs(ctx context.Context, rd io.Reader, opts service.ImportOptions, fn func(service.ImportResult) error) (service.ImportSummary, error) {
+	summary, err := r.c.ImportJobs(ctx, rd, client.ImportOptions{
+		Queues: client.Rewrite(opts.Queues),
+		Tasks:  client.Rewrite(opts.Tasks),
+		Rate:   opts.Rate,
+		DryRun: opts.DryRun,
+	}, func(res client.ImportResult) error {
+		return fn(service.ImportResult(res))
+	})
+	return service.ImportSummary(summary), err
+}
+
+// ctlCommand is a ctl subcommand
+type ctlCommand struct {
+	name    string
+	args    string // Positional arguments, for the usage text
+	summary string
+
+	// setup registers the command's own flags and returns its action
+	setup func(fs *flag.FlagSet) ctlAction
+}
+
+// ctlAction runs a command against a backend with its positional arguments
+type ctlAction func(ctx context.Context, b ctlBackend, p *printer, args []string) error
+
+// errUsage is returned by actions called with invalid arguments
+var errUsage = errors.New("invalid usage")
+
+var ctlCommands = []ctlCommand{
+	{"stats", "", "Show job counts per status and queue", setupStats},
+	{"job", "<id>", "Show a job (alias: get job)", setupJob},
+	{"pending", "[queue]", "List pending jobs in a queue (alias: list pending)", setupPending},
+	{"failed", "", "List failed jobs (alias: list failed)", setupStatusList(tasqueue.StatusFailed)},
+	{"successful", "", "List successful jobs (alias: list successful)", setupStatusList(tasqueue.StatusDone)},
+	{"retry", "<id>...", "Retry failed jobs by ID, or every matching failed job with -all", setupRetry},
+	{"resume", "<chain-id>", "Resume a failed chain from its failed step, or the next one with -skip", setupResume},
+	{"rerun", "<group-id>", "Re-run the failed jobs of a group as a new group", setupRerun},
+	{"cancel", "<id>...", "Cancel pending jobs before a worker picks them up", setupCancel},
+	{"delete", "<id>...", "Delete jobs", setupDelete},
+	{"purge", "", "Delete every job with a status that matches the filters", setupPurge},
+	{"search", "<id>", "Find a job, chain or group by ID", setupSearch},
+	{"export", "", "Export jobs with their payloads and results as NDJSON or CSV", setupExport},
+	{"import", "[file]", "Enqueue copies of the jobs in an NDJSON export, read from stdin without a file", setupImport},
+}
+
+// ctlAliases maps two-word command forms to their commands
+var ctlAliases = map[string]string{
+	"get job":         "job",
+	"list pending":    "pending",
+	"list failed":     "failed",
+	"list successful": "successful",
+}
+
+// ctlStreaming lists the commands that stream for as long as the data
+// lasts, such as a large export or a rate-limited import. They run without a timeout unless
+// -timeout is set.
+var ctlStreaming = map[string]bool{
+	"export": true,
+	"import": true,
+}
+
+// ctlTimeout is the default timeout of commands that don't stream
+const ctlTimeout = 30 * time.Second
+
+// runCtl runs a ctl subcommand and returns the process exit code
+func runCtl(args []string) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "-help" || args[0] == "--help" || args[0] == "help" {
+		ctlUsage(os.Stderr)
+		return 2
+	}
+
+	name := args[0]
+	args = args[1:]
+	if len(args) > 0 {
+		if alias, ok := ctlAliases[name+" "+args[0]]; ok {
+			name = alias
+			args = args[1:]
+		}
+	}
+
+	var cmd *ctlCommand
+	for i := range ctlCommands {
+		if ctlCommands[i].name == name {
+			cmd = &ctlCommands[i]
+		}
+	}
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n\n", name)
+		ctlUsage(os.Stderr)
+		return 2
+	}
+
+	fs := flag.NewFlagSet("ctl "+cmd.name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: tasqueue-ui ctl %s [flags] %s\n\n%s\n\nFlags:\n", cmd.name, cmd.args, cmd.summary)
+		fs.PrintDefaults()
+	}
+
+	defaultTimeout := ctlTimeout
+	if ctlStreaming[cmd.name] {
+		defaultTimeout = 0
+	}
+
+	var (
+		server  = fs.String("server", os.Getenv("TASQUEUE_UI_URL"), "URL of a running UI to call instead of connecting to the broker (env TASQUEUE_UI_URL)")
+		token   = fs.String("token", os.Getenv("TASQUEUE_UI_TOKEN"), "Bearer token sent to -server (env TASQUEUE_UI_TOKEN)")
+		timeout = fs.Duration("timeout", defaultTimeout, "Timeout of the whole command; 0 for none")
+		format  = fs.String("o", "table", "Output format (table, json, ndjson)")
+		backend = bindBackendFlags(fs)
+		action  = cmd.setup(fs)
+	)
+
+	pos, err := parseInterspersed(fs, args)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		return 2
+	}
+
+	p, err := newPrinter(os.Stdout, *format)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	// Keep service logs out of the command's output
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn})))
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	var b ctlBackend
+	if *server != "" {
+		c, err := client.New(*server, client.Options{Token: *token})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		b = remoteBackend{c: c}
+	} else {
+		cfg := config.DefaultConfig()
+		backend.apply(fs, &cfg)
+
+		svc, err := service.NewService(cfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to initialize service: %v\n", err)
+			return 1
+		}
+		b = svc
+	}
+
+	if err := action(ctx, b, p, pos); err != nil {
+		if errors.Is(err, errUsage) {
+			fs.Usage()
+			return 2
+		}
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return 1
+	}
+
+	return 0
+}
+
+// ctlUsage lists the ctl subcommands
+func ctlUsage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: tasqueue-ui ctl <command> [flags] [args]\n\nCommands:\n")
+	for _, cmd := range ctlCommands {
+		fmt.Fprintf(w, "  %-28s %s\n", strings.TrimSpace(cmd.name+" "+cmd.args), cmd.summary)
+	}
+	fmt.Fprintf(w, "\nCommands connect to the broker with the same flags as the server, or call\n"+
+		"a running UI when -server is set. Run 'tasqueue-ui ctl <command> -h' for flags.\n")
+}
+
+// parseInterspersed parses fs allowing flags after positional arguments,
+// as in "ctl job <id> -o json", and returns the positional arguments
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var pos []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return pos, nil
+		}
+		pos = append(pos, args[0])
+		args = args[1:]
+	}
+}
+
+// bindFilterFlags registers the -task, -queue and -limit filter flags
+func bindFilterFlags(fs *flag.FlagSet, limit int) *service.JobFilter {
+	f := &service.JobFilter{}
+	fs.StringVar(&f.Task, "task", "", "Only jobs of this task")
+	fs.StringVar(&f.Queue, "queue", "", "Only jobs from this queue")
+	fs.IntVar(&f.Limit, "limit", limit, "Maximum number of jobs (0 for no limit)")
+	return f
+}
+
+func setupStats(fs *flag.FlagSet) ctlAction {
+	return func(ctx context.Context, b ctlBackend, p *printer, args []string) error {
+		if len(args) != 0 {
+			return errUsage
+		}
+
+		stats, err := b.GetDashboardStats(ctx)
+		if err != nil {
+			return err
+		}
+
+		return p.print(stats, func(t *table) {
+			t.row("Pending", stats.TotalPending)
+			t.row("Successful", stats.TotalSuccess)
+			t.row("Failed", stats.TotalFailed)
+			t.row("Tasks", strings.Join(stats.RegisteredTasks, ", "))
+			t.flush()
+
+			queues := make([]string, 0, len(stats.QueueStats))
+			for q := range stats.QueueStats {
+				queues = append(queues, q)
+			}
+			sort.Strings(queues)
+
+			t.blank()
+			t.row("QUEUE", "PENDING")
+			for _, q := range queues {
+				t.row(q, stats.QueueStats[q])
+			}
+		})
+	}
+}
+
+func setupJob(fs *flag.FlagSet) ctlAction {
+	return func(ctx context.Context, b ctlBackend, p *printer, args []string) error {
+		if len(args) != 1 {
+			return errUsage
+		}
+
+		job, err := b.GetJob(ctx, args[0])
+		if err != nil {
+			return err
+		}
+
+		return p.print(job, func(t *table) {
+			jobDetailRows(t, job)
+		})
+	}
+}
+
+func setupPending(fs *flag.FlagSet) ctlAction {
+	offset := fs.Int("offset", 0, "Index of the first job")
+	limit := fs.Int("limit", 50, "Maximum number of jobs")
+
+	return func(ctx context.Context, b ctlBackend, p *printer, args []string) error {
+		if len(args) > 1 {
+			return errUsage
+		}
+		queue := tasqueue.DefaultQueue
+		if len(args) == 1 {
+			queue = args[0]
+		}
+
+		res, err := b.GetPendingJobsWithPagination(ctx, queue, *offset, *limit)
+		if err != nil {
+			return err
+		}
+
+		if p.format == formatNDJSON {
+			return p.print(res.Jobs, nil)
+		}
+		return p.print(res, func(t *table) {
+			jobRows(t, res.Jobs)
+			t.flush()
+			fmt.Fprintf(t.w, "\nShowing %d-%d of %d pending jobs in %s\n",
+				min(int64(res.Offset+1), res.Total), int64(res.Offset)+int64(len(res.Jobs)), res.Total, queue)
+		})
+	}
+}
+
+func setupStatusList(status string) func(fs *flag.FlagSet) ctlAction {
+	return func(fs *flag.FlagSet) ctlAction {
+		filter := bindFilterFlags(fs, 100)
+
+		return func(ctx context.Context, b ctlBackend, p *printer, args []string) error {
+			if len(args) != 0 {
+				return errUsage
+			}
+
+			jobs, err := b.ListJobs(ctx, status, *filter)
+			if err != nil {
+				return err
+			}
+
+			return p.print(jobs, func(t *table) {
+				jobRows(t, jobs)
+			})
+		}
+	}
+}
+
+// opResult is the outcome of a retry or delete of a single job
+type opResult struct {
+	ID    string `json:"id"`
+	NewID string `json:"new_id,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+func setupRetry(fs *flag.FlagSet) ctlAction {
+	all := fs.Bool("all", false, "Retry every failed job matching -task, -queue and -limit")
+	filter := bindFilterFlags(fs, 0)
+
+	return func(ctx context.Context, b ctlBackend, p *printer, args []string) error {
+		ids := args
+		switch {
+		case *all && len(args) > 0, !*all && len(args) == 0:
+			return errUsage
+		case *all:
+			jobs, err := b.ListJobs(ctx, tasqueue.StatusFailed, *filter)
+			if err != nil {
+				return err
+			}
+			for _, job := range jobs {
+				ids = append(ids, job.ID)
+			}
+		}
+
+		results := make([]opResult, len(ids))
+		for i, id := range ids {
+			results[i].ID = id
+			newID, err := b.RetryJob(ctx, id)
+			if err != nil {
+				results[i].Error = err.Error()
+				continue
+			}
+			results[i].NewID = newID
+		}
+
+		return printOpResults(p, results, "NEW ID", func(r opResult) string { return r.NewID })
+	}
+}
+
+func setupResume(fs *flag.FlagSet) ctlAction {
+	skip := fs.Bool("skip", false, "Skip the failed step and continue with the next one")
+
+	return func(ctx context.Context, b ctlBackend, p *printer, args []string) error {
+		if len(args) != 1 {
+			return errUsage
+		}
+
+		res, err := b.ResumeChain(ctx, args[0], *skip)
+		if err != nil {
+			return err
+		}
+
+		return p.print(res, func(t *table) {
+			t.row("CHAIN", "FAILED JOB", "SKIPPED", "NEW JOB")
+			t.row(res.ChainID, res.Failed, fmt.Sprint(res.Skipped), res.JobID)
+		})
+	}
+}
+
+func setupRerun(fs *flag.FlagSet) ctlAction {
+	maxRetries := fs.Int("max-retries", -1, "Retry limit of the re-run jobs; by default that of the failed jobs")
+
+	return func(ctx context.Context, b ctlBackend, p *printer, args []string) error {
+		if len(args) != 1 {
+			return errUsage
+		}
+
+		var retries *uint32
+		if *maxRetries >= 0 {
+			n := uint32(*maxRetries)
+			retries = &n
+		}
+		res, err := b.RerunGroup(ctx, args[0], retries)
+		if err != nil {
+			return err
+		}
+
+		failed := make([]string, 0, len(res.Jobs))
+		for id := range res.Jobs {
+			failed = append(failed, id)
+		}
+		sort.Strings(failed)
+
+		return p.print(res, func(t *table) {
+			t.row("GROUP", "FAILED JOB", "NEW JOB")
+			for _, id := range failed {
+				t.row(res.RerunGroup, id, res.Jobs[id])
+			}
+		})
+	}
+}
+
+func setupCancel(fs *flag.FlagSet) ctlAction {
+	return func(ctx context.Context, b ctlBackend, p *printer, args []string) error {
+		if len(args) == 0 {
+			return errUsage
+		}
+
+		results := make([]opResult, len(args))
+		for i, id := range args {
+			results[i].ID = id
+			if err := b.CancelJob(ctx, id); err != nil {
+				results[i].Error = err.Error()
+			}
+		}
+
+		return printOpResults(p, results, "CANCELLED", func(r opResult) string {
+			if r.Error != "" {
+				return "no"
+			}
+			return "yes"
+		})
+	}
+}
+
+func setupDelete(fs *flag.FlagSet) ctlAction {
+	return func(ctx context.Context, b ctlBackend, p *printer, args []string) error {
+		if len(args) == 0 {
+			return errUsage
+		}
+
+		results := make([]opResult, len(args))
+		for i, id := range args {
+			results[i].ID = id
+			if err := b.DeleteJob(ctx, id); err != nil {
+				results[i].Error = err.Error()
+			}
+		}
+
+		return printOpResults(p, results, "DELETED", func(r opResult) string {
+			if r.Error != "" {
+				return "no"
+			}
+			return "yes"
+		})
+	}
+}
+
+// printOpResults prints per-job results, and fails if any job failed
+func printOpResults(p *printer, results []opResult, col string, value func(opResult) string) error {
+	failed := 0
+	for _, r := range results {
+		if r.Error != "" {
+			failed++
+		}
+	}
+
+	err := p.print(results, func(t *table) {
+		t.row("ID", col, "ERROR")
+		for _, r := range results {
+			t.row(r.ID, value(r), r.Error)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d jobs failed", failed, len(results))
+	}
+	return nil
+}
+
+func setupPurge(fs *flag.FlagSet) ctlAction {
+	status := fs.String("status", tasqueue.StatusFailed, "Status of the jobs to delete (failed, successful)")
+	yes := fs.Bool("yes", false, "Confirm the purge")
+	filter := bindFilterFlags(fs, 0)
+
+	return func(ctx context.Context, b ctlBackend, p *printer, args []string) error {
+		if len(args) != 0 {
+			return errUsage
+		}
+		if !*yes {
+			return fmt.Errorf("purge deletes jobs permanently; re-run with -yes to confirm")
+		}
+
+		deleted, err := b.PurgeJobs(ctx, *status, *filter)
+		if err != nil {
+			return err
+		}
+
+		out := struct {
+			Status  string `json:"status"`
+			Deleted int    `json:"deleted"`
+		}{*status, deleted}
+
+		return p.print(out, func(t *table) {
+			t.row("Status", out.Status)
+			t.row("Deleted", out.Deleted)
+		})
+	}
+}
+
+func setupSearch(fs *flag.FlagSet) ctlAction {
+	return func(ctx context.Context, b ctlBackend, p *printer, args []string) error {
+		if len(args) != 1 {
+			return errUsage
+		}
+
+		res, err := b.Search(ctx, args[0])
+		if err != nil {
+			return err
+		}
+
+		return p.print(res, func(t *table) {
+			t.row("Type", res.Type)
+			switch {
+			case res.Job != nil:
+				jobDetailRows(t, *res.Job)
+			case res.Chain != nil:
+				t.row("ID", res.Chain.ID)
+				t.row("Status", res.Chain.Status)
+				t.row("Current job", res.Chain.JobID)
+				t.flush()
+				t.blank()
+				jobRows(t, res.Chain.Jobs)
+			case res.Group != nil:
+				t.row("ID", res.Group.ID)
+				t.row("Status", res.Group.Status)
+				t.flush()
+				t.blank()
+				jobRows(t, res.Group.Jobs)
+			}
+		})
+	}
+}
+
+func setupExport(fs *flag.FlagSet) ctlAction {
+	status := fs.String("status", tasqueue.StatusFailed, "Status of the jobs to export (failed, successful, pending)")
+	format := fs.String("format", service.ExportNDJSON, "Export format (ndjson, csv)")
+	columns := fs.String("columns", "", "Comma separated columns to include ("+strings.Join(service.ExportColumns(), ", ")+")")
+	since := fs.String("since", "", "Only jobs processed at or after this RFC 3339 time, or this long ago such as 24h")
+	until := fs.String("until", "", "Only jobs processed before this RFC 3339 time, or this long ago")
+	out := fs.String("out", "", "File to write to instead of stdout")
+	filter := bindFilterFlags(fs, 0)
+
+	return func(ctx context.Context, b ctlBackend, p *printer, args []string) error {
+		if len(args) != 0 {
+			return errUsage
+		}
+
+		opts := service.ExportOptions{
+			JobFilter: *filter,
+			Status:    *status,
+			Format:    *format,
+			Columns:   splitList(*columns),
+		}
+		var err error
+		if opts.Since, err = parseCtlTime(*since); err != nil {
+			return fmt.Errorf("invalid -since: %w", err)
+		}
+		if opts.Until, err = parseCtlTime(*until); err != nil {
+			return fmt.Errorf("invalid -until: %w", err)
+		}
+		if err := opts.Validate(); err != nil {
+			return err
+		}
+
+		if *out == "" {
+			return exportTo(ctx, b, p.w, opts)
+		}
+
+		f, err := os.Create(*out)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		if err := exportTo(ctx, b, f, opts); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	}
+}
+
+// exportTo streams an export to w through a buffer, since the service
+// writes a line at a time
+func exportTo(ctx context.Context, b ctlBackend, w io.Writer, opts service.ExportOptions) error {
+	bw := bufio.NewWriter(w)
+	if _, err := b.ExportJobs(ctx, bw, opts); err != nil {
+		bw.Flush()
+		return err
+	}
+	return bw.Flush()
+}
+
+// parseCtlTime parses an RFC 3339 timestamp, or a duration such as 24h
+// meaning that long ago. An empty string is the zero time.
+func parseCtlTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+func setupImport(fs *flag.FlagSet) ctlAction {
+	queues := fs.String("queue", "", "Comma separated queue rewrites, as from=to or just to for every job")
+	tasks := fs.String("task", "", "Comma separated task rewrites, as from=to or just to for every job")
+	rate := fs.Float64("rate", 0, "Maximum jobs enqueued per second (0 for no limit)")
+	dryRun := fs.Bool("dry-run", false, "Validate and rewrite without enqueueing")
+
+	return func(ctx context.Context, b ctlBackend, p *printer, args []string) error {
+		if len(args) > 1 {
+			return errUsage
+		}
+
+		opts := service.ImportOptions{Rate: *rate, DryRun: *dryRun}
+		var err error
+		if opts.Queues, err = service.ParseRewrite(splitList(*queues)); err != nil {
+			return err
+		}
+		if opts.Tasks, err = service.ParseRewrite(splitList(*tasks)); err != nil {
+			return err
+		}
+
+		r := io.Reader(os.Stdin)
+		if len(args) == 1 && args[0] != "-" {
+			f, err := os.Open(args[0])
+			if err != nil {
+				return fmt.Errorf("failed to open input file: %w", err)
+			}
+			defer f.Close()
+			r = f
+		}
+
+		// NDJSON reports each line as it's imported; other formats need
+		// every result before they can be printed
+		var results []service.ImportResult
+		summary, err := b.ImportJobs(ctx, r, opts, func(res service.ImportResult) error {
+			if p.format == formatNDJSON {
+				return p.print(res, nil)
+			}
+			results = append(results, res)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if p.format != formatNDJSON {
+			err := p.print(results, func(t *table) {
+				t.row("LINE", "STATUS", "ID", "NEW ID", "TASK", "QUEUE", "ERROR")
+				for _, res := range results {
+					t.row(res.Line, res.Status, res.ID, res.NewID, res.Task, res.Queue, truncate(res.Error, maxErrorWidth))
+				}
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		fmt.Fprintf(os.Stderr, "%d lines: %d enqueued, %d valid, %d failed\n",
+			summary.Lines, summary.Enqueued, summary.Valid, summary.Failed)
+		if summary.Failed > 0 {
+			return fmt.Errorf("%d of %d lines failed", summary.Failed, summary.Lines)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/kalbhor/tasqueue/v2"
+
+	"github.com/kalbhor/tasqueue-ui/internal/service"
+)
+
+// Output formats of ctl commands
+const (
+	formatTable  = "table"
+	formatJSON   = "json"
+	formatNDJSON = "ndjson"
+)
+
+// maxErrorWidth truncates errors in job tables
+const maxErrorWidth = 60
+
+// printer writes command results in the selected format
+type printer struct {
+	w      io.Writer
+	format string
+}
+
+// newPrinter creates a printer for one of the output formats
+func newPrinter(w io.Writer, format string) (*printer, error) {
+	switch format {
+	case formatTable, formatJSON, formatNDJSON:
+		return &printer{w: w, format: format}, nil
+	default:
+		return nil, fmt.Errorf("invalid output format: %s (must be table, json or ndjson)", format)
+	}
+}
+
+// print writes v as indented JSON, or as NDJSON with one line per element
+// when v is a slice. In table format render draws v instead.
+func (p *printer) print(v any, render func(t *table)) error {
+	if p.format == formatTable && render != nil {
+		t := &table{w: p.w, tw: tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)}
+		render(t)
+		return t.flush()
+	}
+
+	if p.format == formatNDJSON {
+		enc := json.NewEncoder(p.w)
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Slice {
+			return enc.Encode(v)
+		}
+		for i := 0; i < rv.Len(); i++ {
+			if err := enc.Encode(rv.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	enc := json.NewEncoder(p.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// table aligns rows of cells into columns
+type table struct {
+	w  io.Writer
+	tw *tabwriter.Writer
+}
+
+// row writes one row of cells
+func (t *table) row(cells ...any) {
+	s := make([]string, len(cells))
+	for i, c := range cells {
+		s[i] = fmt.Sprint(c)
+	}
+	fmt.Fprintln(t.tw, strings.Join(s, "\t"))
+}
+
+// flush ends the current block of aligned rows
+func (t *table) flush() error {
+	return t.tw.Flush()
+}
+
+// blank writes an empty line between blocks; call flush first
+func (t *table) blank() {
+	fmt.Fprintln(t.w)
+}
+
+// jobRows writes a table of job messages
+func jobRows(t *table, jobs []tasqueue.JobMessage) {
+	t.row("ID", "TASK", "QUEUE", "STATUS", "RETRIED", "PROCESSED AT", "ERROR")
+	for _, job := range jobs {
+		t.row(job.ID, jobTask(job), job.Queue, job.Status,
+			fmt.Sprintf("%d/%d", job.Retried, job.MaxRetry),
+			formatTime(job.ProcessedAt), truncate(job.PrevErr, maxErrorWidth))
+	}
+}
+
+// jobDetailRows writes the fields of a single job
+func jobDetailRows(t *table, job service.JobDetail) {
+	t.row("ID", job.ID)
+	t.row("Task", jobTask(job.JobMessage))
+	t.row("Status", job.Status)
+	t.row("Queue", job.Queue)
+	t.row("Retried", fmt.Sprintf("%d/%d", job.Retried, job.MaxRetry))
+	t.row("Processed at", formatTime(job.ProcessedAt))
+	if job.Schedule != "" {
+		t.row("Schedule", job.Schedule)
+	}
+	if len(job.OnSuccessIDs) > 0 {
+		t.row("On success", strings.Join(job.OnSuccessIDs, ", "))
+	}
+	if job.PrevErr != "" {
+		t.row("Error", oneLine(job.PrevErr))
+	}
+	if job.Job != nil {
+		t.row("Payload", formatBytes(job.Job.Payload))
+	}
+	if len(job.ResultData) > 0 {
+		t.row("Result", formatBytes(job.ResultData))
+	}
+}
+
+// jobTask returns the task name of a job message
+func jobTask(job tasqueue.JobMessage) string {
+	if job.Job == nil {
+		return "-"
+	}
+	return job.Job.Task
+}
+
+// formatTime formats a timestamp, or "-" when unset
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format(time.RFC3339)
+}
+
+// formatBytes shows printable data as text and anything else as base64
+func formatBytes(b []byte) string {
+	if len(b) == 0 {
+		return "-"
+	}
+
+	s := string(b)
+	if utf8.ValidString(s) && strings.IndexFunc(s, func(r rune) bool {
+		return !unicode.IsPrint(r) && !unicode.IsSpace(r)
+	}) < 0 {
+		return oneLine(s)
+	}
+
+	return "base64:" + base64.StdEncoding.EncodeToString(b)
+}
+
+// oneLine collapses whitespace so a value fits in a table cell
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// truncate shortens s to at most n runes, on a single line
+func truncate(s string, n int) string {
+	s = oneLine(s)
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n-3]) + "..."
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/kalbhor/tasqueue-ui/internal/config"
+)
+
+// backendFlags holds the flags selecting and connecting to the broker and
+// results store
+type backendFlags struct {
+	brokerType   *string
+	brokerRedis  *redisFlags
+	natsURL      *string
+	natsUser     *string
+	natsPass     *string
+	queuePattern *string
+	resultsType  *string
+	resultsRedis *redisFlags
+}
+
+// bindBackendFlags registers the broker and results flags
+func bindBackendFlags(fs *flag.FlagSet) *backendFlags {
+	return &backendFlags{
+		brokerType:   fs.String("broker", "redis", "Broker type (redis, nats-js, in-memory)"),
+		brokerRedis:  bindRedisFlags(fs, "redis", "Broker"),
+		natsURL:      fs.String("nats-url", "nats://localhost:4222", "NATS server URL"),
+		natsUser:     fs.String("nats-user", "", "NATS username"),
+		natsPass:     fs.String("nats-pass", "", "NATS password"),
+		queuePattern: fs.String("queue-pattern", config.DefaultQueuePattern, "Pattern of the Redis broker lists listed as queues besides those tasks are registered on; empty for none"),
+		resultsType:  fs.String("results", "", "Results backend type (redis, in-memory); defaults to in-memory for the in-memory broker and redis otherwise"),
+		resultsRedis: bindRedisFlags(fs, "results-redis", "Results"),
+	}
+}
+
+// apply sets the broker and results configuration described by the flags.
+// fs must be the flag set the flags were bound to, after parsing.
+func (f *backendFlags) apply(fs *flag.FlagSet, cfg *config.Config) {
+	cfg.Broker.Type = *f.brokerType
+	cfg.Broker.Redis = f.brokerRedis.config()
+	cfg.Broker.NATS = config.NATSConfig{
+		URL:      *f.natsURL,
+		Username: *f.natsUser,
+		Password: *f.natsPass,
+	}
+	cfg.Broker.QueuePattern = *f.queuePattern
+
+	cfg.Results.Type = *f.resultsType
+	if cfg.Results.Type == "" {
+		cfg.Results.Type = "redis"
+		if cfg.Broker.Type == "in-memory" {
+			cfg.Results.Type = "in-memory"
+		}
+	}
+	// The results store shares the broker's Redis unless configured separately
+	cfg.Results.Redis = cfg.Broker.Redis
+	if anyFlagSet(fs, "results-redis-") {
+		cfg.Results.Redis = f.resultsRedis.config()
+	}
+}
+
+// redisFlags holds the connection flags of one Redis client
+type redisFlags struct {
+	addr     *string
+	user     *string
+	pass     *string
+	passFile *string
+	db       *int
+	master   *string
+	cluster  *bool
+	tls      *bool
+	ca       *string
+	cert     *string
+	key      *string
+	sni      *string
+}
+
+// bindRedisFlags registers the Redis connection flags, named <prefix>-addr,
+// <prefix>-pass and so on. what describes the connection in the usage text.
+func bindRedisFlags(fs *flag.FlagSet, prefix, what string) *redisFlags {
+	return &redisFlags{
+		addr:     fs.String(prefix+"-addr", "localhost:6379", what+" Redis address, or comma-separated cluster/sentinel addresses"),
+		user:     fs.String(prefix+"-user", "", what+" Redis ACL username"),
+		pass:     fs.String(prefix+"-pass", "", what+" Redis password"),
+		passFile: fs.String(prefix+"-pass-file", "", "File containing the "+what+" Redis password"),
+		db:       fs.Int(prefix+"-db", 0, what+" Redis database"),
+		master:   fs.String(prefix+"-master", "", what+" Redis Sentinel master name (enables sentinel mode)"),
+		cluster:  fs.Bool(prefix+"-cluster", false, "Connect to a "+what+" Redis Cluster"),
+		tls:      fs.Bool(prefix+"-tls", false, "Connect to the "+what+" Redis over TLS"),
+		ca:       fs.String(prefix+"-tls-ca", "", "CA bundle used to verify the "+what+" Redis server"),
+		cert:     fs.String(prefix+"-tls-cert", "", "Client certificate for "+what+" Redis mutual TLS"),
+		key:      fs.String(prefix+"-tls-key", "", "Client key for "+what+" Redis mutual TLS"),
+		sni:      fs.String(prefix+"-tls-server-name", "", "Server name used to verify the "+what+" Redis certificate"),
+	}
+}
+
+// config returns the Redis configuration described by the flags
+func (f *redisFlags) config() config.RedisConfig {
+	return config.RedisConfig{
+		Addrs:        splitList(*f.addr),
+		Username:     *f.user,
+		Password:     *f.pass,
+		PasswordFile: *f.passFile,
+		DB:           *f.db,
+		MasterName:   *f.master,
+		Cluster:      *f.cluster,
+		TLS: config.TLSConfig{
+			Enabled:    *f.tls,
+			CAFile:     *f.ca,
+			CertFile:   *f.cert,
+			KeyFile:    *f.key,
+			ServerName: *f.sni,
+		},
+	}
+}
+
+// anyFlagSet reports whether a flag starting with prefix was set explicitly
+func anyFlagSet(fs *flag.FlagSet, prefix string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if strings.HasPrefix(f.Name, prefix) {
+			set = true
+		}
+	})
+
+	return set
+}
+
+// describeRedis returns a short human readable description of a Redis connection
+func describeRedis(cfg config.RedisConfig) string {
+	switch {
+	case cfg.Cluster:
+		return fmt.Sprintf("cluster %s", strings.Join(cfg.Addrs, ", "))
+	case cfg.MasterName != "":
+		return fmt.Sprintf("sentinel %s (master: %s, DB: %d)", strings.Join(cfg.Addrs, ", "), cfg.MasterName, cfg.DB)
+	default:
+		return fmt.Sprintf("%s (DB: %d)", strings.Join(cfg.Addrs, ", "), cfg.DB)
+	}
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+
+	return out
+}
@@ -6,15 +6,19 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/kalbhor/tasqueue-ui/internal/api"
 	"github.com/kalbhor/tasqueue-ui/internal/config"
+	"github.com/kalbhor/tasqueue-ui/internal/logging"
 	"github.com/kalbhor/tasqueue-ui/internal/service"
+	"github.com/kalbhor/tasqueue-ui/internal/telemetry"
 )
 
 //go:embed all:web
@@ -27,15 +31,37 @@ var (
 )
 
 func main() {
+	// Subcommands for scripting queue operations and the terminal dashboard
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "ctl":
+			os.Exit(runCtl(os.Args[2:]))
+		case "tui":
+			os.Exit(runTUI(os.Args[2:]))
+		}
+	}
+
 	// Parse command line flags
 	var (
-		port       = flag.String("port", "8080", "HTTP server port")
-		host       = flag.String("host", "0.0.0.0", "HTTP server host")
-		brokerType = flag.String("broker", "redis", "Broker type (redis, nats-js, in-memory)")
-		redisAddr  = flag.String("redis-addr", "localhost:6379", "Redis address")
-		redisPass  = flag.String("redis-pass", "", "Redis password")
-		redisDB    = flag.Int("redis-db", 0, "Redis database")
-		showVer    = flag.Bool("version", false, "Show version information")
+		port         = flag.String("port", "8080", "HTTP server port")
+		host         = flag.String("host", "0.0.0.0", "HTTP server host")
+		proxyUser    = flag.Bool("require-proxy-user", false, "Reject silences created or expired without the X-Forwarded-User header of an authenticating proxy")
+		backend      = bindBackendFlags(flag.CommandLine)
+		logFormat    = flag.String("log-format", "text", "Log format (text, json)")
+		logLevel     = flag.String("log-level", "info", "Log level (debug, info, warn, error)")
+		traceExp     = flag.String("trace-exporter", "none", "Trace exporter (none, otlp, stdout)")
+		traceURL     = flag.String("trace-endpoint", "", "OTLP/HTTP traces endpoint URL (defaults to the OTEL_EXPORTER_OTLP_* environment)")
+		traceFile    = flag.String("trace-file", "", "File the stdout trace exporter writes to (default stdout)")
+		traceRatio   = flag.Float64("trace-sample-ratio", 1, "Fraction of new traces to sample (0-1)")
+		readyTimeout = flag.Duration("ready-timeout", 2*time.Second, "Timeout of each backend check made by /ready")
+		readyTTL     = flag.Duration("ready-cache-ttl", 2*time.Second, "How long a /ready result is reused")
+		alertsFile   = flag.String("alerts-config", "", "JSON file of alert rules and webhooks (alerting is disabled without one)")
+		tasksFile    = flag.String("tasks-config", "", "JSON file describing tasks and their payload schemas")
+		statsEvery   = flag.Duration("task-stats-interval", 10*time.Second, "How often per-task statistics count newly finished jobs")
+		retainFile   = flag.String("retention-config", "", "JSON file of retention policies for finished jobs (nothing is deleted without one)")
+		retainDry    = flag.Bool("retention-dry-run", false, "Log and count the jobs retention policies would delete without deleting them")
+		archiveDir   = flag.String("archive-dir", "", "Directory expired jobs are archived to before they are deleted, and searched in; requires -retention-config (overrides its archive dir)")
+		showVer      = flag.Bool("version", false, "Show version information")
 	)
 	flag.Parse()
 
@@ -51,20 +77,81 @@ func main() {
 	cfg := config.DefaultConfig()
 	cfg.Server.Port = *port
 	cfg.Server.Host = *host
-	cfg.Broker.Type = *brokerType
-	cfg.Broker.Redis.Addr = *redisAddr
-	cfg.Broker.Redis.Password = *redisPass
-	cfg.Broker.Redis.DB = *redisDB
+	cfg.Server.RequireProxyUser = *proxyUser
+	cfg.Log.Format = *logFormat
+	cfg.Log.Level = *logLevel
+	cfg.Tracing = config.TracingConfig{
+		Exporter:    *traceExp,
+		Endpoint:    *traceURL,
+		File:        *traceFile,
+		SampleRatio: *traceRatio,
+	}
+	cfg.Readiness = config.ReadinessConfig{
+		Timeout:  *readyTimeout,
+		CacheTTL: *readyTTL,
+	}
+	cfg.Stats.Interval = *statsEvery
+	backend.apply(flag.CommandLine, &cfg)
+	if *alertsFile != "" {
+		alerting, err := config.LoadAlerting(*alertsFile)
+		if err != nil {
+			log.Fatalf("Invalid configuration: %v", err)
+		}
+		cfg.Alerting = alerting
+	}
+	if *tasksFile != "" {
+		catalog, err := config.LoadCatalog(*tasksFile)
+		if err != nil {
+			log.Fatalf("Invalid configuration: %v", err)
+		}
+		cfg.Catalog = catalog
+	}
+	if *retainFile != "" {
+		retention, err := config.LoadRetention(*retainFile)
+		if err != nil {
+			log.Fatalf("Invalid configuration: %v", err)
+		}
+		cfg.Retention = retention
+	}
+	if *retainDry {
+		cfg.Retention.DryRun = true
+	}
+	if *archiveDir != "" {
+		cfg.Retention.Archive.Dir = *archiveDir
+	}
 
 	// Validate configuration
 	if err := cfg.Validate(); err != nil {
 		log.Fatalf("Invalid configuration: %v", err)
 	}
 
+	// Route all logs, including the standard logger, through slog
+	logger, err := logging.New(cfg.Log, os.Stderr)
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	slog.SetDefault(logger)
+
 	log.Printf("Starting Tasqueue UI server...")
 	log.Printf("Broker: %s", cfg.Broker.Type)
-	if cfg.Broker.Type == "redis" {
-		log.Printf("Redis: %s (DB: %d)", cfg.Broker.Redis.Addr, cfg.Broker.Redis.DB)
+	switch cfg.Broker.Type {
+	case "redis":
+		log.Printf("Broker Redis: %s", describeRedis(cfg.Broker.Redis))
+	case "nats-js":
+		log.Printf("Broker NATS: %s", cfg.Broker.NATS.URL)
+	}
+	log.Printf("Results: %s", cfg.Results.Type)
+	if cfg.Results.Type == "redis" {
+		log.Printf("Results Redis: %s", describeRedis(cfg.Results.Redis))
+	}
+
+	// Set up tracing before the service so tasqueue picks up the provider
+	_, shutdownTracing, err := telemetry.Setup(context.Background(), cfg.Tracing, version)
+	if err != nil {
+		log.Fatalf("Failed to initialize tracing: %v", err)
+	}
+	if cfg.Tracing.Exporter != "none" {
+		log.Printf("Tracing: %s exporter, sample ratio %g", cfg.Tracing.Exporter, cfg.Tracing.SampleRatio)
 	}
 
 	// Initialize Tasqueue service
@@ -72,7 +159,41 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to initialize service: %v", err)
 	}
-	log.Printf("Successfully connected to %s broker", cfg.Broker.Type)
+	log.Printf("Successfully connected to %s broker and %s results", cfg.Broker.Type, cfg.Results.Type)
+
+	// Background work such as alerting runs until shutdown. Alerting and
+	// retention are waited for, so they release their locks.
+	bgCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	var background sync.WaitGroup
+	go svc.RunTaskStats(bgCtx, cfg.Stats.Interval)
+	if n := len(cfg.Catalog.Tasks); n > 0 {
+		log.Printf("Task catalog: %d tasks", n)
+	}
+	if cfg.Alerting.Enabled() {
+		log.Printf("Alerting: %d rules, %d failure rules, %d webhooks, every %s", len(cfg.Alerting.Rules),
+			len(cfg.Alerting.Failures), len(cfg.Alerting.Webhooks), cfg.Alerting.Interval.Duration)
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			svc.RunAlerting(bgCtx)
+		}()
+	}
+	if cfg.Retention.Enabled() {
+		mode := ""
+		if cfg.Retention.DryRun {
+			mode = " (dry run)"
+		}
+		log.Printf("Retention: %d policies, every %s%s", len(cfg.Retention.Policies), cfg.Retention.Interval.Duration, mode)
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			svc.RunRetention(bgCtx)
+		}()
+	}
+	if dir := cfg.Retention.Archive.Dir; dir != "" {
+		log.Printf("Archive: %s", dir)
+	}
 
 	// Create API handler
 	handler := api.NewHandler(svc)
@@ -81,7 +202,7 @@ func main() {
 	mux := api.SetupRoutes(handler, staticFS)
 
 	// Wrap with middleware
-	finalHandler := api.LoggingMiddleware(api.CORSMiddleware(mux))
+	finalHandler := api.LoggingMiddleware(api.CORSMiddleware(api.TracingMiddleware(mux)))
 
 	// Create HTTP server
 	addr := fmt.Sprintf("%s:%s", cfg.Server.Host, cfg.Server.Port)
@@ -107,6 +228,7 @@ func main() {
 	<-quit
 
 	log.Println("Shutting down server...")
+	stopBackground()
 
 	// Graceful shutdown with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -116,5 +238,21 @@ func main() {
 		log.Printf("Server forced to shutdown: %v", err)
 	}
 
+	stopped := make(chan struct{})
+	go func() {
+		background.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		log.Printf("Background work didn't stop in time")
+	}
+
+	// Flush any buffered spans
+	if err := shutdownTracing(ctx); err != nil {
+		log.Printf("Failed to shut down tracing: %v", err)
+	}
+
 	log.Println("Server stopped")
 }
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/kalbhor/tasqueue-ui/internal/config"
+	"github.com/kalbhor/tasqueue-ui/internal/service"
+	"github.com/kalbhor/tasqueue-ui/internal/tui"
+)
+
+// runTUI runs the terminal dashboard and returns the process exit code
+func runTUI(args []string) int {
+	fs := flag.NewFlagSet("tui", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: tasqueue-ui tui [flags]\n\nTerminal dashboard connecting directly to the broker and results store.\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+
+	var (
+		refresh = fs.Duration("refresh", 3*time.Second, "Refresh interval")
+		logFile = fs.String("log-file", "", "File to write logs to (default: discard)")
+		backend = bindBackendFlags(fs)
+	)
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	// Logs would corrupt the screen, so they go to a file or nowhere
+	var w io.Writer = io.Discard
+	if *logFile != "" {
+		f, err := os.OpenFile(*logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to open log file: %v\n", err)
+			return 1
+		}
+		defer f.Close()
+		w = f
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(w, nil)))
+
+	cfg := config.DefaultConfig()
+	backend.apply(fs, &cfg)
+
+	svc, err := service.NewService(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to initialize service: %v\n", err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if err := tui.Run(ctx, svc, tui.Options{RefreshInterval: *refresh}); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return 1
+	}
+
+	return 0
+}
@@ -16,8 +16,19 @@ document.addEventListener('DOMContentLoaded', () => {
     initButtons();
     loadDashboard();
     startAutoRefresh();
+    openJobFromHash();
+    window.addEventListener('hashchange', openJobFromHash);
 });
 
+// Deep links such as #job=<id> open the job's details
+function openJobFromHash() {
+    const params = new URLSearchParams(window.location.hash.slice(1));
+    const jobId = params.get('job');
+    if (jobId) {
+        showJobDetail(jobId);
+    }
+}
+
 // Tab navigation
 function initTabs() {
     const tabs = document.querySelectorAll('.tab');
@@ -45,6 +56,10 @@ function switchView(view) {
     // Load data for the view
     if (view === 'dashboard') {
         loadDashboard();
+    } else if (view === 'workers') {
+        loadWorkers();
+    } else if (view === 'archive') {
+        loadArchiveDays();
     }
 }
 
@@ -53,6 +68,8 @@ function initButtons() {
     document.getElementById('refreshBtn').addEventListener('click', () => {
         if (currentView === 'dashboard') {
             loadDashboard();
+        } else if (currentView === 'workers') {
+            loadWorkers();
         }
     });
 
@@ -94,9 +111,19 @@ function initButtons() {
         if (e.key === 'Enter') loadGroup();
     });
 
+    // Archive
+    document.getElementById('searchArchiveBtn').addEventListener('click', searchArchive);
+    document.getElementById('archive-id-input').addEventListener('keypress', (e) => {
+        if (e.key === 'Enter') searchArchive();
+    });
+
     // Modal close
     document.querySelector('.close').addEventListener('click', () => {
         document.getElementById('job-modal').classList.remove('active');
+        // Clear a deep link so following it again reopens the job
+        if (window.location.hash) {
+            history.replaceState(null, '', window.location.pathname + window.location.search);
+        }
     });
 }
 
@@ -105,6 +132,8 @@ function startAutoRefresh() {
     autoRefreshInterval = setInterval(() => {
         if (currentView === 'dashboard') {
             loadDashboard();
+        } else if (currentView === 'workers') {
+            loadWorkers();
         }
     }, 3000); // Refresh every 3 seconds
 }
@@ -126,14 +155,7 @@ async function loadDashboard() {
         document.getElementById('stat-failed').textContent = data.total_failed || 0;
 
         // Update tasks list
-        const tasksList = document.getElementById('tasks-list');
-        if (data.registered_tasks && data.registered_tasks.length > 0) {
-            tasksList.innerHTML = data.registered_tasks
-                .map(task => `<span class="task-badge">${task}</span>`)
-                .join('');
-        } else {
-            tasksList.innerHTML = '<p class="info">No tasks registered</p>';
-        }
+        await loadCatalog();
 
         // Update queue stats
         const queueStats = document.getElementById('queue-stats');
@@ -145,12 +167,132 @@ async function loadDashboard() {
             queueStats.innerHTML = '<p class="info">No queue data available</p>';
         }
 
+        await loadTaskStats();
+
         updateLastUpdate();
     } catch (error) {
         showError('dashboard-view', 'Failed to load dashboard data: ' + error.message);
     }
 }
 
+// Task catalog, from /api/catalog
+async function loadCatalog() {
+    const tasksList = document.getElementById('tasks-list');
+    const response = await fetch(`${API_BASE}/catalog`);
+    const data = await response.json();
+
+    if (!data.tasks || data.tasks.length === 0) {
+        tasksList.innerHTML = '<p class="info">No tasks registered</p>';
+        return;
+    }
+
+    tasksList.innerHTML = data.tasks.map(task => {
+        const details = [
+            task.description,
+            task.owner && `Owner: ${task.owner}`,
+            task.queue && `Queue: ${task.queue}`,
+            task.schema && 'Payload schema enforced'
+        ].filter(Boolean).join('\n');
+        return `<span class="task-badge" title="${escapeHtml(details)}">${escapeHtml(task.name)}` +
+            (task.owner ? ` <span class="task-owner">${escapeHtml(task.owner)}</span>` : '') +
+            '</span>';
+    }).join('');
+}
+
+// Per-task statistics, from /api/tasks
+async function loadTaskStats() {
+    const container = document.getElementById('task-stats');
+    const response = await fetch(`${API_BASE}/tasks`);
+    const data = await response.json();
+
+    if (!data.tasks || data.tasks.length === 0) {
+        container.innerHTML = `<p class="info">${data.complete ? 'No finished jobs yet' : 'Counting finished jobs...'}</p>`;
+        return;
+    }
+
+    const pct = v => `${(v * 100).toFixed(1)}%`;
+    const ms = d => d ? `${d.avg_ms.toFixed(1)} / ${d.p95_ms.toFixed(1)} ms` : '-';
+    const rows = data.tasks.map(t => `
+        <tr>
+            <td>${escapeHtml(t.name)}</td>
+            <td>${t.successful}</td>
+            <td>${t.failed}</td>
+            <td>${pct(t.failure_rate)}</td>
+            <td>${pct(t.retry_rate)}</td>
+            <td>${ms(t.duration)}</td>
+            <td>${t.last_failure
+                ? `<a href="#job=${encodeURIComponent(t.last_failure.job_id)}" title="${escapeHtml(t.last_failure.error)}">${new Date(t.last_failure.at).toLocaleString()}</a>`
+                : '-'}</td>
+        </tr>`).join('');
+
+    container.innerHTML = `
+        <table class="task-stats-table">
+            <thead>
+                <tr>
+                    <th>Task</th><th>Successful</th><th>Failed</th><th>Failure rate</th>
+                    <th>Retry rate</th><th>Avg / p95</th><th>Last failure</th>
+                </tr>
+            </thead>
+            <tbody>${rows}</tbody>
+        </table>`;
+}
+
+function escapeHtml(s) {
+    return String(s).replace(/[&<>"']/g, c => ({'&': '&amp;', '<': '&lt;', '>': '&gt;', '"': '&quot;', "'": '&#39;'})[c]);
+}
+
+// Workers
+const WORKER_STATUS_CLASSES = {
+    live: 'status-successful',
+    stopped: 'status-queued',
+    dead: 'status-failed'
+};
+
+async function loadWorkers() {
+    const container = document.getElementById('workers-list');
+    try {
+        const response = await fetch(`${API_BASE}/workers`);
+        const data = await response.json();
+
+        if (data.error) {
+            showError('workers-view', data.error);
+            return;
+        }
+        if (!data.workers || data.workers.length === 0) {
+            container.innerHTML = '<p class="info">No workers are publishing heartbeats</p>';
+            return;
+        }
+
+        container.innerHTML = data.workers.map(w => `
+            <div class="worker-card">
+                <div class="worker-header">
+                    <span class="worker-id">${escapeHtml(w.hostname)} (PID ${w.pid})</span>
+                    <span class="status-badge ${WORKER_STATUS_CLASSES[w.status]}">${w.status}</span>
+                </div>
+                <div class="worker-meta">
+                    <div><strong>Tasks:</strong> ${w.tasks.map(t =>
+                        `<span class="task-badge">${escapeHtml(t.name)} &times;${t.concurrency}</span>`).join(' ') || '-'}</div>
+                    <div><strong>Queues:</strong> ${(w.queues || []).map(escapeHtml).join(', ') || '-'}</div>
+                    <div><strong>Uptime:</strong> ${formatUptime(w.uptime_seconds)}</div>
+                    <div><strong>Last heartbeat:</strong> ${new Date(w.seen_at).toLocaleString()}</div>
+                </div>
+            </div>`).join('');
+
+        updateLastUpdate();
+    } catch (error) {
+        showError('workers-view', 'Failed to load workers: ' + error.message);
+    }
+}
+
+function formatUptime(seconds) {
+    const d = Math.floor(seconds / 86400);
+    const h = Math.floor(seconds % 86400 / 3600);
+    const m = Math.floor(seconds % 3600 / 60);
+    if (d > 0) return `${d}d ${h}h`;
+    if (h > 0) return `${h}h ${m}m`;
+    return `${m}m ${seconds % 60}s`;
+}
+
 // Jobs
 async function loadJobs() {
     const status = document.getElementById('job-status-filter').value;
@@ -299,7 +441,7 @@ async function searchJob() {
     pagination.style.display = 'none';
 
     try {
-        const response = await fetch(`${API_BASE}/jobs/${jobId}`);
+        const response = await fetch(`${API_BASE}/jobs/${encodeURIComponent(jobId)}`);
         const job = await response.json();
 
         if (job.error) {
@@ -368,48 +510,76 @@ async function showJobDetail(jobId) {
     detailDiv.innerHTML = '<p class="loading">Loading job details...</p>';
 
     try {
-        const response = await fetch(`${API_BASE}/jobs/${jobId}`);
+        const response = await fetch(`${API_BASE}/jobs/${encodeURIComponent(jobId)}`);
         const job = await response.json();
 
         if (job.error) {
             throw new Error(job.error);
         }
 
-        detailDiv.innerHTML = `
+        detailDiv.innerHTML = renderJobDetail(job);
+        detailDiv.querySelector('.job-cancel-btn')?.addEventListener('click', () => cancelJob(job.ID));
+    } catch (error) {
+        detailDiv.innerHTML = `<p class="error">Failed to load job details: ${error.message}</p>`;
+    }
+}
+
+function renderJobDetail(job) {
+    return `
+        <div class="detail-section">
+            <h3>Job Information</h3>
+            <div class="detail-content">
+                <strong>ID:</strong> ${job.ID}<br>
+                <strong>Task:</strong> ${job.Job?.Task || 'N/A'}<br>
+                <strong>Status:</strong> <span class="status-badge status-${job.Status.toLowerCase()}">${job.Status}</span><br>
+                <strong>Queue:</strong> ${job.Queue}<br>
+                <strong>Retries:</strong> ${job.Retried}/${job.MaxRetry}<br>
+                <strong>Processed At:</strong> ${job.ProcessedAt || 'N/A'}<br>
+                ${job.PrevErr ? `<strong>Error:</strong> ${job.PrevErr}<br>` : ''}
+            </div>
+        </div>
+
+        ${job.Job?.Payload ? `
             <div class="detail-section">
-                <h3>Job Information</h3>
+                <h3>Payload</h3>
                 <div class="detail-content">
-                    <strong>ID:</strong> ${job.ID}<br>
-                    <strong>Task:</strong> ${job.Job?.Task || 'N/A'}<br>
-                    <strong>Status:</strong> <span class="status-badge status-${job.Status.toLowerCase()}">${job.Status}</span><br>
-                    <strong>Queue:</strong> ${job.Queue}<br>
-                    <strong>Retries:</strong> ${job.Retried}/${job.MaxRetry}<br>
-                    <strong>Processed At:</strong> ${job.ProcessedAt || 'N/A'}<br>
-                    ${job.PrevErr ? `<strong>Error:</strong> ${job.PrevErr}<br>` : ''}
+                    ${formatPayload(job.Job.Payload)}
                 </div>
             </div>
+        ` : ''}
 
-            ${job.Job?.Payload ? `
-                <div class="detail-section">
-                    <h3>Payload</h3>
-                    <div class="detail-content">
-                        ${formatPayload(job.Job.Payload)}
-                    </div>
+        ${job.result_data ? `
+            <div class="detail-section">
+                <h3>Result Data</h3>
+                <div class="detail-content">
+                    ${formatPayload(job.result_data)}
                 </div>
-            ` : ''}
+            </div>
+        ` : ''}
 
-            ${job.result_data ? `
-                <div class="detail-section">
-                    <h3>Result Data</h3>
-                    <div class="detail-content">
-                        ${formatPayload(job.result_data)}
-                    </div>
-                </div>
-            ` : ''}
-        `;
+        ${job.Status === 'queued' || job.Status === 'retrying' ? `
+            <div class="detail-actions">
+                <button class="btn btn-danger job-cancel-btn">Cancel job</button>
+            </div>
+        ` : ''}
+    `;
+}
+
+async function cancelJob(jobId) {
+    if (!confirm(`Cancel job ${jobId}? It will be removed from its queue.`)) {
+        return;
+    }
+
+    try {
+        const response = await fetch(`${API_BASE}/jobs/${encodeURIComponent(jobId)}/cancel`, {method: 'POST'});
+        const res = await response.json();
+        if (res.error) {
+            throw new Error(res.error);
+        }
     } catch (error) {
-        detailDiv.innerHTML = `<p class="error">Failed to load job details: ${error.message}</p>`;
+        alert(`Failed to cancel job: ${error.message}`);
     }
+    await showJobDetail(jobId);
 }
 
 // Chains
@@ -433,25 +603,54 @@ async function loadChain() {
         }
 
         chainsList.innerHTML = renderChainDetail(chain);
+        chainsList.querySelectorAll('.chain-resume-btn').forEach(btn => {
+            btn.addEventListener('click', () => resumeChain(chain.ID, btn.dataset.skip === 'true'));
+        });
     } catch (error) {
         chainsList.innerHTML = `<p class="error">Failed to load chain: ${error.message}</p>`;
     }
 }
 
+async function resumeChain(chainId, skip) {
+    const what = skip ? 'skip the failed step and continue with the next one' : 're-run the failed step';
+    if (!confirm(`Resume chain ${chainId}: ${what}?`)) {
+        return;
+    }
+
+    try {
+        const response = await fetch(`${API_BASE}/chains/${encodeURIComponent(chainId)}/resume?skip=${skip}`, {method: 'POST'});
+        const res = await response.json();
+        if (res.error) {
+            throw new Error(res.error);
+        }
+        await loadChain();
+    } catch (error) {
+        alert(`Failed to resume chain: ${error.message}`);
+    }
+}
+
 function renderChainDetail(chain) {
     const statusClass = `status-${chain.Status.toLowerCase()}`;
+    const steps = chain.steps || [];
+    const blocked = steps.find(st => st.position === chain.blocked_at);
 
     let jobsHtml = '';
-    if (chain.Jobs && chain.Jobs.length > 0) {
+    if (steps.length > 0) {
         jobsHtml = `
             <div class="chain-progress">
-                ${chain.Jobs.map((job, idx) => `
-                    <div class="chain-job">
-                        <div><strong>${job.Job?.Task || 'Job'}</strong></div>
-                        <div><span class="status-badge status-${job.Status.toLowerCase()}">${job.Status}</span></div>
-                        <div style="font-size: 11px; margin-top: 5px;">${job.ID}</div>
+                ${steps.map((st, idx) => `
+                    <div class="chain-job${st.position === chain.blocked_at ? ' chain-job-blocked' : ''}${st.job_id && st.status !== 'missing' ? '' : ' chain-job-planned'}">
+                        <div class="chain-step">Step ${st.position}</div>
+                        <div><strong>${escapeHtml(st.task || 'Job')}</strong></div>
+                        <div><span class="status-badge status-${st.status.toLowerCase()}">${st.status.replace('_', ' ')}</span></div>
+                        ${st.job_id ? `<div style="font-size: 11px; margin-top: 5px;">${escapeHtml(st.job_id)}</div>` : ''}
+                        ${st.processed_at ? `<div style="font-size: 11px;">${new Date(st.processed_at).toLocaleString()}</div>` : ''}
+                        ${st.timing ? `<div style="font-size: 11px;">${st.duration_ms.toFixed(1)} ms</div>` : ''}
+                        ${st.error ? `<div class="chain-step-error" title="${escapeHtml(st.error)}">${escapeHtml(st.error)}</div>` : ''}
+                        ${st.resumed_from ? `<div style="font-size: 11px;" title="${escapeHtml(st.resumed_from.join('\n'))}">Resumed after ${st.resumed_from.length} failed attempt${st.resumed_from.length > 1 ? 's' : ''}</div>` : ''}
+                        ${st.result ? `<div style="font-size: 11px;" title="${escapeHtml(atob(st.result))}">Result: ${escapeHtml(atob(st.result).slice(0, 40))}</div>` : ''}
                     </div>
-                    ${idx < chain.Jobs.length - 1 ? '<div class="chain-arrow">→</div>' : ''}
+                    ${idx < steps.length - 1 ? '<div class="chain-arrow">→</div>' : ''}
                 `).join('')}
             </div>
         `;
@@ -465,15 +664,21 @@ function renderChainDetail(chain) {
             </div>
             <div class="chain-meta">
                 <div class="meta-item">
-                    <span class="meta-label">Current Job</span>
-                    <span>${chain.JobID || 'N/A'}</span>
+                    <span class="meta-label">${chain.Status === 'failed' ? 'Failed At' : 'Blocked On'}</span>
+                    <span>${blocked ? `Step ${blocked.position} of ${steps.length}: ${escapeHtml(blocked.task || blocked.job_id)}` : 'N/A'}</span>
                 </div>
                 <div class="meta-item">
-                    <span class="meta-label">Completed Jobs</span>
-                    <span>${chain.PrevJobs?.length || 0}</span>
+                    <span class="meta-label">Completed Steps</span>
+                    <span>${steps.filter(st => st.status === 'successful').length} of ${steps.length}</span>
                 </div>
             </div>
             ${jobsHtml}
+            ${chain.Status === 'failed' ? `
+                <div class="detail-actions">
+                    <button class="btn btn-primary chain-resume-btn" data-skip="false">Resume from failed step</button>
+                    <button class="btn btn-secondary chain-resume-btn" data-skip="true">Skip failed step</button>
+                </div>
+            ` : ''}
         </div>
     `;
 }
@@ -499,11 +704,63 @@ async function loadGroup() {
         }
 
         groupsList.innerHTML = renderGroupDetail(group);
+        groupsList.querySelector('.group-rerun-btn')?.addEventListener('click', () => rerunGroup(group.ID));
+        groupsList.querySelectorAll('.group-link').forEach(link => {
+            link.addEventListener('click', (e) => {
+                e.preventDefault();
+                document.getElementById('group-id-input').value = link.dataset.group;
+                loadGroup();
+            });
+        });
     } catch (error) {
         groupsList.innerHTML = `<p class="error">Failed to load group: ${error.message}</p>`;
     }
 }
 
+async function rerunGroup(groupId) {
+    const retries = prompt(`Re-run the failed jobs of group ${groupId} as a new group.\nMax retries (leave empty to keep each job's own):`, '');
+    if (retries === null) {
+        return;
+    }
+
+    try {
+        const query = retries.trim() ? `?max_retries=${encodeURIComponent(retries.trim())}` : '';
+        const response = await fetch(`${API_BASE}/groups/${encodeURIComponent(groupId)}/rerun${query}`, {method: 'POST'});
+        const res = await response.json();
+        if (res.error) {
+            throw new Error(res.error);
+        }
+        await loadGroup();
+    } catch (error) {
+        alert(`Failed to re-run group: ${error.message}`);
+    }
+}
+
+function renderGroupOutcome(combined) {
+    return `
+        <div class="group-outcome">
+            <div class="group-header">
+                <strong>Combined outcome</strong>
+                <span class="status-badge status-${combined.status}">${combined.status}</span>
+            </div>
+            <div class="group-meta">
+                <div class="meta-item"><span class="meta-label">Successful</span><span>${combined.successful}</span></div>
+                <div class="meta-item"><span class="meta-label">Failed</span><span>${combined.failed}</span></div>
+                <div class="meta-item"><span class="meta-label">Pending</span><span>${combined.pending}</span></div>
+            </div>
+            <div class="group-jobs-grid">
+                ${combined.jobs.map(m => `
+                    <div class="group-job-item">
+                        <div><span class="status-badge status-${m.status}">${m.status.replace('_', ' ')}</span></div>
+                        <div style="font-size: 11px; color: var(--gray-600); margin-top: 8px;">${escapeHtml(m.job_id)}</div>
+                        ${m.attempts.length > 1 ? `<div style="font-size: 11px;" title="${escapeHtml(m.attempts.join('\n'))}">${m.attempts.length} attempts</div>` : ''}
+                    </div>
+                `).join('')}
+            </div>
+        </div>
+    `;
+}
+
 function renderGroupDetail(group) {
     const statusClass = `status-${group.Status.toLowerCase()}`;
 
@@ -533,12 +790,97 @@ function renderGroupDetail(group) {
                     <span class="meta-label">Total Jobs</span>
                     <span>${Object.keys(group.JobStatus || {}).length}</span>
                 </div>
+                ${group.rerun_of ? `
+                    <div class="meta-item">
+                        <span class="meta-label">Re-run Of</span>
+                        <a href="#" class="group-link" data-group="${escapeHtml(group.rerun_of)}">${escapeHtml(group.rerun_of)}</a>
+                    </div>
+                ` : ''}
+                ${group.reruns ? `
+                    <div class="meta-item">
+                        <span class="meta-label">Re-runs</span>
+                        <span>${group.reruns.map(r => `<a href="#" class="group-link" data-group="${escapeHtml(r.rerun_group)}">${escapeHtml(r.rerun_group)}</a>`).join('<br>')}</span>
+                    </div>
+                ` : ''}
             </div>
             ${jobsHtml}
+            ${group.combined ? renderGroupOutcome(group.combined) : ''}
+            ${Object.values(group.JobStatus || {}).includes('failed') ? `
+                <div class="detail-actions">
+                    <button class="btn btn-primary group-rerun-btn">Re-run failed jobs</button>
+                </div>
+            ` : ''}
         </div>
     `;
 }
 
+// Archive
+async function loadArchiveDays() {
+    const select = document.getElementById('archive-day-select');
+    const archiveList = document.getElementById('archive-list');
+
+    try {
+        const response = await fetch(`${API_BASE}/archive`);
+        const data = await response.json();
+
+        if (data.error) {
+            throw new Error(data.error);
+        }
+
+        const selected = select.value;
+        if (!data.days || data.days.length === 0) {
+            select.innerHTML = '<option value="">No archived days</option>';
+            return;
+        }
+        select.innerHTML = data.days.map(d => {
+            const tasks = d.tasks.map(t => t.task || 'unknown').join(', ');
+            return `<option value="${d.day}" title="${escapeHtml(tasks)}">${d.day} (${d.tasks.length} tasks)</option>`;
+        }).join('');
+        if (selected && data.days.some(d => d.day === selected)) {
+            select.value = selected;
+        }
+    } catch (error) {
+        select.innerHTML = '<option value="">Unavailable</option>';
+        archiveList.innerHTML = `<p class="error">Failed to load the archive: ${escapeHtml(error.message)}</p>`;
+    }
+}
+
+async function searchArchive() {
+    const day = document.getElementById('archive-day-select').value;
+    const jobId = document.getElementById('archive-id-input').value.trim();
+    const archiveList = document.getElementById('archive-list');
+
+    if (!day || !jobId) {
+        archiveList.innerHTML = '<p class="error">Please pick a day and enter a Job ID</p>';
+        return;
+    }
+
+    archiveList.innerHTML = '<p class="loading">Searching the archive...</p>';
+
+    try {
+        const response = await fetch(`${API_BASE}/archive/${encodeURIComponent(day)}/jobs/${encodeURIComponent(jobId)}`);
+        const res = await response.json();
+
+        if (res.error) {
+            throw new Error(res.error);
+        }
+
+        archiveList.innerHTML = `
+            <div class="search-result-info">
+                <p><strong>Archived in:</strong> ${escapeHtml(res.file)}</p>
+            </div>
+            ${renderJobDetail(res.job)}
+        `;
+    } catch (error) {
+        archiveList.innerHTML = `
+            <div class="no-results">
+                <p class="error">${escapeHtml(error.message)}</p>
+                <p class="info">Jobs are archived under the UTC day they finished on.</p>
+            </div>
+        `;
+    }
+}
+
 // Utility functions
 function formatPayload(data) {
     if (!data) return 'No data';
@@ -2,14 +2,17 @@ module github.com/kalbhor/tasqueue-ui/examples/test-worker
 
 go 1.23
 
-require github.com/kalbhor/tasqueue/v2 v2.0.0
+require (
+	github.com/go-redis/redis/v8 v8.11.5
+	github.com/kalbhor/tasqueue-ui v0.0.0
+	github.com/kalbhor/tasqueue/v2 v2.0.0
+)
 
 require (
 	github.com/cespare/xxhash/v2 v2.1.2 // indirect
 	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
 	github.com/go-logr/logr v1.2.3 // indirect
 	github.com/go-logr/stdr v1.2.2 // indirect
-	github.com/go-redis/redis/v8 v8.11.5 // indirect
 	github.com/google/uuid v1.3.0 // indirect
 	github.com/robfig/cron/v3 v3.0.1 // indirect
 	github.com/vmihailenco/msgpack/v5 v5.3.5 // indirect
@@ -21,3 +24,5 @@ require (
 )
 
 replace github.com/kalbhor/tasqueue/v2 => ../../../Tasqueue
+
+replace github.com/kalbhor/tasqueue-ui => ../..
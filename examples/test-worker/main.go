@@ -11,9 +11,12 @@ import (
 	"os/signal"
 	"time"
 
+	"github.com/go-redis/redis/v8"
 	"github.com/kalbhor/tasqueue/v2"
 	rb "github.com/kalbhor/tasqueue/v2/brokers/redis"
 	rr "github.com/kalbhor/tasqueue/v2/results/redis"
+
+	"github.com/kalbhor/tasqueue-ui/worker"
 )
 
 type Payload struct {
@@ -108,13 +111,14 @@ func main() {
 
 	// Register tasks
 	log.Println("Registering tasks...")
-	if err := srv.RegisterTask("add", AddProcessor, tasqueue.TaskOpts{Concurrency: 3}); err != nil {
+	opts := tasqueue.TaskOpts{Concurrency: 3}
+	if err := srv.RegisterTask("add", AddProcessor, opts); err != nil {
 		log.Fatal(err)
 	}
-	if err := srv.RegisterTask("multiply", MultiplyProcessor, tasqueue.TaskOpts{Concurrency: 3}); err != nil {
+	if err := srv.RegisterTask("multiply", MultiplyProcessor, opts); err != nil {
 		log.Fatal(err)
 	}
-	if err := srv.RegisterTask("fail", FailProcessor, tasqueue.TaskOpts{Concurrency: 3}); err != nil {
+	if err := srv.RegisterTask("fail", FailProcessor, opts); err != nil {
 		log.Fatal(err)
 	}
 
@@ -225,7 +229,8 @@ func main() {
 	log.Println("\nView these jobs in the Tasqueue-UI dashboard!")
 	log.Println("Press Ctrl+C to stop")
 
-	// Keep running
-	<-ctx.Done()
+	// Keep running, publishing heartbeats so the UI lists this worker
+	rdb := redis.NewClient(&redis.Options{Addr: redisAddr})
+	worker.Heartbeat(ctx, rdb, map[string]tasqueue.TaskOpts{"add": opts, "multiply": opts, "fail": opts})
 	log.Println("Shutting down...")
 }
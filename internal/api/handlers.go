@@ -2,8 +2,15 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kalbhor/tasqueue/v2"
 
 	"github.com/kalbhor/tasqueue-ui/internal/service"
 )
@@ -22,7 +29,8 @@ func NewHandler(svc *service.Service) *Handler {
 
 // ErrorResponse represents an error response
 type ErrorResponse struct {
-	Error string `json:"error"`
+	Error     string `json:"error"`
+	RequestID string `json:"request_id,omitempty"`
 }
 
 // respondJSON sends a JSON response
@@ -32,9 +40,13 @@ func respondJSON(w http.ResponseWriter, status int, data interface{}) {
 	json.NewEncoder(w).Encode(data)
 }
 
-// respondError sends an error response
+// respondError sends an error response. The request ID set by
+// LoggingMiddleware is included so errors can be matched to log entries.
 func respondError(w http.ResponseWriter, status int, message string) {
-	respondJSON(w, status, ErrorResponse{Error: message})
+	respondJSON(w, status, ErrorResponse{
+		Error:     message,
+		RequestID: w.Header().Get(RequestIDHeader),
+	})
 }
 
 // GetDashboardStats handles GET /api/stats
@@ -57,9 +69,13 @@ func (h *Handler) GetJob(w http.ResponseWriter, r *http.Request) {
 	}
 
 	job, err := h.service.GetJob(r.Context(), id)
-	if err != nil {
+	switch {
+	case errors.Is(err, tasqueue.ErrNotFound):
 		respondError(w, http.StatusNotFound, err.Error())
 		return
+	case err != nil:
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
 	}
 
 	respondJSON(w, http.StatusOK, job)
@@ -125,6 +141,8 @@ func (h *Handler) GetPendingCount(w http.ResponseWriter, r *http.Request) {
 }
 
 // GetJobsByStatus handles GET /api/jobs?status=<status>
+// The optional task, queue and limit parameters filter the jobs, and
+// details=true includes their messages in the response.
 func (h *Handler) GetJobsByStatus(w http.ResponseWriter, r *http.Request) {
 	status := r.URL.Query().Get("status")
 	if status == "" {
@@ -132,19 +150,332 @@ func (h *Handler) GetJobsByStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	jobIDs, err := h.service.GetJobsByStatus(r.Context(), status)
+	filter, err := parseJobFilter(r)
 	if err != nil {
 		respondError(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	details := r.URL.Query().Get("details") == "true"
+
+	// Filtering and details need the job messages; plain listings only need IDs
+	if filter == (service.JobFilter{}) && !details {
+		jobIDs, err := h.service.GetJobsByStatus(r.Context(), status)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
 
-	respondJSON(w, http.StatusOK, map[string]interface{}{
+		respondJSON(w, http.StatusOK, map[string]interface{}{
+			"status":  status,
+			"job_ids": jobIDs,
+			"count":   len(jobIDs),
+		})
+		return
+	}
+
+	jobs, err := h.service.ListJobs(r.Context(), status, filter)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	jobIDs := make([]string, len(jobs))
+	for i, job := range jobs {
+		jobIDs[i] = job.ID
+	}
+
+	resp := map[string]interface{}{
 		"status":  status,
 		"job_ids": jobIDs,
 		"count":   len(jobIDs),
+	}
+	if details {
+		resp["jobs"] = jobs
+	}
+	respondJSON(w, http.StatusOK, resp)
+}
+
+// PurgeJobs handles DELETE /api/jobs?status=<status>
+// The optional task, queue and limit parameters restrict the jobs deleted.
+func (h *Handler) PurgeJobs(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		respondError(w, http.StatusBadRequest, "status parameter is required")
+		return
+	}
+
+	filter, err := parseJobFilter(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	deleted, err := h.service.PurgeJobs(r.Context(), status, filter)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"status":  status,
+		"deleted": deleted,
 	})
 }
 
+// ExportJobs handles GET /api/jobs/export, streaming every matching job as
+// NDJSON or CSV. Errors after the first job has been written can't change
+// the response status, so the connection is aborted instead to signal an
+// incomplete export.
+func (h *Handler) ExportJobs(w http.ResponseWriter, r *http.Request) {
+	opts, err := parseExportOptions(r)
+	if err == nil {
+		err = opts.Validate()
+	}
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	contentType := "application/x-ndjson"
+	if opts.Format == service.ExportCSV {
+		contentType = "text/csv; charset=utf-8"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q",
+		fmt.Sprintf("jobs-%s-%s.%s", opts.Status, time.Now().UTC().Format("20060102T150405Z"), opts.Format)))
+
+	sw := &streamWriter{w: w, rc: http.NewResponseController(w)}
+	if _, err := h.service.ExportJobs(r.Context(), sw, opts); err != nil {
+		if !sw.started {
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// streamTimeout is how long each part of a streamed request or response
+// may take. Deadlines are pushed back as data flows, so exports and imports
+// can outlast the server's read and write timeouts.
+const streamTimeout = 30 * time.Second
+
+// streamWriter extends the connection's write deadline while a response streams
+type streamWriter struct {
+	w        http.ResponseWriter
+	rc       *http.ResponseController
+	started  bool
+	deadline time.Time
+}
+
+func (s *streamWriter) Write(b []byte) (int, error) {
+	s.started = true
+	if now := time.Now(); s.deadline.Sub(now) < streamTimeout/2 {
+		s.deadline = now.Add(streamTimeout)
+		// Not every writer supports deadlines; the server timeout applies then
+		_ = s.rc.SetWriteDeadline(s.deadline)
+	}
+	return s.w.Write(b)
+}
+
+// streamReader extends the connection's read deadline while a request body streams
+type streamReader struct {
+	r        io.Reader
+	rc       *http.ResponseController
+	deadline time.Time
+}
+
+func (s *streamReader) Read(b []byte) (int, error) {
+	if now := time.Now(); s.deadline.Sub(now) < streamTimeout/2 {
+		s.deadline = now.Add(streamTimeout)
+		_ = s.rc.SetReadDeadline(s.deadline)
+	}
+	return s.r.Read(b)
+}
+
+// parseExportOptions reads the export query parameters
+func parseExportOptions(r *http.Request) (service.ExportOptions, error) {
+	q := r.URL.Query()
+	filter, err := parseJobFilter(r)
+	if err != nil {
+		return service.ExportOptions{}, err
+	}
+
+	opts := service.ExportOptions{
+		JobFilter: filter,
+		Status:    q.Get("status"),
+		Format:    q.Get("format"),
+	}
+	if cols := q.Get("columns"); cols != "" {
+		for _, c := range strings.Split(cols, ",") {
+			if c = strings.TrimSpace(c); c != "" {
+				opts.Columns = append(opts.Columns, c)
+			}
+		}
+	}
+	if opts.Since, err = parseTime(q.Get("since")); err != nil {
+		return opts, fmt.Errorf("invalid since: %w", err)
+	}
+	if opts.Until, err = parseTime(q.Get("until")); err != nil {
+		return opts, fmt.Errorf("invalid until: %w", err)
+	}
+
+	return opts, nil
+}
+
+// parseTime parses an RFC 3339 timestamp, or a duration such as 24h
+// meaning that long ago. An empty string is the zero time.
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s is neither an RFC 3339 time nor a duration", s)
+	}
+	return t, nil
+}
+
+// ImportJobs handles POST /api/jobs/import. The body holds job messages as
+// NDJSON; the outcome of each line is streamed back as NDJSON while the
+// body is still being read, so large imports report progress as they go.
+func (h *Handler) ImportJobs(w http.ResponseWriter, r *http.Request) {
+	opts, err := parseImportOptions(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// HTTP/1 handlers can't read the body once the response has started
+	// unless full duplex is enabled; HTTP/2 doesn't need it
+	rc := http.NewResponseController(w)
+	_ = rc.EnableFullDuplex()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	sw := &streamWriter{w: w, rc: rc}
+	enc := json.NewEncoder(sw)
+
+	body := &streamReader{r: r.Body, rc: rc}
+	_, err = h.service.ImportJobs(r.Context(), body, opts, func(res service.ImportResult) error {
+		if err := enc.Encode(res); err != nil {
+			return err
+		}
+		return rc.Flush()
+	})
+	if err != nil {
+		if !sw.started {
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// parseImportOptions reads the import query parameters
+func parseImportOptions(r *http.Request) (service.ImportOptions, error) {
+	q := r.URL.Query()
+	opts := service.ImportOptions{
+		DryRun: q.Get("dry_run") == "true",
+	}
+
+	var err error
+	if opts.Queues, err = service.ParseRewrite(q["queue"]); err != nil {
+		return opts, err
+	}
+	if opts.Tasks, err = service.ParseRewrite(q["task"]); err != nil {
+		return opts, err
+	}
+	if rateStr := q.Get("rate"); rateStr != "" {
+		opts.Rate, err = strconv.ParseFloat(rateStr, 64)
+		if err != nil || opts.Rate < 0 {
+			return opts, fmt.Errorf("invalid rate: %s", rateStr)
+		}
+	}
+
+	return opts, nil
+}
+
+// RetryJob handles POST /api/jobs/:id/retry
+func (h *Handler) RetryJob(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		respondError(w, http.StatusBadRequest, "job ID is required")
+		return
+	}
+
+	newID, err := h.service.RetryJob(r.Context(), id)
+	switch {
+	case errors.Is(err, tasqueue.ErrNotFound):
+		respondError(w, http.StatusNotFound, err.Error())
+		return
+	case errors.Is(err, service.ErrNotRetryable):
+		respondError(w, http.StatusConflict, err.Error())
+		return
+	case errors.Is(err, service.ErrInvalidPayload):
+		respondError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	case err != nil:
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{
+		"id":     id,
+		"new_id": newID,
+	})
+}
+
+// CancelJob handles POST /api/jobs/{id}/cancel
+func (h *Handler) CancelJob(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		respondError(w, http.StatusBadRequest, "job ID is required")
+		return
+	}
+
+	err := h.service.CancelJob(r.Context(), id)
+	switch {
+	case errors.Is(err, tasqueue.ErrNotFound):
+		respondError(w, http.StatusNotFound, err.Error())
+		return
+	case errors.Is(err, service.ErrNotCancellable):
+		respondError(w, http.StatusConflict, err.Error())
+		return
+	case errors.Is(err, errors.ErrUnsupported):
+		respondError(w, http.StatusNotImplemented, err.Error())
+		return
+	case err != nil:
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{
+		"id":     id,
+		"status": service.JobCancelled,
+	})
+}
+
+// parseJobFilter reads the task, queue and limit query parameters
+func parseJobFilter(r *http.Request) (service.JobFilter, error) {
+	q := r.URL.Query()
+	filter := service.JobFilter{
+		Task:  q.Get("task"),
+		Queue: q.Get("queue"),
+	}
+
+	if limitStr := q.Get("limit"); limitStr != "" {
+		n, err := strconv.Atoi(limitStr)
+		if err != nil || n < 0 {
+			return filter, fmt.Errorf("invalid limit: %s", limitStr)
+		}
+		filter.Limit = n
+	}
+
+	return filter, nil
+}
+
 // DeleteJob handles DELETE /api/jobs/:id
 func (h *Handler) DeleteJob(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
@@ -171,14 +502,95 @@ func (h *Handler) GetChain(w http.ResponseWriter, r *http.Request) {
 	}
 
 	chain, err := h.service.GetChain(r.Context(), id)
-	if err != nil {
+	switch {
+	case errors.Is(err, tasqueue.ErrNotFound):
 		respondError(w, http.StatusNotFound, err.Error())
 		return
+	case err != nil:
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
 	}
 
 	respondJSON(w, http.StatusOK, chain)
 }
 
+// ResumeChain handles POST /api/chains/{id}/resume
+func (h *Handler) ResumeChain(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		respondError(w, http.StatusBadRequest, "chain ID is required")
+		return
+	}
+
+	var skip bool
+	if v := r.URL.Query().Get("skip"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, fmt.Sprintf("invalid skip: %s", v))
+			return
+		}
+		skip = b
+	}
+
+	res, err := h.service.ResumeChain(r.Context(), id, skip)
+	switch {
+	case errors.Is(err, tasqueue.ErrNotFound):
+		respondError(w, http.StatusNotFound, err.Error())
+		return
+	case errors.Is(err, service.ErrNotResumable), errors.Is(err, service.ErrLocked):
+		respondError(w, http.StatusConflict, err.Error())
+		return
+	case errors.Is(err, service.ErrInvalidPayload):
+		respondError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	case err != nil:
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, res)
+}
+
+// GetGraph handles GET /api/graph/{id}
+func (h *Handler) GetGraph(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	format := q.Get("format")
+	switch format {
+	case "", "json", "dot", "mermaid":
+	default:
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("invalid format: %q (must be json, dot or mermaid)", format))
+		return
+	}
+	kind := q.Get("kind")
+	switch kind {
+	case "", service.NodeJob, service.NodeChain, service.NodeGroup:
+	default:
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("invalid kind: %q (must be job, chain or group)", kind))
+		return
+	}
+
+	graph, err := h.service.GetGraph(r.Context(), r.PathValue("id"), kind)
+	switch {
+	case errors.Is(err, tasqueue.ErrNotFound):
+		respondError(w, http.StatusNotFound, err.Error())
+		return
+	case err != nil:
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	switch format {
+	case "dot":
+		w.Header().Set("Content-Type", "text/vnd.graphviz; charset=utf-8")
+		io.WriteString(w, graph.DOT())
+	case "mermaid":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		io.WriteString(w, graph.Mermaid())
+	default:
+		respondJSON(w, http.StatusOK, graph)
+	}
+}
+
 // ListChains handles GET /api/chains
 func (h *Handler) ListChains(w http.ResponseWriter, r *http.Request) {
 	chains, err := h.service.ListChains(r.Context())
@@ -202,14 +614,56 @@ func (h *Handler) GetGroup(w http.ResponseWriter, r *http.Request) {
 	}
 
 	group, err := h.service.GetGroup(r.Context(), id)
-	if err != nil {
+	switch {
+	case errors.Is(err, tasqueue.ErrNotFound):
 		respondError(w, http.StatusNotFound, err.Error())
 		return
+	case err != nil:
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
 	}
 
 	respondJSON(w, http.StatusOK, group)
 }
 
+// RerunGroup handles POST /api/groups/{id}/rerun
+func (h *Handler) RerunGroup(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		respondError(w, http.StatusBadRequest, "group ID is required")
+		return
+	}
+
+	var maxRetries *uint32
+	if v := r.URL.Query().Get("max_retries"); v != "" {
+		n, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, fmt.Sprintf("invalid max_retries: %s", v))
+			return
+		}
+		m := uint32(n)
+		maxRetries = &m
+	}
+
+	res, err := h.service.RerunGroup(r.Context(), id, maxRetries)
+	switch {
+	case errors.Is(err, tasqueue.ErrNotFound):
+		respondError(w, http.StatusNotFound, err.Error())
+		return
+	case errors.Is(err, service.ErrNoFailedJobs), errors.Is(err, service.ErrLocked):
+		respondError(w, http.StatusConflict, err.Error())
+		return
+	case errors.Is(err, service.ErrInvalidPayload):
+		respondError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	case err != nil:
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, res)
+}
+
 // ListGroups handles GET /api/groups
 func (h *Handler) ListGroups(w http.ResponseWriter, r *http.Request) {
 	groups, err := h.service.ListGroups(r.Context())
@@ -224,6 +678,20 @@ func (h *Handler) ListGroups(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// ListQueues handles GET /api/queues
+func (h *Handler) ListQueues(w http.ResponseWriter, r *http.Request) {
+	queues, err := h.service.ListQueues(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"queues": queues,
+		"count":  len(queues),
+	})
+}
+
 // Search handles GET /api/search?q=<query>
 func (h *Handler) Search(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query().Get("q")
@@ -233,15 +701,271 @@ func (h *Handler) Search(w http.ResponseWriter, r *http.Request) {
 	}
 
 	result, err := h.service.Search(r.Context(), query)
-	if err != nil {
+	switch {
+	case errors.Is(err, tasqueue.ErrNotFound):
 		respondError(w, http.StatusNotFound, err.Error())
 		return
+	case err != nil:
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
 	}
 
 	respondJSON(w, http.StatusOK, result)
 }
 
-// HealthCheck handles GET /health
+// ListTasks handles GET /api/tasks
+func (h *Handler) ListTasks(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, h.service.TaskStats())
+}
+
+// GetTask handles GET /api/tasks/{name}
+func (h *Handler) GetTask(w http.ResponseWriter, r *http.Request) {
+	stats, err := h.service.GetTaskStats(r.PathValue("name"))
+	if err != nil {
+		respondError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, stats)
+}
+
+// ListCatalog handles GET /api/catalog
+func (h *Handler) ListCatalog(w http.ResponseWriter, r *http.Request) {
+	tasks, err := h.service.TaskCatalog(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"tasks": tasks,
+		"count": len(tasks),
+	})
+}
+
+// GetCatalogTask handles GET /api/catalog/{name}
+func (h *Handler) GetCatalogTask(w http.ResponseWriter, r *http.Request) {
+	task, err := h.service.GetCatalogTask(r.Context(), r.PathValue("name"))
+	switch {
+	case errors.Is(err, service.ErrTaskNotFound):
+		respondError(w, http.StatusNotFound, err.Error())
+		return
+	case err != nil:
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, task)
+}
+
+// ListWorkers handles GET /api/workers
+func (h *Handler) ListWorkers(w http.ResponseWriter, r *http.Request) {
+	workers, err := h.service.ListWorkers(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	live := 0
+	for _, wk := range workers {
+		if wk.Status == service.WorkerLive {
+			live++
+		}
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"workers": workers,
+		"count":   len(workers),
+		"live":    live,
+	})
+}
+
+// GetAlerts handles GET /api/alerts
+func (h *Handler) GetAlerts(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, h.service.Alerts())
+}
+
+// GetRetention handles GET /api/retention
+func (h *Handler) GetRetention(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, h.service.Retention())
+}
+
+// ListArchive handles GET /api/archive
+func (h *Handler) ListArchive(w http.ResponseWriter, r *http.Request) {
+	days, err := h.service.ListArchive(r.Context())
+	switch {
+	case errors.Is(err, errors.ErrUnsupported):
+		respondError(w, http.StatusNotImplemented, err.Error())
+		return
+	case err != nil:
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"days":  days,
+		"count": len(days),
+	})
+}
+
+// GetArchivedJob handles GET /api/archive/:day/jobs/:id
+func (h *Handler) GetArchivedJob(w http.ResponseWriter, r *http.Request) {
+	job, err := h.service.SearchArchive(r.Context(), r.PathValue("day"), r.PathValue("id"))
+	switch {
+	case errors.Is(err, service.ErrInvalidDay):
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, tasqueue.ErrNotFound):
+		respondError(w, http.StatusNotFound, err.Error())
+		return
+	case errors.Is(err, errors.ErrUnsupported):
+		respondError(w, http.StatusNotImplemented, err.Error())
+		return
+	case err != nil:
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, job)
+}
+
+// labelEscaper escapes Prometheus label values
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// GetMetrics handles GET /metrics, reporting the retention janitor's counts
+// in the Prometheus text format
+func (h *Handler) GetMetrics(w http.ResponseWriter, r *http.Request) {
+	st := h.service.Retention()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	fmt.Fprintln(w, "# HELP tasqueue_ui_retention_deleted_jobs_total Finished jobs deleted by retention policies, or found expired in a dry run.")
+	fmt.Fprintln(w, "# TYPE tasqueue_ui_retention_deleted_jobs_total counter")
+	for _, c := range st.Deleted {
+		fmt.Fprintf(w, "tasqueue_ui_retention_deleted_jobs_total{status=\"%s\",task=\"%s\",dry_run=\"%t\"} %d\n",
+			labelEscaper.Replace(c.Status), labelEscaper.Replace(c.Task), st.DryRun, c.Jobs)
+	}
+
+	fmt.Fprintln(w, "# HELP tasqueue_ui_retention_runs_total Retention passes by result; skipped passes were left to another replica.")
+	fmt.Fprintln(w, "# TYPE tasqueue_ui_retention_runs_total counter")
+	fmt.Fprintf(w, "tasqueue_ui_retention_runs_total{result=\"ok\"} %d\n", st.Runs-st.Errors)
+	fmt.Fprintf(w, "tasqueue_ui_retention_runs_total{result=\"error\"} %d\n", st.Errors)
+	fmt.Fprintf(w, "tasqueue_ui_retention_runs_total{result=\"skipped\"} %d\n", st.Skipped)
+
+	if st.LastRun != nil {
+		fmt.Fprintln(w, "# HELP tasqueue_ui_retention_last_run_timestamp_seconds When this replica's last retention pass finished.")
+		fmt.Fprintln(w, "# TYPE tasqueue_ui_retention_last_run_timestamp_seconds gauge")
+		fmt.Fprintf(w, "tasqueue_ui_retention_last_run_timestamp_seconds %.3f\n", float64(st.LastRun.FinishedAt.UnixMilli())/1000)
+		fmt.Fprintln(w, "# HELP tasqueue_ui_retention_last_run_deleted_jobs Jobs deleted by this replica's last retention pass.")
+		fmt.Fprintln(w, "# TYPE tasqueue_ui_retention_last_run_deleted_jobs gauge")
+		fmt.Fprintf(w, "tasqueue_ui_retention_last_run_deleted_jobs %d\n", st.LastRun.Deleted)
+	}
+}
+
+// userHeader carries the user authenticated by a proxy in front of the UI.
+// When set it's recorded as the creator of silences, in place of the one
+// in the request body.
+const userHeader = "X-Forwarded-User"
+
+// maxSilenceBody caps the size of silence request bodies
+const maxSilenceBody = 64 << 10
+
+// ListSilences handles GET /api/silences
+func (h *Handler) ListSilences(w http.ResponseWriter, r *http.Request) {
+	silences, err := h.service.ListSilences(r.Context())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"silences": silences,
+		"count":    len(silences),
+	})
+}
+
+// CreateSilence handles POST /api/silences
+func (h *Handler) CreateSilence(w http.ResponseWriter, r *http.Request) {
+	var sl service.Silence
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSilenceBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&sl); err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("invalid silence: %v", err))
+		return
+	}
+	sl.CreatedBySource = service.CreatedByRequest
+	if user := r.Header.Get(userHeader); user != "" {
+		sl.CreatedBy, sl.CreatedBySource = user, service.CreatedByProxy
+	}
+
+	sl, err := h.service.CreateSilence(r.Context(), sl)
+	switch {
+	case errors.Is(err, service.ErrInvalidSilence):
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, service.ErrProxyUserRequired):
+		respondError(w, http.StatusForbidden, err.Error())
+		return
+	case err != nil:
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, sl)
+}
+
+// GetSilence handles GET /api/silences/{id}
+func (h *Handler) GetSilence(w http.ResponseWriter, r *http.Request) {
+	sl, err := h.service.GetSilence(r.Context(), r.PathValue("id"))
+	switch {
+	case errors.Is(err, service.ErrSilenceNotFound):
+		respondError(w, http.StatusNotFound, err.Error())
+		return
+	case err != nil:
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, sl)
+}
+
+// ExpireSilence handles DELETE /api/silences/{id}. The silence ends now but
+// is still listed until it ages out.
+func (h *Handler) ExpireSilence(w http.ResponseWriter, r *http.Request) {
+	by, source := "", service.CreatedByRequest
+	if user := r.Header.Get(userHeader); user != "" {
+		by, source = user, service.CreatedByProxy
+	}
+
+	sl, err := h.service.ExpireSilence(r.Context(), r.PathValue("id"), by, source)
+	switch {
+	case errors.Is(err, service.ErrSilenceNotFound):
+		respondError(w, http.StatusNotFound, err.Error())
+		return
+	case errors.Is(err, service.ErrProxyUserRequired):
+		respondError(w, http.StatusForbidden, err.Error())
+		return
+	case err != nil:
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, sl)
+}
+
+// HealthCheck handles GET /health. It is a liveness check and doesn't
+// touch the backends; use /ready for that.
 func (h *Handler) HealthCheck(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusOK, map[string]string{"status": "healthy"})
 }
+
+// ReadyCheck handles GET /ready, responding 503 when any backend is down
+func (h *Handler) ReadyCheck(w http.ResponseWriter, r *http.Request) {
+	report := h.service.CheckReadiness(r.Context())
+
+	status := http.StatusOK
+	if !report.Ready {
+		status = http.StatusServiceUnavailable
+	}
+
+	respondJSON(w, status, report)
+}
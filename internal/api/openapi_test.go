@@ -0,0 +1,165 @@
+package api
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// openAPIDoc is the subset of the OpenAPI document the tests inspect
+type openAPIDoc struct {
+	Paths map[string]map[string]json.RawMessage `json:"paths"`
+}
+
+// openAPIParam is a path item or operation parameter, possibly a $ref
+type openAPIParam struct {
+	Ref  string `json:"$ref"`
+	Name string `json:"name"`
+	In   string `json:"in"`
+}
+
+var pathParamRe = regexp.MustCompile(`\{([^}]+)\}`)
+
+func loadSpec(t *testing.T) (openAPIDoc, map[string]any) {
+	t.Helper()
+
+	var doc openAPIDoc
+	if err := json.Unmarshal(openAPISpec, &doc); err != nil {
+		t.Fatalf("openapi.json is not valid JSON: %v", err)
+	}
+	var raw map[string]any
+	if err := json.Unmarshal(openAPISpec, &raw); err != nil {
+		t.Fatalf("openapi.json is not valid JSON: %v", err)
+	}
+
+	return doc, raw
+}
+
+// splitPattern splits a ServeMux pattern into its method and path
+func splitPattern(t *testing.T, pattern string) (string, string) {
+	t.Helper()
+
+	method, path, ok := strings.Cut(pattern, " ")
+	if !ok {
+		t.Fatalf("route %q has no method; every API route must be method-specific", pattern)
+	}
+
+	return strings.ToLower(method), path
+}
+
+func TestRoutesDocumented(t *testing.T) {
+	doc, raw := loadSpec(t)
+
+	registered := map[string]bool{}
+	for _, rt := range apiRoutes(&Handler{}) {
+		method, path := splitPattern(t, rt.pattern)
+		registered[method+" "+path] = true
+
+		item, ok := doc.Paths[path]
+		if !ok {
+			t.Errorf("route %q is not documented: openapi.json has no path %q", rt.pattern, path)
+			continue
+		}
+		op, ok := item[method]
+		if !ok {
+			t.Errorf("route %q is not documented: path %q has no %s operation", rt.pattern, path, method)
+			continue
+		}
+
+		// Every wildcard in the pattern must be a documented path parameter
+		var (
+			params    []openAPIParam
+			opDetails struct {
+				Parameters []openAPIParam `json:"parameters"`
+			}
+		)
+		if p, ok := item["parameters"]; ok {
+			json.Unmarshal(p, &params)
+		}
+		json.Unmarshal(op, &opDetails)
+		params = append(params, opDetails.Parameters...)
+
+		declared := map[string]bool{}
+		for _, p := range params {
+			if p.Ref != "" {
+				resolved, ok := resolveRef(raw, p.Ref).(map[string]any)
+				if !ok {
+					continue
+				}
+				p.Name, _ = resolved["name"].(string)
+				p.In, _ = resolved["in"].(string)
+			}
+			if p.In == "path" {
+				declared[p.Name] = true
+			}
+		}
+		for _, m := range pathParamRe.FindAllStringSubmatch(path, -1) {
+			if !declared[m[1]] {
+				t.Errorf("route %q: path parameter %q is not documented", rt.pattern, m[1])
+			}
+		}
+	}
+
+	// Documented operations must exist too, so the spec doesn't go stale
+	for path, item := range doc.Paths {
+		for method := range item {
+			if method == "parameters" {
+				continue
+			}
+			if !registered[method+" "+path] {
+				t.Errorf("openapi.json documents %s %s, which is not a registered route", strings.ToUpper(method), path)
+			}
+		}
+	}
+}
+
+func TestOpenAPISchemas(t *testing.T) {
+	_, raw := loadSpec(t)
+
+	for _, name := range []string{
+		"DashboardStats", "JobDetail", "ChainDetail", "GroupDetail",
+		"SearchResult", "PendingJobsResult", "ErrorResponse",
+	} {
+		if resolveRef(raw, "#/components/schemas/"+name) == nil {
+			t.Errorf("schema %s is not documented", name)
+		}
+	}
+
+	// Every $ref must point at an existing component
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			if ref, ok := v["$ref"].(string); ok && resolveRef(raw, ref) == nil {
+				t.Errorf("unresolved $ref %q", ref)
+			}
+			for _, child := range v {
+				walk(child)
+			}
+		case []any:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(raw)
+}
+
+// resolveRef looks up a local JSON pointer such as #/components/schemas/Job
+func resolveRef(doc map[string]any, ref string) any {
+	if !strings.HasPrefix(ref, "#/") {
+		return nil
+	}
+
+	var cur any = doc
+	for _, key := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return nil
+		}
+		cur = m[key]
+	}
+
+	return cur
+}
@@ -4,29 +4,88 @@ import (
 	"embed"
 	"io/fs"
 	"log"
+	"log/slog"
 	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/kalbhor/tasqueue-ui/internal/logging"
 )
 
+// RequestIDHeader carries the request ID in requests and responses
+const RequestIDHeader = "X-Request-ID"
+
+// route binds a ServeMux pattern to its handler
+type route struct {
+	pattern string
+	handler http.HandlerFunc
+}
+
+// apiRoutes returns every route other than the static file server.
+// Each one must be documented in openapi.json.
+func apiRoutes(h *Handler) []route {
+	return []route{
+		// Liveness and readiness checks
+		{"GET /health", h.HealthCheck},
+		{"GET /ready", h.ReadyCheck},
+
+		// Prometheus metrics
+		{"GET /metrics", h.GetMetrics},
+
+		// API documentation
+		{"GET /api/openapi.json", h.GetOpenAPI},
+		{"GET /api/docs", h.GetDocs},
+
+		// API routes
+		{"GET /api/stats", h.GetDashboardStats},
+		{"GET /api/search", h.Search},
+		{"GET /api/jobs/export", h.ExportJobs},
+		{"POST /api/jobs/import", h.ImportJobs},
+		{"GET /api/jobs/{id}", h.GetJob},
+		{"GET /api/jobs/pending/{queue}/paginated", h.GetPendingJobsPaginated},
+		{"GET /api/jobs/pending/{queue}/count", h.GetPendingCount},
+		{"GET /api/jobs/pending/{queue}", h.GetPendingJobs},
+		{"GET /api/jobs", h.GetJobsByStatus},
+		{"DELETE /api/jobs", h.PurgeJobs},
+		{"DELETE /api/jobs/{id}", h.DeleteJob},
+		{"POST /api/jobs/{id}/retry", h.RetryJob},
+		{"POST /api/jobs/{id}/cancel", h.CancelJob},
+		{"GET /api/chains/{id}", h.GetChain},
+		{"POST /api/chains/{id}/resume", h.ResumeChain},
+		{"GET /api/chains", h.ListChains},
+		{"GET /api/groups/{id}", h.GetGroup},
+		{"POST /api/groups/{id}/rerun", h.RerunGroup},
+		{"GET /api/groups", h.ListGroups},
+		{"GET /api/graph/{id}", h.GetGraph},
+		{"GET /api/queues", h.ListQueues},
+		{"GET /api/tasks", h.ListTasks},
+		{"GET /api/tasks/{name}", h.GetTask},
+		{"GET /api/catalog", h.ListCatalog},
+		{"GET /api/catalog/{name}", h.GetCatalogTask},
+		{"GET /api/workers", h.ListWorkers},
+		{"GET /api/alerts", h.GetAlerts},
+		{"GET /api/retention", h.GetRetention},
+		{"GET /api/archive", h.ListArchive},
+		{"GET /api/archive/{day}/jobs/{id}", h.GetArchivedJob},
+		{"GET /api/silences", h.ListSilences},
+		{"POST /api/silences", h.CreateSilence},
+		{"GET /api/silences/{id}", h.GetSilence},
+		{"DELETE /api/silences/{id}", h.ExpireSilence},
+	}
+}
+
 // SetupRoutes configures all HTTP routes
 func SetupRoutes(h *Handler, staticFS embed.FS) *http.ServeMux {
 	mux := http.NewServeMux()
 
-	// Health check
-	mux.HandleFunc("GET /health", h.HealthCheck)
-
-	// API routes
-	mux.HandleFunc("GET /api/stats", h.GetDashboardStats)
-	mux.HandleFunc("GET /api/search", h.Search)
-	mux.HandleFunc("GET /api/jobs/{id}", h.GetJob)
-	mux.HandleFunc("GET /api/jobs/pending/{queue}/paginated", h.GetPendingJobsPaginated)
-	mux.HandleFunc("GET /api/jobs/pending/{queue}/count", h.GetPendingCount)
-	mux.HandleFunc("GET /api/jobs/pending/{queue}", h.GetPendingJobs)
-	mux.HandleFunc("GET /api/jobs", h.GetJobsByStatus)
-	mux.HandleFunc("DELETE /api/jobs/{id}", h.DeleteJob)
-	mux.HandleFunc("GET /api/chains/{id}", h.GetChain)
-	mux.HandleFunc("GET /api/chains", h.ListChains)
-	mux.HandleFunc("GET /api/groups/{id}", h.GetGroup)
-	mux.HandleFunc("GET /api/groups", h.ListGroups)
+	for _, rt := range apiRoutes(h) {
+		mux.HandleFunc(rt.pattern, rt.handler)
+	}
 
 	// Serve static files and index.html
 	staticSub, err := fs.Sub(staticFS, "web")
@@ -45,7 +104,8 @@ func CORSMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, traceparent, tracestate, "+RequestIDHeader)
+		w.Header().Set("Access-Control-Expose-Headers", RequestIDHeader)
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -56,10 +116,107 @@ func CORSMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-// LoggingMiddleware logs HTTP requests
+// LoggingMiddleware assigns every request an ID and writes a structured
+// access log entry once the request has been served. The ID is taken from
+// the X-Request-ID request header when present, echoed in the response
+// header and carried in the request context.
 func LoggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("%s %s %s", r.Method, r.URL.Path, r.RemoteAddr)
-		next.ServeHTTP(w, r)
+		start := time.Now()
+
+		id := r.Header.Get(RequestIDHeader)
+		if id == "" || len(id) > 128 {
+			id = logging.NewRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+
+		ctx := logging.WithRequestID(r.Context(), id)
+		rw := &responseWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rw, r.WithContext(ctx))
+
+		level := slog.LevelInfo
+		switch {
+		case rw.status >= 500:
+			level = slog.LevelError
+		case rw.status >= 400:
+			level = slog.LevelWarn
+		}
+
+		slog.LogAttrs(ctx, level, "http request",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("query", r.URL.RawQuery),
+			slog.Int("status", rw.status),
+			slog.Int64("bytes", rw.bytes),
+			slog.Duration("duration", time.Since(start)),
+			slog.String("remote_addr", r.RemoteAddr),
+			slog.String("user_agent", r.UserAgent()),
+		)
 	})
 }
+
+// TracingMiddleware starts a server span for every request, continuing any
+// trace propagated by the caller. It must wrap the mux directly so the span
+// can be named after the matched route pattern.
+func TracingMiddleware(next http.Handler) http.Handler {
+	tracer := otel.Tracer("github.com/kalbhor/tasqueue-ui/internal/api")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer.Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("url.path", r.URL.Path),
+				attribute.String("user_agent.original", r.UserAgent()),
+			),
+		)
+		defer span.End()
+
+		if id := logging.RequestID(ctx); id != "" {
+			span.SetAttributes(attribute.String("http.request.id", id))
+		}
+
+		rw := &responseWriter{ResponseWriter: w, status: http.StatusOK}
+		r = r.WithContext(ctx)
+		next.ServeHTTP(rw, r)
+
+		// The pattern is only known once the mux has routed the request
+		if r.Pattern != "" {
+			span.SetName(r.Pattern)
+			span.SetAttributes(attribute.String("http.route", r.Pattern))
+		}
+		span.SetAttributes(attribute.Int("http.response.status_code", rw.status))
+		if rw.status >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(rw.status))
+		}
+	})
+}
+
+// responseWriter records the status code and size of a response
+type responseWriter struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
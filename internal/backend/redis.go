@@ -0,0 +1,116 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/kalbhor/tasqueue-ui/internal/config"
+)
+
+// scanCount is the COUNT hint passed to every SCAN call
+const scanCount = 1000
+
+// NewRedisClient creates a Redis client for the configured topology.
+// Sentinel mode is selected when a master name is set, cluster mode when
+// Cluster is enabled, and a single-node client otherwise.
+func NewRedisClient(cfg config.RedisConfig) (redis.UniversalClient, error) {
+	if len(cfg.Addrs) == 0 {
+		return nil, fmt.Errorf("at least one redis address is required")
+	}
+
+	password := cfg.Password
+	if cfg.PasswordFile != "" {
+		b, err := os.ReadFile(cfg.PasswordFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read redis password file: %w", err)
+		}
+		password = strings.TrimRight(string(b), "\r\n")
+	}
+
+	tlsCfg, err := NewTLSConfig(cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &redis.UniversalOptions{
+		Addrs:      cfg.Addrs,
+		Username:   cfg.Username,
+		Password:   password,
+		DB:         cfg.DB,
+		MasterName: cfg.MasterName,
+		TLSConfig:  tlsCfg,
+	}
+
+	switch {
+	case cfg.Cluster:
+		return redis.NewClusterClient(opts.Cluster()), nil
+	case cfg.MasterName != "":
+		return redis.NewFailoverClient(opts.Failover()), nil
+	default:
+		return redis.NewClient(opts.Simple()), nil
+	}
+}
+
+// ScanKeys returns every key matching pattern. When keyType is non-empty
+// only keys of that Redis type are returned. In cluster mode every master
+// node is scanned, since SCAN only iterates the keyspace of a single node.
+func ScanKeys(ctx context.Context, rdb redis.UniversalClient, pattern, keyType string) ([]string, error) {
+	cluster, ok := rdb.(*redis.ClusterClient)
+	if !ok {
+		return scanNode(ctx, rdb, pattern, keyType)
+	}
+
+	var (
+		mu   sync.Mutex
+		keys []string
+	)
+	err := cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+		nodeKeys, err := scanNode(ctx, node, pattern, keyType)
+		if err != nil {
+			return fmt.Errorf("failed to scan node %s: %w", node.Options().Addr, err)
+		}
+
+		mu.Lock()
+		keys = append(keys, nodeKeys...)
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// scanNode iterates the keyspace of a single Redis node
+func scanNode(ctx context.Context, c redis.Cmdable, pattern, keyType string) ([]string, error) {
+	var (
+		keys   []string
+		cursor uint64
+	)
+
+	for {
+		var cmd *redis.ScanCmd
+		if keyType != "" {
+			cmd = c.ScanType(ctx, cursor, pattern, scanCount, keyType)
+		} else {
+			cmd = c.Scan(ctx, cursor, pattern, scanCount)
+		}
+
+		page, next, err := cmd.Result()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, page...)
+
+		if next == 0 {
+			return keys, nil
+		}
+		cursor = next
+	}
+}
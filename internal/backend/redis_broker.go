@@ -0,0 +1,213 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	// pollPeriod is how long Consume blocks waiting for a job
+	pollPeriod = time.Second
+
+	// scheduledKey holds jobs with an ETA, matching tasqueue's Redis broker
+	scheduledKey = "tasqueue:ss:%s"
+
+	// maxPageSize caps the number of jobs returned by a single page
+	maxPageSize = 10000
+)
+
+// RedisBroker is a tasqueue.Broker backed by an existing Redis client.
+// It uses the same key layout as tasqueue's own Redis broker, so it can
+// read and write queues shared with workers, but unlike the upstream broker
+// it works with sentinel and cluster clients.
+type RedisBroker struct {
+	conn redis.UniversalClient
+	lo   *slog.Logger
+}
+
+// NewRedisBroker creates a broker on top of the given client
+func NewRedisBroker(conn redis.UniversalClient, lo *slog.Logger) *RedisBroker {
+	return &RedisBroker{
+		conn: conn,
+		lo:   lo,
+	}
+}
+
+// Enqueue pushes a job message onto the queue
+func (b *RedisBroker) Enqueue(ctx context.Context, msg []byte, queue string) error {
+	return b.conn.LPush(ctx, queue, msg).Err()
+}
+
+// EnqueueScheduled adds a job message to the queue's scheduled set
+func (b *RedisBroker) EnqueueScheduled(ctx context.Context, msg []byte, queue string, ts time.Time) error {
+	return b.conn.ZAdd(ctx, fmt.Sprintf(scheduledKey, queue), &redis.Z{
+		Score:  float64(ts.UnixNano()),
+		Member: msg,
+	}).Err()
+}
+
+// Consume pops job messages off the queue until ctx is cancelled.
+// The UI never consumes jobs itself; this exists to satisfy tasqueue.Broker.
+func (b *RedisBroker) Consume(ctx context.Context, work chan []byte, queue string) {
+	defer close(work)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+			res, err := b.conn.BLPop(ctx, pollPeriod, queue).Result()
+			if errors.Is(err, redis.Nil) {
+				continue
+			}
+			if err != nil {
+				b.lo.Error("error consuming from redis queue", "error", err)
+				continue
+			}
+			if len(res) != 2 {
+				b.lo.Error("unexpected BLPOP result", "result", res)
+				continue
+			}
+			work <- []byte(res[1])
+		}
+	}
+}
+
+// GetPending returns every job message in the queue
+func (b *RedisBroker) GetPending(ctx context.Context, queue string) ([]string, error) {
+	rs, err := b.conn.LRange(ctx, queue, 0, -1).Result()
+	if errors.Is(err, redis.Nil) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return rs, nil
+}
+
+// GetPendingWithPagination returns a page of job messages and the queue length
+func (b *RedisBroker) GetPendingWithPagination(ctx context.Context, queue string, offset, limit int) ([]string, int64, error) {
+	total, err := b.conn.LLen(ctx, queue).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if offset < 0 {
+		offset = 0
+	}
+	if total == 0 || int64(offset) >= total {
+		return []string{}, total, nil
+	}
+	if limit <= 0 {
+		limit = 100
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+
+	rs, err := b.conn.LRange(ctx, queue, int64(offset), int64(offset+limit-1)).Result()
+	if errors.Is(err, redis.Nil) {
+		return []string{}, total, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return rs, total, nil
+}
+
+// GetPendingCount returns the number of job messages in the queue
+func (b *RedisBroker) GetPendingCount(ctx context.Context, queue string) (int64, error) {
+	return b.conn.LLen(ctx, queue).Result()
+}
+
+// RemovePending removes the first job message matching match from a queue,
+// or failing that its scheduled set, and reports whether one was removed.
+// A message that is found but gone by the time it is removed was consumed
+// in between, and isn't reported as removed.
+//
+// Jobs are pushed and popped at the head of the queue, and the scheduler
+// takes jobs from the low end of the scheduled set, so both are read from
+// the other end for positions to stay put while they are paged through.
+func RemovePending(ctx context.Context, conn redis.UniversalClient, queue string, match func(msg string) bool) (bool, error) {
+	for end := int64(-1); ; end -= scanCount {
+		msgs, err := conn.LRange(ctx, queue, end-scanCount+1, end).Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return false, fmt.Errorf("failed to read queue %s: %w", queue, err)
+		}
+		for _, msg := range msgs {
+			if match(msg) {
+				n, err := conn.LRem(ctx, queue, 1, msg).Result()
+				if err != nil {
+					return false, fmt.Errorf("failed to remove job from queue %s: %w", queue, err)
+				}
+				return n > 0, nil
+			}
+		}
+		if len(msgs) < scanCount {
+			break
+		}
+	}
+
+	key := fmt.Sprintf(scheduledKey, queue)
+	for start := int64(0); ; start += scanCount {
+		msgs, err := conn.ZRevRange(ctx, key, start, start+scanCount-1).Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return false, fmt.Errorf("failed to read scheduled jobs of queue %s: %w", queue, err)
+		}
+		for _, msg := range msgs {
+			if match(msg) {
+				n, err := conn.ZRem(ctx, key, msg).Result()
+				if err != nil {
+					return false, fmt.Errorf("failed to remove scheduled job of queue %s: %w", queue, err)
+				}
+				return n > 0, nil
+			}
+		}
+		if len(msgs) < scanCount {
+			return false, nil
+		}
+	}
+}
+
+// PendingMessages pages through the job messages waiting in a queue, oldest
+// first, calling fn with at most batch messages at a time. As with
+// RemovePending the queue is read from the end jobs aren't pushed or popped
+// at, so jobs enqueued or consumed during the walk don't shift pages: no
+// message is read twice, and the walk stops after as many messages as the
+// queue held when it started. Only a job removed from the middle of the
+// queue, such as a cancelled one, can make the walk miss the job next to it.
+func PendingMessages(ctx context.Context, conn redis.UniversalClient, queue string, batch int, fn func(msgs []string) error) error {
+	total, err := conn.LLen(ctx, queue).Result()
+	if err != nil {
+		return fmt.Errorf("failed to get length of queue %s: %w", queue, err)
+	}
+
+	for end, read := int64(-1), int64(0); read < total; end -= int64(batch) {
+		msgs, err := conn.LRange(ctx, queue, max(end-int64(batch)+1, -total), end).Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return fmt.Errorf("failed to read queue %s: %w", queue, err)
+		}
+		// LRANGE returns the page head first
+		for i, j := 0, len(msgs)-1; i < j; i, j = i+1, j-1 {
+			msgs[i], msgs[j] = msgs[j], msgs[i]
+		}
+		if len(msgs) > 0 {
+			if err := fn(msgs); err != nil {
+				return err
+			}
+		}
+		if len(msgs) < batch {
+			return nil
+		}
+		read += int64(len(msgs))
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestPendingMessages(t *testing.T) {
+	ctx := context.Background()
+
+	// Jobs are pushed at the head, so "a" is the oldest
+	queued := []string{"a", "b", "c", "d", "e"}
+
+	for _, batch := range []int{1, 2, 3, 5, 10} {
+		t.Run(fmt.Sprintf("batch of %d", batch), func(t *testing.T) {
+			conn := newTestRedis(t)
+			for _, m := range queued {
+				if err := conn.LPush(ctx, "q", m).Err(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var got []string
+			err := PendingMessages(ctx, conn, "q", batch, func(msgs []string) error {
+				if len(msgs) > batch {
+					t.Errorf("got a batch of %d messages, want at most %d", len(msgs), batch)
+				}
+				got = append(got, msgs...)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, queued) {
+				t.Errorf("got %v, want %v", got, queued)
+			}
+		})
+	}
+}
+
+// TestPendingMessagesLive checks that jobs pushed and popped at the head
+// while the queue is walked don't make it read a job twice
+func TestPendingMessagesLive(t *testing.T) {
+	ctx := context.Background()
+	conn := newTestRedis(t)
+	for _, m := range []string{"a", "b", "c", "d", "e", "f"} {
+		if err := conn.LPush(ctx, "q", m).Err(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var got []string
+	err := PendingMessages(ctx, conn, "q", 2, func(msgs []string) error {
+		got = append(got, msgs...)
+		if len(got) == 2 {
+			// A worker takes the newest job and another is enqueued
+			if err := conn.LPop(ctx, "q").Err(); err != nil {
+				return err
+			}
+			return conn.LPush(ctx, "q", "g").Err()
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a", "b", "c", "d", "e", "g"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestPendingMessagesEmpty(t *testing.T) {
+	conn := newTestRedis(t)
+
+	err := PendingMessages(context.Background(), conn, "q", 10, func(msgs []string) error {
+		t.Errorf("got messages from an empty queue: %v", msgs)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,15 @@
+package backend
+
+import (
+	"context"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/kalbhor/tasqueue-ui/worker"
+)
+
+// GetCatalog returns the task descriptions published by workers, as JSON
+// by task name
+func GetCatalog(ctx context.Context, conn redis.UniversalClient) (map[string]string, error) {
+	return conn.HGetAll(ctx, worker.CatalogKey).Result()
+}
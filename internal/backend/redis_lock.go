@@ -0,0 +1,46 @@
+package backend
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// lockPrefix prefixes the keys of locks shared by UI replicas
+const lockPrefix = "tq:ui:lock:"
+
+// Scripts that only touch a lock while it still holds the caller's token,
+// so a lock that expired and was taken by another replica is left alone
+var (
+	extendLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+	releaseLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+)
+
+// AcquireLock takes the named lock for ttl if no one holds it, and reports
+// whether it was taken. token identifies the holder to ExtendLock and
+// ReleaseLock.
+func AcquireLock(ctx context.Context, conn redis.UniversalClient, name, token string, ttl time.Duration) (bool, error) {
+	return conn.SetNX(ctx, lockPrefix+name, token, ttl).Result()
+}
+
+// ExtendLock resets the expiry of a lock held with token, and reports
+// whether it was still held
+func ExtendLock(ctx context.Context, conn redis.UniversalClient, name, token string, ttl time.Duration) (bool, error) {
+	n, err := extendLockScript.Run(ctx, conn, []string{lockPrefix + name}, token, ttl.Milliseconds()).Int()
+	return n == 1, err
+}
+
+// ReleaseLock releases a lock held with token
+func ReleaseLock(ctx context.Context, conn redis.UniversalClient, name, token string) error {
+	return releaseLockScript.Run(ctx, conn, []string{lockPrefix + name}, token).Err()
+}
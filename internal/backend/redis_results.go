@@ -0,0 +1,323 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/kalbhor/tasqueue-ui/worker"
+)
+
+const (
+	// ResultPrefix is prepended to every key tasqueue stores in the results backend
+	ResultPrefix = "tq:res:"
+
+	// Keys of the sorted sets holding successful and failed job IDs
+	successKey = ResultPrefix + "success"
+	failedKey  = ResultPrefix + "failed"
+
+	// jobMsgPrefix prefixes the keys of job messages
+	jobMsgPrefix = ResultPrefix + "job:msg:"
+
+	// Task metadata keys
+	taskPrefix = "tq:task:"
+	tasksKey   = "tq:tasks"
+)
+
+// RedisResults is a tasqueue.Results store backed by an existing Redis client.
+// It uses the same key layout as tasqueue's own Redis results store.
+type RedisResults struct {
+	conn redis.UniversalClient
+	lo   *slog.Logger
+}
+
+// NewRedisResults creates a results store on top of the given client
+func NewRedisResults(conn redis.UniversalClient, lo *slog.Logger) *RedisResults {
+	return &RedisResults{
+		conn: conn,
+		lo:   lo,
+	}
+}
+
+// Get returns the value stored for id
+func (r *RedisResults) Get(ctx context.Context, id string) ([]byte, error) {
+	return r.conn.Get(ctx, ResultPrefix+id).Bytes()
+}
+
+// NilError returns the error Get reports for missing keys
+func (r *RedisResults) NilError() error {
+	return redis.Nil
+}
+
+// Set stores b against id
+func (r *RedisResults) Set(ctx context.Context, id string, b []byte) error {
+	return r.conn.Set(ctx, ResultPrefix+id, b, 0).Err()
+}
+
+// DeleteJob removes the job's result, its timing and its success/failed markers
+func (r *RedisResults) DeleteJob(ctx context.Context, id string) error {
+	r.lo.Debug("deleting job", "id", id)
+
+	pipe := r.conn.Pipeline()
+	pipe.ZRem(ctx, successKey, id)
+	pipe.ZRem(ctx, failedKey, id)
+	pipe.Del(ctx, ResultPrefix+id)
+	pipe.Del(ctx, ResultPrefix+worker.TimingKey(id))
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// GetSuccess returns the IDs of successful jobs, newest first
+func (r *RedisResults) GetSuccess(ctx context.Context) ([]string, error) {
+	return r.statusIDs(ctx, successKey)
+}
+
+// GetFailed returns the IDs of failed jobs, newest first
+func (r *RedisResults) GetFailed(ctx context.Context) ([]string, error) {
+	return r.statusIDs(ctx, failedKey)
+}
+
+// SetSuccess marks a job as successful
+func (r *RedisResults) SetSuccess(ctx context.Context, id string) error {
+	return r.conn.ZAdd(ctx, successKey, &redis.Z{
+		Score:  float64(time.Now().UnixNano()),
+		Member: id,
+	}).Err()
+}
+
+// SetFailed marks a job as failed
+func (r *RedisResults) SetFailed(ctx context.Context, id string) error {
+	return r.conn.ZAdd(ctx, failedKey, &redis.Z{
+		Score:  float64(time.Now().UnixNano()),
+		Member: id,
+	}).Err()
+}
+
+// SetTask stores task metadata
+func (r *RedisResults) SetTask(ctx context.Context, name string, task []byte) error {
+	r.lo.Debug("setting task metadata", "name", name)
+
+	pipe := r.conn.Pipeline()
+	pipe.Set(ctx, taskPrefix+name, task, 0)
+	pipe.SAdd(ctx, tasksKey, name)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// GetTask returns the metadata of a single task
+func (r *RedisResults) GetTask(ctx context.Context, name string) ([]byte, error) {
+	return r.conn.Get(ctx, taskPrefix+name).Bytes()
+}
+
+// GetAllTasks returns the metadata of every registered task.
+// The keys are fetched with a pipeline rather than MGET, since task keys
+// can live in different hash slots in cluster mode.
+func (r *RedisResults) GetAllTasks(ctx context.Context) ([][]byte, error) {
+	names, err := r.conn.SMembers(ctx, tasksKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return [][]byte{}, nil
+	}
+
+	pipe := r.conn.Pipeline()
+	cmds := make([]*redis.StringCmd, len(names))
+	for i, name := range names {
+		cmds[i] = pipe.Get(ctx, taskPrefix+name)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	tasks := make([][]byte, 0, len(cmds))
+	for _, cmd := range cmds {
+		b, err := cmd.Bytes()
+		if err != nil {
+			continue
+		}
+		tasks = append(tasks, b)
+	}
+
+	return tasks, nil
+}
+
+// DeleteTask removes task metadata
+func (r *RedisResults) DeleteTask(ctx context.Context, name string) error {
+	pipe := r.conn.Pipeline()
+	pipe.Del(ctx, taskPrefix+name)
+	pipe.SRem(ctx, tasksKey, name)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// statusIDs returns the members of a status set scored up to now
+func (r *RedisResults) statusIDs(ctx context.Context, key string) ([]string, error) {
+	return r.conn.ZRevRangeByScore(ctx, key, &redis.ZRangeBy{
+		Min: "0",
+		Max: strconv.FormatInt(time.Now().UnixNano(), 10),
+	}).Result()
+}
+
+// StatusIDs pages through the IDs of successful or failed jobs marked at or
+// after since, newest first, calling fn with at most batch IDs at a time so
+// the whole set is never held in memory. Scores are walked with a cursor
+// rather than an offset, so jobs marked during the walk don't shift pages.
+func StatusIDs(ctx context.Context, conn redis.UniversalClient, status string, since time.Time, batch int, fn func(ids []string) error) error {
+	key, err := statusKey(status)
+	if err != nil {
+		return err
+	}
+
+	min := "0"
+	if !since.IsZero() {
+		min = strconv.FormatInt(since.UnixNano(), 10)
+	}
+	max := strconv.FormatInt(time.Now().UnixNano(), 10)
+
+	// skip counts the members already returned with the last score; scores
+	// are float64 nanoseconds, so jobs marked close together can tie
+	var skip int64
+	for {
+		zs, err := conn.ZRevRangeByScoreWithScores(ctx, key, &redis.ZRangeBy{
+			Min:    min,
+			Max:    max,
+			Offset: skip,
+			Count:  int64(batch),
+		}).Result()
+		if err != nil {
+			return err
+		}
+		if len(zs) == 0 {
+			return nil
+		}
+
+		ids := make([]string, len(zs))
+		for i, z := range zs {
+			ids[i], _ = z.Member.(string)
+		}
+		if err := fn(ids); err != nil {
+			return err
+		}
+		if len(zs) < batch {
+			return nil
+		}
+
+		last := zs[len(zs)-1].Score
+		if next := strconv.FormatFloat(last, 'f', -1, 64); next == max {
+			skip += int64(len(zs))
+		} else {
+			max, skip = next, 0
+			for i := len(zs) - 1; i >= 0 && zs[i].Score == last; i-- {
+				skip++
+			}
+		}
+	}
+}
+
+// GetResults returns the values of several keys of the results store,
+// given without ResultPrefix, in one round trip. Missing keys are nil.
+func GetResults(ctx context.Context, conn redis.UniversalClient, keys []string) ([][]byte, error) {
+	pipe := conn.Pipeline()
+	cmds := make([]*redis.StringCmd, len(keys))
+	for i, k := range keys {
+		cmds[i] = pipe.Get(ctx, ResultPrefix+k)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	vals := make([][]byte, len(cmds))
+	for i, cmd := range cmds {
+		b, err := cmd.Bytes()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return nil, err
+		}
+		vals[i] = b
+	}
+	return vals, nil
+}
+
+// StatusAfter returns up to limit successful or failed jobs marked at or
+// after the given score, oldest first, with their scores. Scores are Unix
+// nanoseconds.
+func StatusAfter(ctx context.Context, conn redis.UniversalClient, status string, min float64, limit int) ([]redis.Z, error) {
+	key, err := statusKey(status)
+	if err != nil {
+		return nil, err
+	}
+
+	return conn.ZRangeByScoreWithScores(ctx, key, &redis.ZRangeBy{
+		Min:   strconv.FormatFloat(min, 'f', -1, 64),
+		Max:   "+inf",
+		Count: int64(limit),
+	}).Result()
+}
+
+// StatusBetween returns up to limit successful or failed jobs marked from
+// min up to max, oldest first, with their scores, after skipping the first
+// skip of them. Scores are Unix nanoseconds.
+func StatusBetween(ctx context.Context, conn redis.UniversalClient, status string, min, max float64, skip, limit int) ([]redis.Z, error) {
+	key, err := statusKey(status)
+	if err != nil {
+		return nil, err
+	}
+
+	return conn.ZRangeByScoreWithScores(ctx, key, &redis.ZRangeBy{
+		Min:    strconv.FormatFloat(min, 'f', -1, 64),
+		Max:    strconv.FormatFloat(max, 'f', -1, 64),
+		Offset: int64(skip),
+		Count:  int64(limit),
+	}).Result()
+}
+
+// DeleteJobs removes successful or failed jobs in one round trip: their
+// messages, results and timings as well as their status markers
+func DeleteJobs(ctx context.Context, conn redis.UniversalClient, status string, ids []string) error {
+	key, err := statusKey(status)
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	members := make([]interface{}, len(ids))
+	pipe := conn.Pipeline()
+	for i, id := range ids {
+		members[i] = id
+		pipe.Del(ctx, jobMsgPrefix+id)
+		pipe.Del(ctx, ResultPrefix+id)
+		pipe.Del(ctx, ResultPrefix+worker.TimingKey(id))
+	}
+	pipe.ZRem(ctx, key, members...)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// CountStatus returns the number of successful or failed jobs marked at or after since
+func CountStatus(ctx context.Context, conn redis.UniversalClient, status string, since time.Time) (int64, error) {
+	key, err := statusKey(status)
+	if err != nil {
+		return 0, err
+	}
+
+	return conn.ZCount(ctx, key, strconv.FormatInt(since.UnixNano(), 10), "+inf").Result()
+}
+
+// statusKey returns the key of the sorted set holding jobs with a status
+func statusKey(status string) (string, error) {
+	switch status {
+	case "successful":
+		return successKey, nil
+	case "failed":
+		return failedKey, nil
+	default:
+		return "", fmt.Errorf("unsupported status: %s", status)
+	}
+}
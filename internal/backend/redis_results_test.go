@@ -0,0 +1,127 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+// newTestRedis starts an in-memory Redis for the test
+func newTestRedis(t *testing.T) redis.UniversalClient {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	conn := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// markJobs adds jobs to a status set, scored with the given Unix nanoseconds
+func markJobs(t *testing.T, conn redis.UniversalClient, key string, scores map[string]float64) {
+	t.Helper()
+
+	for id, score := range scores {
+		if err := conn.ZAdd(context.Background(), key, &redis.Z{Score: score, Member: id}).Err(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestStatusIDs(t *testing.T) {
+	conn := newTestRedis(t)
+
+	// Five jobs tie, so most batch sizes split them across batches
+	markJobs(t, conn, successKey, map[string]float64{
+		"a": 1000,
+		"b": 2000, "c": 2000, "d": 2000, "e": 2000, "f": 2000,
+		"g": 3000,
+	})
+	markJobs(t, conn, failedKey, map[string]float64{"x": 2000})
+
+	tests := []struct {
+		name  string
+		since time.Time
+		batch int
+		want  []string
+	}{
+		{"batch of 1", time.Time{}, 1, []string{"g", "f", "e", "d", "c", "b", "a"}},
+		{"batch of 2", time.Time{}, 2, []string{"g", "f", "e", "d", "c", "b", "a"}},
+		{"batch of 3", time.Time{}, 3, []string{"g", "f", "e", "d", "c", "b", "a"}},
+		{"batch within ties", time.Time{}, 4, []string{"g", "f", "e", "d", "c", "b", "a"}},
+		{"batch larger than set", time.Time{}, 10, []string{"g", "f", "e", "d", "c", "b", "a"}},
+		{"batch of exactly the set", time.Time{}, 7, []string{"g", "f", "e", "d", "c", "b", "a"}},
+		{"since", time.Unix(0, 2000), 2, []string{"g", "f", "e", "d", "c", "b"}},
+		{"since after every job", time.Unix(0, 4000), 2, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			err := StatusIDs(context.Background(), conn, "successful", tt.since, tt.batch, func(ids []string) error {
+				if len(ids) > tt.batch {
+					t.Errorf("got a batch of %d IDs, want at most %d", len(ids), tt.batch)
+				}
+				got = append(got, ids...)
+				if len(got) > 7 {
+					return fmt.Errorf("walk repeated IDs: %v", got)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatusIDsInvalidStatus(t *testing.T) {
+	conn := newTestRedis(t)
+
+	err := StatusIDs(context.Background(), conn, "pending", time.Time{}, 10, func([]string) error { return nil })
+	if err == nil {
+		t.Error("got no error for an unsupported status")
+	}
+}
+
+func TestStatusBetween(t *testing.T) {
+	conn := newTestRedis(t)
+	markJobs(t, conn, failedKey, map[string]float64{
+		"a": 1000, "b": 2000, "c": 2000, "d": 3000, "e": 4000,
+	})
+
+	tests := []struct {
+		name        string
+		min, max    float64
+		skip, limit int
+		want        []string
+	}{
+		{"inclusive bounds", 2000, 3000, 0, 10, []string{"b", "c", "d"}},
+		{"limit", 0, 4000, 0, 2, []string{"a", "b"}},
+		{"skip ties", 2000, 4000, 1, 2, []string{"c", "d"}},
+		{"skip past the range", 2000, 2000, 2, 10, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			zs, err := StatusBetween(context.Background(), conn, "failed", tt.min, tt.max, tt.skip, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, z := range zs {
+				got = append(got, z.Member.(string))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package backend
+
+import (
+	"context"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// silencesKey is the hash holding alert silences, as JSON by ID. It lives
+// alongside tasqueue's keys but outside their prefix.
+const silencesKey = "tq:ui:silences"
+
+// SetSilence stores a silence
+func SetSilence(ctx context.Context, conn redis.UniversalClient, id string, b []byte) error {
+	return conn.HSet(ctx, silencesKey, id, b).Err()
+}
+
+// GetSilences returns every stored silence by ID
+func GetSilences(ctx context.Context, conn redis.UniversalClient) (map[string]string, error) {
+	return conn.HGetAll(ctx, silencesKey).Result()
+}
+
+// DeleteSilences removes silences
+func DeleteSilences(ctx context.Context, conn redis.UniversalClient, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return conn.HDel(ctx, silencesKey, ids...).Err()
+}
@@ -0,0 +1,41 @@
+package backend
+
+import (
+	"context"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/kalbhor/tasqueue-ui/worker"
+)
+
+// GetWorkers returns the last heartbeat of every worker by ID, and whether
+// each worker's heartbeat key is still live
+func GetWorkers(ctx context.Context, conn redis.UniversalClient) (map[string]string, map[string]bool, error) {
+	workers, err := conn.HGetAll(ctx, worker.WorkersKey).Result()
+	if err != nil || len(workers) == 0 {
+		return workers, nil, err
+	}
+
+	p := conn.Pipeline()
+	cmds := make(map[string]*redis.IntCmd, len(workers))
+	for id := range workers {
+		cmds[id] = p.Exists(ctx, worker.HeartbeatKey(id))
+	}
+	if _, err := p.Exec(ctx); err != nil {
+		return nil, nil, err
+	}
+
+	live := make(map[string]bool, len(cmds))
+	for id, cmd := range cmds {
+		live[id] = cmd.Val() > 0
+	}
+	return workers, live, nil
+}
+
+// DeleteWorkers forgets workers
+func DeleteWorkers(ctx context.Context, conn redis.UniversalClient, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return conn.HDel(ctx, worker.WorkersKey, ids...).Err()
+}
@@ -0,0 +1,278 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"time"
+)
+
+// Alert rule types
+const (
+	AlertPendingDepth     = "pending_depth"      // Pending jobs in a queue above Threshold
+	AlertFailedDelta      = "failed_delta"       // Jobs failed within Window above Threshold
+	AlertOldestPendingAge = "oldest_pending_age" // Oldest pending job in a queue waiting longer than MaxAge
+	AlertChainFailed      = "chain_failed"       // A chain has failed
+	AlertGroupFailed      = "group_failed"       // A group has failed
+)
+
+// AlertingConfig holds the alert rules, the failure notification rules and
+// the webhooks both are sent to. It is read from a JSON file with LoadAlerting.
+type AlertingConfig struct {
+	Interval Duration        `json:"interval"` // How often rules are evaluated and new failures looked for
+	Rules    []AlertRule     `json:"rules"`
+	Failures []FailureRule   `json:"failures"`
+	Webhooks []WebhookConfig `json:"webhooks"`
+
+	// BaseURL is the address the UI is reached at, used to link to jobs
+	// from notifications
+	BaseURL string `json:"base_url,omitempty"`
+}
+
+// AlertRule is a condition that fires an alert
+type AlertRule struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Severity string `json:"severity,omitempty"` // Passed through to webhooks, e.g. warning or critical
+
+	// Queue limits pending rules to one queue; every queue is checked when empty
+	Queue string `json:"queue,omitempty"`
+
+	Threshold float64  `json:"threshold,omitempty"` // For pending_depth and failed_delta
+	Window    Duration `json:"window,omitempty"`    // For failed_delta
+	MaxAge    Duration `json:"max_age,omitempty"`   // For oldest_pending_age
+
+	// For is how long the condition must hold before the alert fires
+	For Duration `json:"for,omitempty"`
+
+	// Webhooks names the webhooks notified; every webhook when empty
+	Webhooks []string `json:"webhooks,omitempty"`
+}
+
+// FailureRule notifies webhooks of every job that fails with a matching
+// task and queue
+type FailureRule struct {
+	Name string `json:"name"`
+
+	// Tasks and Queues are glob patterns such as "billing.*"; a job matches
+	// when its task matches one of Tasks and its queue one of Queues. An
+	// empty list matches everything.
+	Tasks  []string `json:"tasks,omitempty"`
+	Queues []string `json:"queues,omitempty"`
+
+	// Dedup is how long repeats of a failure with the same task, queue and
+	// error are held back after one is notified
+	Dedup Duration `json:"dedup,omitempty"`
+
+	// Webhooks names the webhooks notified; every webhook when empty
+	Webhooks []string `json:"webhooks,omitempty"`
+}
+
+// Matches reports whether a job with the given task and queue matches the rule
+func (r FailureRule) Matches(task, queue string) bool {
+	return matchAny(r.Tasks, task) && matchAny(r.Queues, queue)
+}
+
+// matchAny reports whether v matches one of the glob patterns, or true when
+// there are none
+func matchAny(patterns []string, v string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, v); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookConfig is an endpoint alerts and failure notifications are posted to
+type WebhookConfig struct {
+	Name        string            `json:"name"`
+	URL         string            `json:"url"`
+	Secret      string            `json:"secret,omitempty"`      // Key used to sign each request with HMAC-SHA256
+	SecretFile  string            `json:"secret_file,omitempty"` // File to read the secret from, instead of Secret
+	Headers     map[string]string `json:"headers,omitempty"`
+	Timeout     Duration          `json:"timeout,omitempty"`      // Timeout of each attempt
+	MaxAttempts int               `json:"max_attempts,omitempty"` // Attempts before a delivery is dropped
+	Backoff     Duration          `json:"backoff,omitempty"`      // Delay before the first retry, doubled after each
+}
+
+// Duration is a time.Duration read from JSON as a string such as "30s"
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalJSON parses a duration string
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"30s\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+	return nil
+}
+
+// MarshalJSON formats the duration as a string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// LoadAlerting reads alerting configuration from a JSON file and fills in
+// defaults. Unknown fields are rejected so typos don't silently disable rules.
+func LoadAlerting(path string) (AlertingConfig, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return AlertingConfig{}, fmt.Errorf("failed to read alerting config: %w", err)
+	}
+
+	var cfg AlertingConfig
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return AlertingConfig{}, fmt.Errorf("failed to parse alerting config: %w", err)
+	}
+
+	if cfg.Interval.Duration == 0 {
+		cfg.Interval.Duration = 30 * time.Second
+	}
+	for i := range cfg.Webhooks {
+		wh := &cfg.Webhooks[i]
+		if wh.SecretFile != "" {
+			b, err := os.ReadFile(wh.SecretFile)
+			if err != nil {
+				return AlertingConfig{}, fmt.Errorf("failed to read secret of webhook %s: %w", wh.Name, err)
+			}
+			wh.Secret = string(bytes.TrimRight(b, "\r\n"))
+		}
+		if wh.Timeout.Duration == 0 {
+			wh.Timeout.Duration = 10 * time.Second
+		}
+		if wh.MaxAttempts == 0 {
+			wh.MaxAttempts = 5
+		}
+		if wh.Backoff.Duration == 0 {
+			wh.Backoff.Duration = time.Second
+		}
+	}
+
+	return cfg, nil
+}
+
+// Enabled reports whether any alert or failure rules are configured
+func (c *AlertingConfig) Enabled() bool {
+	return len(c.Rules) > 0 || len(c.Failures) > 0
+}
+
+// Validate checks the rules and webhooks
+func (c *AlertingConfig) Validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+	if c.Interval.Duration <= 0 {
+		return fmt.Errorf("alerting interval must be positive")
+	}
+
+	webhooks := make(map[string]bool, len(c.Webhooks))
+	for _, wh := range c.Webhooks {
+		if wh.Name == "" {
+			return fmt.Errorf("webhook name cannot be empty")
+		}
+		if webhooks[wh.Name] {
+			return fmt.Errorf("duplicate webhook name: %s", wh.Name)
+		}
+		webhooks[wh.Name] = true
+
+		u, err := url.Parse(wh.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("webhook %s: invalid URL: %s", wh.Name, wh.URL)
+		}
+		if wh.Timeout.Duration <= 0 || wh.MaxAttempts < 1 || wh.Backoff.Duration < 0 {
+			return fmt.Errorf("webhook %s: timeout and max attempts must be positive", wh.Name)
+		}
+	}
+
+	if c.BaseURL != "" {
+		u, err := url.Parse(c.BaseURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid base URL: %s", c.BaseURL)
+		}
+	}
+
+	rules := make(map[string]bool, len(c.Rules))
+	for _, r := range c.Rules {
+		if r.Name == "" {
+			return fmt.Errorf("alert rule name cannot be empty")
+		}
+		if rules[r.Name] {
+			return fmt.Errorf("duplicate alert rule name: %s", r.Name)
+		}
+		rules[r.Name] = true
+
+		switch r.Type {
+		case AlertPendingDepth:
+			if r.Threshold < 0 {
+				return fmt.Errorf("rule %s: threshold cannot be negative", r.Name)
+			}
+		case AlertFailedDelta:
+			if r.Threshold < 0 {
+				return fmt.Errorf("rule %s: threshold cannot be negative", r.Name)
+			}
+			if r.Window.Duration <= 0 {
+				return fmt.Errorf("rule %s: window must be positive", r.Name)
+			}
+		case AlertOldestPendingAge:
+			if r.MaxAge.Duration <= 0 {
+				return fmt.Errorf("rule %s: max_age must be positive", r.Name)
+			}
+		case AlertChainFailed, AlertGroupFailed:
+		default:
+			return fmt.Errorf("rule %s: invalid type: %s (must be %s, %s, %s, %s or %s)", r.Name, r.Type,
+				AlertPendingDepth, AlertFailedDelta, AlertOldestPendingAge, AlertChainFailed, AlertGroupFailed)
+		}
+		if r.For.Duration < 0 {
+			return fmt.Errorf("rule %s: for cannot be negative", r.Name)
+		}
+
+		for _, name := range r.Webhooks {
+			if !webhooks[name] {
+				return fmt.Errorf("rule %s: unknown webhook: %s", r.Name, name)
+			}
+		}
+	}
+
+	failures := make(map[string]bool, len(c.Failures))
+	for _, r := range c.Failures {
+		if r.Name == "" {
+			return fmt.Errorf("failure rule name cannot be empty")
+		}
+		if failures[r.Name] {
+			return fmt.Errorf("duplicate failure rule name: %s", r.Name)
+		}
+		failures[r.Name] = true
+
+		for _, p := range append(r.Tasks, r.Queues...) {
+			if _, err := path.Match(p, ""); err != nil {
+				return fmt.Errorf("failure rule %s: invalid pattern: %s", r.Name, p)
+			}
+		}
+		if r.Dedup.Duration < 0 {
+			return fmt.Errorf("failure rule %s: dedup cannot be negative", r.Name)
+		}
+		for _, name := range r.Webhooks {
+			if !webhooks[name] {
+				return fmt.Errorf("failure rule %s: unknown webhook: %s", r.Name, name)
+			}
+		}
+	}
+
+	return nil
+}
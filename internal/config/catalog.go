@@ -0,0 +1,56 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/kalbhor/tasqueue-ui/internal/jsonschema"
+	"github.com/kalbhor/tasqueue-ui/worker"
+)
+
+// CatalogConfig describes tasks for the task catalog. It is read from a
+// JSON file with LoadCatalog.
+type CatalogConfig struct {
+	Tasks []worker.TaskSpec `json:"tasks"`
+}
+
+// LoadCatalog reads the task catalog from a JSON file
+func LoadCatalog(path string) (CatalogConfig, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return CatalogConfig{}, fmt.Errorf("failed to read tasks config: %w", err)
+	}
+
+	var cfg CatalogConfig
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return CatalogConfig{}, fmt.Errorf("failed to parse tasks config: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// Validate checks task names are set and unique and that schemas compile
+func (c *CatalogConfig) Validate() error {
+	names := make(map[string]bool, len(c.Tasks))
+	for _, t := range c.Tasks {
+		if t.Name == "" {
+			return fmt.Errorf("task name cannot be empty")
+		}
+		if names[t.Name] {
+			return fmt.Errorf("duplicate task: %s", t.Name)
+		}
+		names[t.Name] = true
+
+		if len(t.Schema) > 0 {
+			if _, err := jsonschema.Compile(t.Schema); err != nil {
+				return fmt.Errorf("task %s: invalid schema: %w", t.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
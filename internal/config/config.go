@@ -7,15 +7,27 @@ import (
 
 // Config holds all configuration for the UI server
 type Config struct {
-	Server ServerConfig
-	Broker BrokerConfig
-	UI     UIConfig
+	Server    ServerConfig
+	Broker    BrokerConfig
+	Results   ResultsConfig
+	Log       LogConfig
+	Tracing   TracingConfig
+	Readiness ReadinessConfig
+	Alerting  AlertingConfig
+	Stats     StatsConfig
+	Catalog   CatalogConfig
+	Retention RetentionConfig
+	UI        UIConfig
 }
 
 // ServerConfig holds HTTP server configuration
 type ServerConfig struct {
 	Port string
 	Host string
+
+	// RequireProxyUser rejects changes without the X-Forwarded-User header
+	// of an authenticating proxy, so their author can't be made up
+	RequireProxyUser bool
 }
 
 // BrokerConfig holds broker connection configuration
@@ -23,19 +35,74 @@ type BrokerConfig struct {
 	Type  string // redis, nats-js, or in-memory
 	Redis RedisConfig
 	NATS  NATSConfig
+
+	// QueuePattern matches the Redis lists listed as queues besides the
+	// default queue and those tasks are registered on; empty lists no others
+	QueuePattern string
+}
+
+// DefaultQueuePattern matches tasqueue's own queue keys, such as its
+// default queue tasqueue:tasks
+const DefaultQueuePattern = "tasqueue:*"
+
+// ResultsConfig holds results backend connection configuration
+type ResultsConfig struct {
+	Type  string // redis or in-memory
+	Redis RedisConfig
 }
 
 // RedisConfig holds Redis-specific configuration
 type RedisConfig struct {
-	Addr     string
-	Password string
-	DB       int
+	// Addrs is a single address, a cluster seed list or the sentinel addresses
+	Addrs        []string
+	Username     string // ACL username
+	Password     string
+	PasswordFile string // File to read the password from, instead of Password
+	DB           int
+	MasterName   string // Sentinel master name; enables sentinel mode when set
+	Cluster      bool   // Connect to a Redis Cluster
+	TLS          TLSConfig
+}
+
+// TLSConfig holds TLS settings for a client connection
+type TLSConfig struct {
+	Enabled    bool
+	CAFile     string // PEM bundle used to verify the server instead of the system roots
+	CertFile   string // Client certificate for mutual TLS
+	KeyFile    string // Client key for mutual TLS
+	ServerName string // Overrides the name used to verify the server certificate
 }
 
 // NATSConfig holds NATS JetStream configuration
 type NATSConfig struct {
-	URL    string
-	Stream string
+	URL      string
+	Username string
+	Password string
+}
+
+// LogConfig holds logging configuration
+type LogConfig struct {
+	Format string // text or json
+	Level  string // debug, info, warn or error
+}
+
+// TracingConfig holds OpenTelemetry tracing configuration
+type TracingConfig struct {
+	Exporter    string  // none, otlp or stdout
+	Endpoint    string  // OTLP/HTTP endpoint URL; OTEL_EXPORTER_OTLP_* variables apply when empty
+	File        string  // File the stdout exporter writes to instead of stdout
+	SampleRatio float64 // Fraction of new traces to sample, from 0 to 1
+}
+
+// ReadinessConfig holds settings of the readiness probe
+type ReadinessConfig struct {
+	Timeout  time.Duration // Maximum time a single dependency check may take
+	CacheTTL time.Duration // How long a readiness report is reused
+}
+
+// StatsConfig holds settings of the per-task statistics
+type StatsConfig struct {
+	Interval time.Duration // How often jobs finished since the last update are counted
 }
 
 // UIConfig holds UI-specific settings
@@ -54,15 +121,38 @@ func DefaultConfig() Config {
 		Broker: BrokerConfig{
 			Type: "redis",
 			Redis: RedisConfig{
-				Addr:     "localhost:6379",
+				Addrs:    []string{"localhost:6379"},
 				Password: "",
 				DB:       0,
 			},
 			NATS: NATSConfig{
-				URL:    "nats://localhost:4222",
-				Stream: "TASQUEUE",
+				URL: "nats://localhost:4222",
+			},
+			QueuePattern: DefaultQueuePattern,
+		},
+		Results: ResultsConfig{
+			Type: "redis",
+			Redis: RedisConfig{
+				Addrs:    []string{"localhost:6379"},
+				Password: "",
+				DB:       0,
 			},
 		},
+		Log: LogConfig{
+			Format: "text",
+			Level:  "info",
+		},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			SampleRatio: 1,
+		},
+		Readiness: ReadinessConfig{
+			Timeout:  2 * time.Second,
+			CacheTTL: 2 * time.Second,
+		},
+		Stats: StatsConfig{
+			Interval: 10 * time.Second,
+		},
 		UI: UIConfig{
 			RefreshInterval: 3 * time.Second,
 			MaxJobsDisplay:  100,
@@ -84,9 +174,116 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("invalid broker type: %s (must be redis, nats-js, or in-memory)", c.Broker.Type)
 	}
 
+	if c.Results.Type == "nats-js" {
+		return fmt.Errorf("unsupported results type: nats-js (the NATS results store does not track successful and failed jobs, use redis)")
+	}
+	if c.Results.Type != "redis" && c.Results.Type != "in-memory" {
+		return fmt.Errorf("invalid results type: %s (must be redis or in-memory)", c.Results.Type)
+	}
+
+	// In-memory stores live inside this process, so they cannot be shared
+	// with workers through any other backend.
+	if (c.Broker.Type == "in-memory") != (c.Results.Type == "in-memory") {
+		return fmt.Errorf("unsupported combination: %s broker with %s results (in-memory can only be paired with in-memory)", c.Broker.Type, c.Results.Type)
+	}
+
+	if c.Broker.Type == "redis" {
+		if err := c.Broker.Redis.Validate(); err != nil {
+			return fmt.Errorf("broker: %w", err)
+		}
+	}
+
+	if c.Broker.Type == "nats-js" && c.Broker.NATS.URL == "" {
+		return fmt.Errorf("broker: nats URL cannot be empty")
+	}
+
+	if c.Results.Type == "redis" {
+		if err := c.Results.Redis.Validate(); err != nil {
+			return fmt.Errorf("results: %w", err)
+		}
+	}
+
+	if c.Log.Format != "text" && c.Log.Format != "json" {
+		return fmt.Errorf("invalid log format: %s (must be text or json)", c.Log.Format)
+	}
+
+	switch c.Tracing.Exporter {
+	case "", "none", "otlp", "stdout":
+	default:
+		return fmt.Errorf("invalid trace exporter: %s (must be none, otlp or stdout)", c.Tracing.Exporter)
+	}
+
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		return fmt.Errorf("trace sample ratio must be between 0 and 1")
+	}
+
+	if c.Readiness.Timeout <= 0 {
+		return fmt.Errorf("readiness timeout must be positive")
+	}
+	if c.Readiness.CacheTTL < 0 {
+		return fmt.Errorf("readiness cache TTL cannot be negative")
+	}
+
+	if c.Stats.Interval <= 0 {
+		return fmt.Errorf("task stats interval must be positive")
+	}
+
+	if err := c.Alerting.Validate(); err != nil {
+		return fmt.Errorf("alerting: %w", err)
+	}
+
+	if err := c.Catalog.Validate(); err != nil {
+		return fmt.Errorf("tasks: %w", err)
+	}
+
+	if err := c.Retention.Validate(); err != nil {
+		return fmt.Errorf("retention: %w", err)
+	}
+	if c.Retention.Enabled() && c.Results.Type != "redis" {
+		return fmt.Errorf("retention policies require a redis results store")
+	}
+
 	if c.Server.Port == "" {
 		return fmt.Errorf("server port cannot be empty")
 	}
 
 	return nil
 }
+
+// Validate checks that the Redis topology settings are consistent
+func (r *RedisConfig) Validate() error {
+	if len(r.Addrs) == 0 {
+		return fmt.Errorf("at least one redis address is required")
+	}
+
+	if r.Cluster && r.MasterName != "" {
+		return fmt.Errorf("redis cluster and sentinel modes cannot be combined")
+	}
+
+	if r.Cluster && r.DB != 0 {
+		return fmt.Errorf("redis cluster only supports database 0")
+	}
+
+	if !r.Cluster && r.MasterName == "" && len(r.Addrs) > 1 {
+		return fmt.Errorf("multiple redis addresses require cluster mode or a sentinel master name")
+	}
+
+	if r.Password != "" && r.PasswordFile != "" {
+		return fmt.Errorf("redis password and password file cannot both be set")
+	}
+
+	return r.TLS.Validate()
+}
+
+// Validate checks that the TLS settings are consistent
+func (t *TLSConfig) Validate() error {
+	if !t.Enabled && (t.CAFile != "" || t.CertFile != "" || t.KeyFile != "" || t.ServerName != "") {
+		return fmt.Errorf("TLS options are set but TLS is not enabled")
+	}
+
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		return fmt.Errorf("TLS client certificate and key must be set together")
+	}
+
+	return nil
+}
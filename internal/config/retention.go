@@ -0,0 +1,131 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"time"
+)
+
+// RetentionConfig holds the policies old finished jobs are deleted by. It
+// is read from a JSON file with LoadRetention.
+type RetentionConfig struct {
+	Interval      Duration          `json:"interval"`       // How often expired jobs are looked for
+	BatchSize     int               `json:"batch_size"`     // Jobs read and deleted per batch
+	BatchInterval Duration          `json:"batch_interval"` // Pause after each batch that deleted jobs
+	DryRun        bool              `json:"dry_run"`        // Count and log expired jobs without deleting them
+	Policies      []RetentionPolicy `json:"policies"`
+	Archive       ArchiveConfig     `json:"archive"`
+}
+
+// ArchiveConfig sets where expired jobs are archived before they are deleted
+type ArchiveConfig struct {
+	// Dir is the directory archive files are written to and searched in;
+	// jobs aren't archived when it is empty
+	Dir string `json:"dir"`
+
+	// MaxFileSize is the size in bytes after which a new file is started,
+	// 64 MiB when zero
+	MaxFileSize int64 `json:"max_file_size,omitempty"`
+
+	// MaxAge is how long archived days are kept; forever when zero
+	MaxAge Duration `json:"max_age,omitempty"`
+}
+
+// RetentionPolicy sets how long finished jobs with a status and a matching
+// task are kept. A job is kept by the first policy that matches it, and
+// forever when none does.
+type RetentionPolicy struct {
+	Status string `json:"status"` // successful or failed
+
+	// Tasks are glob patterns such as "billing.*"; an empty list matches
+	// every task
+	Tasks []string `json:"tasks,omitempty"`
+
+	// MaxAge is how long jobs are kept after they finish; matching jobs
+	// are kept forever when it is zero
+	MaxAge Duration `json:"max_age,omitempty"`
+}
+
+// Matches reports whether a job with the given status and task matches the policy
+func (p RetentionPolicy) Matches(status, task string) bool {
+	return p.Status == status && matchAny(p.Tasks, task)
+}
+
+// LoadRetention reads retention policies from a JSON file and fills in
+// defaults
+func LoadRetention(path string) (RetentionConfig, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return RetentionConfig{}, fmt.Errorf("failed to read retention config: %w", err)
+	}
+
+	var cfg RetentionConfig
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return RetentionConfig{}, fmt.Errorf("failed to parse retention config: %w", err)
+	}
+
+	if cfg.Interval.Duration == 0 {
+		cfg.Interval.Duration = 10 * time.Minute
+	}
+	if cfg.BatchSize == 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.BatchInterval.Duration == 0 {
+		cfg.BatchInterval.Duration = time.Second
+	}
+
+	return cfg, nil
+}
+
+// Enabled reports whether any retention policies are configured
+func (c *RetentionConfig) Enabled() bool {
+	return len(c.Policies) > 0
+}
+
+// Validate checks the policies, batch and archive settings
+func (c *RetentionConfig) Validate() error {
+	if c.Archive.MaxFileSize < 0 {
+		return fmt.Errorf("archive max file size cannot be negative")
+	}
+	if c.Archive.MaxAge.Duration < 0 {
+		return fmt.Errorf("archive max age cannot be negative")
+	}
+
+	if !c.Enabled() {
+		// Jobs are archived, and old days removed, by retention passes only
+		if c.Archive.Dir != "" {
+			return fmt.Errorf("archive dir %s is set without retention policies, so nothing would be archived", c.Archive.Dir)
+		}
+		return nil
+	}
+	if c.Interval.Duration <= 0 {
+		return fmt.Errorf("retention interval must be positive")
+	}
+	if c.BatchSize < 1 {
+		return fmt.Errorf("batch size must be positive")
+	}
+	if c.BatchInterval.Duration < 0 {
+		return fmt.Errorf("batch interval cannot be negative")
+	}
+
+	for i, p := range c.Policies {
+		if p.Status != "successful" && p.Status != "failed" {
+			return fmt.Errorf("policy %d: invalid status: %s (must be successful or failed)", i+1, p.Status)
+		}
+		for _, t := range p.Tasks {
+			if _, err := path.Match(t, ""); err != nil {
+				return fmt.Errorf("policy %d: invalid pattern: %s", i+1, t)
+			}
+		}
+		if p.MaxAge.Duration < 0 {
+			return fmt.Errorf("policy %d: max_age cannot be negative", i+1)
+		}
+	}
+
+	return nil
+}
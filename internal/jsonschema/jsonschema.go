@@ -0,0 +1,507 @@
+// Package jsonschema validates JSON documents against the subset of JSON
+// Schema task payloads need: type, enum, const, properties, required,
+// additionalProperties, items, the length, size and range limits, pattern,
+// and the allOf, anyOf, oneOf and not combinators. Schemas using any other
+// keyword, such as $ref or format, fail to compile rather than accept
+// documents the keyword would reject.
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+)
+
+// keywords are the keywords Compile understands. Annotations don't affect
+// validation, so they're accepted and ignored.
+var keywords = map[string]bool{
+	"type": true, "enum": true, "const": true,
+	"properties": true, "required": true, "additionalProperties": true,
+	"minProperties": true, "maxProperties": true,
+	"items": true, "minItems": true, "maxItems": true,
+	"minLength": true, "maxLength": true, "pattern": true,
+	"minimum": true, "maximum": true, "multipleOf": true,
+	"exclusiveMinimum": true, "exclusiveMaximum": true,
+	"allOf": true, "anyOf": true, "oneOf": true, "not": true,
+
+	// Annotations
+	"$schema": true, "$id": true, "$comment": true,
+	"title": true, "description": true, "default": true, "examples": true,
+	"deprecated": true, "readOnly": true, "writeOnly": true,
+}
+
+// Schema is a compiled JSON Schema
+type Schema struct {
+	always *bool // Set for the true and false schemas
+
+	types   []string
+	enum    []any
+	hasEnum bool
+	konst   any
+	isConst bool
+
+	properties   map[string]*Schema
+	required     []string
+	additional   *Schema
+	minProps     *int
+	maxProps     *int
+	items        *Schema
+	minItems     *int
+	maxItems     *int
+	minLength    *int
+	maxLength    *int
+	pattern      *regexp.Regexp
+	minimum      *float64
+	maximum      *float64
+	exclusiveMin *float64
+	exclusiveMax *float64
+	multipleOf   *float64
+
+	allOf []*Schema
+	anyOf []*Schema
+	oneOf []*Schema
+	not   *Schema
+}
+
+// Compile parses a JSON Schema document
+func Compile(raw []byte) (*Schema, error) {
+	v, err := decode(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	return compile(v, "#")
+}
+
+// Validate checks a JSON document against the schema. The error names the
+// location of the first violation, such as "payload.items[2]".
+func (s *Schema) Validate(doc []byte) error {
+	v, err := decode(doc)
+	if err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	return s.validate(v, "payload")
+}
+
+// decode parses JSON with numbers converted to float64, rejecting
+// trailing data
+func decode(b []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("unexpected data after the document")
+	}
+	return normalize(v)
+}
+
+// normalize converts json.Number values to float64
+func normalize(v any) (any, error) {
+	switch v := v.(type) {
+	case json.Number:
+		return strconv.ParseFloat(string(v), 64)
+	case []any:
+		for i := range v {
+			n, err := normalize(v[i])
+			if err != nil {
+				return nil, err
+			}
+			v[i] = n
+		}
+	case map[string]any:
+		for k := range v {
+			n, err := normalize(v[k])
+			if err != nil {
+				return nil, err
+			}
+			v[k] = n
+		}
+	}
+	return v, nil
+}
+
+func compile(v any, at string) (*Schema, error) {
+	if b, ok := v.(bool); ok {
+		return &Schema{always: &b}, nil
+	}
+	m, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s: schema must be an object or a boolean", at)
+	}
+
+	// Report the first unsupported keyword in a stable order
+	kws := make([]string, 0, len(m))
+	for kw := range m {
+		kws = append(kws, kw)
+	}
+	sort.Strings(kws)
+	for _, kw := range kws {
+		if !keywords[kw] {
+			return nil, fmt.Errorf("%s/%s: unsupported keyword", at, kw)
+		}
+	}
+
+	s := &Schema{}
+	var err error
+	if t, ok := m["type"]; ok {
+		if s.types, err = stringList(t); err != nil {
+			return nil, fmt.Errorf("%s/type: %w", at, err)
+		}
+		for _, t := range s.types {
+			switch t {
+			case "null", "boolean", "object", "array", "number", "integer", "string":
+			default:
+				return nil, fmt.Errorf("%s/type: unknown type %q", at, t)
+			}
+		}
+	}
+	if e, ok := m["enum"]; ok {
+		if s.enum, ok = e.([]any); !ok {
+			return nil, fmt.Errorf("%s/enum: must be an array", at)
+		}
+		s.hasEnum = true
+	}
+	if c, ok := m["const"]; ok {
+		s.konst, s.isConst = c, true
+	}
+
+	if p, ok := m["properties"]; ok {
+		props, ok := p.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%s/properties: must be an object", at)
+		}
+		s.properties = make(map[string]*Schema, len(props))
+		for name, ps := range props {
+			if s.properties[name], err = compile(ps, at+"/properties/"+name); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if r, ok := m["required"]; ok {
+		if s.required, err = stringList(r); err != nil {
+			return nil, fmt.Errorf("%s/required: %w", at, err)
+		}
+	}
+	if a, ok := m["additionalProperties"]; ok {
+		if s.additional, err = compile(a, at+"/additionalProperties"); err != nil {
+			return nil, err
+		}
+	}
+	if i, ok := m["items"]; ok {
+		if s.items, err = compile(i, at+"/items"); err != nil {
+			return nil, err
+		}
+	}
+
+	ints := map[string]**int{
+		"minProperties": &s.minProps, "maxProperties": &s.maxProps,
+		"minItems": &s.minItems, "maxItems": &s.maxItems,
+		"minLength": &s.minLength, "maxLength": &s.maxLength,
+	}
+	for kw, dst := range ints {
+		if v, ok := m[kw]; ok {
+			f, ok := v.(float64)
+			if !ok || f < 0 || f != math.Trunc(f) {
+				return nil, fmt.Errorf("%s/%s: must be a non-negative integer", at, kw)
+			}
+			n := int(f)
+			*dst = &n
+		}
+	}
+	nums := map[string]**float64{
+		"minimum": &s.minimum, "maximum": &s.maximum,
+		"exclusiveMinimum": &s.exclusiveMin, "exclusiveMaximum": &s.exclusiveMax,
+		"multipleOf": &s.multipleOf,
+	}
+	for kw, dst := range nums {
+		if v, ok := m[kw]; ok {
+			f, ok := v.(float64)
+			if !ok {
+				return nil, fmt.Errorf("%s/%s: must be a number", at, kw)
+			}
+			*dst = &f
+		}
+	}
+	if s.multipleOf != nil && *s.multipleOf <= 0 {
+		return nil, fmt.Errorf("%s/multipleOf: must be positive", at)
+	}
+	if p, ok := m["pattern"]; ok {
+		ps, ok := p.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s/pattern: must be a string", at)
+		}
+		if s.pattern, err = regexp.Compile(ps); err != nil {
+			return nil, fmt.Errorf("%s/pattern: %w", at, err)
+		}
+	}
+
+	for kw, dst := range map[string]*[]*Schema{"allOf": &s.allOf, "anyOf": &s.anyOf, "oneOf": &s.oneOf} {
+		v, ok := m[kw]
+		if !ok {
+			continue
+		}
+		list, ok := v.([]any)
+		if !ok || len(list) == 0 {
+			return nil, fmt.Errorf("%s/%s: must be a non-empty array", at, kw)
+		}
+		for i, sub := range list {
+			cs, err := compile(sub, fmt.Sprintf("%s/%s/%d", at, kw, i))
+			if err != nil {
+				return nil, err
+			}
+			*dst = append(*dst, cs)
+		}
+	}
+	if n, ok := m["not"]; ok {
+		if s.not, err = compile(n, at+"/not"); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// stringList reads a string or an array of strings
+func stringList(v any) ([]string, error) {
+	if s, ok := v.(string); ok {
+		return []string{s}, nil
+	}
+	list, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("must be a string or an array of strings")
+	}
+	out := make([]string, 0, len(list))
+	for _, e := range list {
+		s, ok := e.(string)
+		if !ok {
+			return nil, fmt.Errorf("must be a string or an array of strings")
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+func (s *Schema) validate(v any, at string) error {
+	if s.always != nil {
+		if !*s.always {
+			return fmt.Errorf("%s: not allowed", at)
+		}
+		return nil
+	}
+
+	if len(s.types) > 0 && !s.typeMatches(v) {
+		return fmt.Errorf("%s: must be of type %s, got %s", at, joinOr(s.types), typeOf(v))
+	}
+	if s.hasEnum {
+		found := false
+		for _, e := range s.enum {
+			if reflect.DeepEqual(e, v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: must be one of the enumerated values", at)
+		}
+	}
+	if s.isConst && !reflect.DeepEqual(s.konst, v) {
+		return fmt.Errorf("%s: must equal the constant value", at)
+	}
+
+	var err error
+	switch v := v.(type) {
+	case map[string]any:
+		err = s.validateObject(v, at)
+	case []any:
+		err = s.validateArray(v, at)
+	case string:
+		err = s.validateString(v, at)
+	case float64:
+		err = s.validateNumber(v, at)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, sub := range s.allOf {
+		if err := sub.validate(v, at); err != nil {
+			return err
+		}
+	}
+	if len(s.anyOf) > 0 {
+		var first error
+		for _, sub := range s.anyOf {
+			if first = sub.validate(v, at); first == nil {
+				break
+			}
+		}
+		if first != nil {
+			return fmt.Errorf("%s: must match at least one schema of anyOf", at)
+		}
+	}
+	if len(s.oneOf) > 0 {
+		matched := 0
+		for _, sub := range s.oneOf {
+			if sub.validate(v, at) == nil {
+				matched++
+			}
+		}
+		if matched != 1 {
+			return fmt.Errorf("%s: must match exactly one schema of oneOf, matched %d", at, matched)
+		}
+	}
+	if s.not != nil && s.not.validate(v, at) == nil {
+		return fmt.Errorf("%s: must not match the schema of not", at)
+	}
+
+	return nil
+}
+
+func (s *Schema) validateObject(v map[string]any, at string) error {
+	if s.minProps != nil && len(v) < *s.minProps {
+		return fmt.Errorf("%s: must have at least %d properties", at, *s.minProps)
+	}
+	if s.maxProps != nil && len(v) > *s.maxProps {
+		return fmt.Errorf("%s: must have at most %d properties", at, *s.maxProps)
+	}
+	for _, name := range s.required {
+		if _, ok := v[name]; !ok {
+			return fmt.Errorf("%s: missing required property %q", at, name)
+		}
+	}
+
+	// Check properties in a stable order so the error reported is too
+	names := make([]string, 0, len(v))
+	for name := range v {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if ps, ok := s.properties[name]; ok {
+			if err := ps.validate(v[name], at+"."+name); err != nil {
+				return err
+			}
+			continue
+		}
+		if s.additional != nil {
+			if s.additional.always != nil && !*s.additional.always {
+				return fmt.Errorf("%s: unexpected property %q", at, name)
+			}
+			if err := s.additional.validate(v[name], at+"."+name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Schema) validateArray(v []any, at string) error {
+	if s.minItems != nil && len(v) < *s.minItems {
+		return fmt.Errorf("%s: must have at least %d items", at, *s.minItems)
+	}
+	if s.maxItems != nil && len(v) > *s.maxItems {
+		return fmt.Errorf("%s: must have at most %d items", at, *s.maxItems)
+	}
+	if s.items != nil {
+		for i, e := range v {
+			if err := s.items.validate(e, fmt.Sprintf("%s[%d]", at, i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Schema) validateString(v, at string) error {
+	n := utf8.RuneCountInString(v)
+	if s.minLength != nil && n < *s.minLength {
+		return fmt.Errorf("%s: must be at least %d characters", at, *s.minLength)
+	}
+	if s.maxLength != nil && n > *s.maxLength {
+		return fmt.Errorf("%s: must be at most %d characters", at, *s.maxLength)
+	}
+	if s.pattern != nil && !s.pattern.MatchString(v) {
+		return fmt.Errorf("%s: must match the pattern %s", at, s.pattern)
+	}
+	return nil
+}
+
+func (s *Schema) validateNumber(v float64, at string) error {
+	switch {
+	case s.minimum != nil && v < *s.minimum:
+		return fmt.Errorf("%s: must be at least %g", at, *s.minimum)
+	case s.maximum != nil && v > *s.maximum:
+		return fmt.Errorf("%s: must be at most %g", at, *s.maximum)
+	case s.exclusiveMin != nil && v <= *s.exclusiveMin:
+		return fmt.Errorf("%s: must be greater than %g", at, *s.exclusiveMin)
+	case s.exclusiveMax != nil && v >= *s.exclusiveMax:
+		return fmt.Errorf("%s: must be less than %g", at, *s.exclusiveMax)
+	}
+	if s.multipleOf != nil {
+		if q := v / *s.multipleOf; q != math.Trunc(q) {
+			return fmt.Errorf("%s: must be a multiple of %g", at, *s.multipleOf)
+		}
+	}
+	return nil
+}
+
+// typeMatches reports whether v is of one of the schema's types
+func (s *Schema) typeMatches(v any) bool {
+	t := typeOf(v)
+	for _, want := range s.types {
+		if want == t || (want == "number" && t == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// typeOf returns the JSON Schema type of a decoded value. Numbers without
+// a fractional part are integers.
+func typeOf(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		if v == math.Trunc(v) && !math.IsInf(v, 0) {
+			return "integer"
+		}
+		return "number"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// joinOr joins types as "a, b or c"
+func joinOr(types []string) string {
+	if len(types) == 1 {
+		return types[0]
+	}
+	out := ""
+	for i, t := range types {
+		switch {
+		case i == 0:
+		case i == len(types)-1:
+			out += " or "
+		default:
+			out += ", "
+		}
+		out += t
+	}
+	return out
+}
@@ -0,0 +1,148 @@
+package jsonschema
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		doc    string
+		err    string // Substring of the error; empty if the document is valid
+	}{
+		{"true schema", `true`, `{"a": 1}`, ""},
+		{"false schema", `false`, `1`, "payload: not allowed"},
+		{"empty schema", `{}`, `[1, "a", null]`, ""},
+
+		{"type", `{"type": "string"}`, `"a"`, ""},
+		{"type mismatch", `{"type": "string"}`, `1`, "payload: must be of type string, got integer"},
+		{"type list", `{"type": ["string", "null"]}`, `null`, ""},
+		{"type list mismatch", `{"type": ["string", "null", "array"]}`, `true`, "must be of type string, null or array, got boolean"},
+		{"integer", `{"type": "integer"}`, `3`, ""},
+		{"integer with zero fraction", `{"type": "integer"}`, `3.0`, ""},
+		{"integer with fraction", `{"type": "integer"}`, `3.5`, "must be of type integer, got number"},
+		{"number accepts integer", `{"type": "number"}`, `3`, ""},
+		{"object", `{"type": "object"}`, `[]`, "must be of type object, got array"},
+
+		{"enum", `{"enum": ["a", 1, null]}`, `1`, ""},
+		{"enum mismatch", `{"enum": ["a", 1]}`, `"b"`, "must be one of the enumerated values"},
+		{"enum object", `{"enum": [{"a": [1]}]}`, `{"a": [1]}`, ""},
+		{"const", `{"const": "a"}`, `"a"`, ""},
+		{"const mismatch", `{"const": "a"}`, `"b"`, "must equal the constant value"},
+
+		{"required", `{"required": ["a", "b"]}`, `{"a": 1, "b": 2}`, ""},
+		{"required missing", `{"required": ["a", "b"]}`, `{"a": 1}`, `payload: missing required property "b"`},
+		{"required ignores non-objects", `{"required": ["a"]}`, `"a"`, ""},
+		{"property", `{"properties": {"a": {"type": "string"}}}`, `{"a": "x"}`, ""},
+		{"property mismatch", `{"properties": {"a": {"type": "string"}}}`, `{"a": 1}`, "payload.a: must be of type string"},
+		{"nested property", `{"properties": {"a": {"properties": {"b": {"type": "string"}}}}}`, `{"a": {"b": 1}}`, "payload.a.b:"},
+		{"first failing property in order", `{"additionalProperties": {"type": "string"}}`, `{"c": 1, "b": 1}`, "payload.b:"},
+
+		{"additionalProperties false", `{"properties": {"a": {}}, "additionalProperties": false}`, `{"a": 1}`, ""},
+		{"additionalProperties false extra", `{"properties": {"a": {}}, "additionalProperties": false}`, `{"a": 1, "b": 2}`, `payload: unexpected property "b"`},
+		{"additionalProperties schema", `{"properties": {"a": {}}, "additionalProperties": {"type": "integer"}}`, `{"a": "x", "b": 2}`, ""},
+		{"additionalProperties schema mismatch", `{"additionalProperties": {"type": "integer"}}`, `{"b": "x"}`, "payload.b: must be of type integer"},
+		{"minProperties", `{"minProperties": 2}`, `{"a": 1}`, "must have at least 2 properties"},
+		{"maxProperties", `{"maxProperties": 1}`, `{"a": 1, "b": 2}`, "must have at most 1 properties"},
+
+		{"items", `{"items": {"type": "integer"}}`, `[1, 2]`, ""},
+		{"items mismatch", `{"items": {"type": "integer"}}`, `[1, "a"]`, "payload[1]: must be of type integer"},
+		{"minItems", `{"minItems": 1}`, `[]`, "must have at least 1 items"},
+		{"maxItems", `{"maxItems": 1}`, `[1, 2]`, "must have at most 1 items"},
+
+		{"minLength counts characters", `{"minLength": 2}`, `"é"`, "must be at least 2 characters"},
+		{"maxLength counts characters", `{"maxLength": 1}`, `"é"`, ""},
+		{"pattern", `{"pattern": "^a+$"}`, `"aaa"`, ""},
+		{"pattern mismatch", `{"pattern": "^a+$"}`, `"ab"`, "must match the pattern ^a+$"},
+
+		{"minimum", `{"minimum": 1}`, `1`, ""},
+		{"minimum violated", `{"minimum": 1}`, `0.5`, "must be at least 1"},
+		{"maximum violated", `{"maximum": 1}`, `2`, "must be at most 1"},
+		{"exclusiveMinimum", `{"exclusiveMinimum": 1}`, `1`, "must be greater than 1"},
+		{"exclusiveMaximum", `{"exclusiveMaximum": 1}`, `1`, "must be less than 1"},
+		{"multipleOf", `{"multipleOf": 0.5}`, `1.5`, ""},
+		{"multipleOf violated", `{"multipleOf": 2}`, `3`, "must be a multiple of 2"},
+
+		{"allOf", `{"allOf": [{"type": "integer"}, {"minimum": 1}]}`, `2`, ""},
+		{"allOf one fails", `{"allOf": [{"type": "integer"}, {"minimum": 1}]}`, `0`, "must be at least 1"},
+		{"anyOf", `{"anyOf": [{"type": "string"}, {"type": "integer"}]}`, `1`, ""},
+		{"anyOf none", `{"anyOf": [{"type": "string"}, {"type": "integer"}]}`, `true`, "must match at least one schema of anyOf"},
+		{"oneOf", `{"oneOf": [{"type": "string"}, {"type": "integer"}]}`, `1`, ""},
+		{"oneOf none", `{"oneOf": [{"type": "string"}, {"type": "integer"}]}`, `true`, "matched 0"},
+		{"oneOf several", `{"oneOf": [{"type": "number"}, {"type": "integer"}]}`, `1`, "matched 2"},
+		{"not", `{"not": {"type": "string"}}`, `1`, ""},
+		{"not matched", `{"not": {"type": "string"}}`, `"a"`, "must not match the schema of not"},
+
+		{"annotations", `{"title": "t", "description": "d", "default": 1, "examples": [1], "$comment": "c"}`, `2`, ""},
+
+		{"invalid document", `{}`, `{"a":`, "invalid JSON"},
+		{"trailing data", `{}`, `1 2`, "invalid JSON"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Compile([]byte(tt.schema))
+			if err != nil {
+				t.Fatalf("Compile: %v", err)
+			}
+			err = s.Validate([]byte(tt.doc))
+			switch {
+			case tt.err == "" && err != nil:
+				t.Errorf("Validate(%s) = %v, want no error", tt.doc, err)
+			case tt.err != "" && err == nil:
+				t.Errorf("Validate(%s) = nil, want an error containing %q", tt.doc, tt.err)
+			case tt.err != "" && !strings.Contains(err.Error(), tt.err):
+				t.Errorf("Validate(%s) = %v, want an error containing %q", tt.doc, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		err    string
+	}{
+		{"invalid JSON", `{"type":`, "invalid JSON"},
+		{"not a schema", `1`, "#: schema must be an object or a boolean"},
+		{"unknown type", `{"type": "date"}`, `#/type: unknown type "date"`},
+		{"type not a string", `{"type": 1}`, "#/type: must be a string or an array of strings"},
+		{"enum not an array", `{"enum": "a"}`, "#/enum: must be an array"},
+		{"properties not an object", `{"properties": []}`, "#/properties: must be an object"},
+		{"invalid property", `{"properties": {"a": 1}}`, "#/properties/a: schema must be an object or a boolean"},
+		{"required not strings", `{"required": [1]}`, "#/required: must be a string or an array of strings"},
+		{"negative minItems", `{"minItems": -1}`, "#/minItems: must be a non-negative integer"},
+		{"fractional maxLength", `{"maxLength": 1.5}`, "#/maxLength: must be a non-negative integer"},
+		{"minimum not a number", `{"minimum": "1"}`, "#/minimum: must be a number"},
+		{"multipleOf zero", `{"multipleOf": 0}`, "#/multipleOf: must be positive"},
+		{"pattern not a string", `{"pattern": 1}`, "#/pattern: must be a string"},
+		{"invalid pattern", `{"pattern": "("}`, "#/pattern:"},
+		{"empty allOf", `{"allOf": []}`, "#/allOf: must be a non-empty array"},
+		{"invalid anyOf member", `{"anyOf": [{}, 1]}`, "#/anyOf/1: schema must be an object or a boolean"},
+		{"invalid not", `{"not": "a"}`, "#/not: schema must be an object or a boolean"},
+
+		// Keywords the validator doesn't implement must not be ignored
+		{"$ref", `{"$ref": "#/$defs/a", "$defs": {"a": {}}}`, "#/$defs: unsupported keyword"},
+		{"definitions", `{"definitions": {}}`, "#/definitions: unsupported keyword"},
+		{"format", `{"type": "string", "format": "email"}`, "#/format: unsupported keyword"},
+		{"patternProperties", `{"patternProperties": {"^a": {}}}`, "#/patternProperties: unsupported keyword"},
+		{"dependentRequired", `{"dependentRequired": {"a": ["b"]}}`, "#/dependentRequired: unsupported keyword"},
+		{"nested", `{"properties": {"a": {"items": {"$ref": "#"}}}}`, "#/properties/a/items/$ref: unsupported keyword"},
+		{"misspelled", `{"requried": ["a"]}`, "#/requried: unsupported keyword"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile([]byte(tt.schema))
+			if err == nil {
+				t.Fatalf("Compile(%s) = nil, want an error containing %q", tt.schema, tt.err)
+			}
+			if !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Compile(%s) = %v, want an error containing %q", tt.schema, err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,627 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kalbhor/tasqueue/v2"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/kalbhor/tasqueue-ui/internal/backend"
+	"github.com/kalbhor/tasqueue-ui/internal/config"
+)
+
+// Alert states
+const (
+	AlertPending  = "pending"  // Condition holds, but not yet for the rule's For duration
+	AlertFiring   = "firing"   // Notified as firing
+	AlertResolved = "resolved" // Condition no longer holds; only seen in notifications
+)
+
+// WebhookEventAlert is the event header value of alert notifications
+const WebhookEventAlert = "alert"
+
+// alertingLock is the lock held by the replica that evaluates alert rules
+// and notifies failures
+const alertingLock = "alerting"
+
+// oldestPendingWindow is the number of jobs at the old end of a queue whose
+// first sighting is remembered by oldest_pending_age rules
+const oldestPendingWindow = 100
+
+// Alert is an instance of a rule whose condition holds, such as one queue
+// of a pending_depth rule. It is also the body of alert notifications.
+type Alert struct {
+	Rule        string            `json:"rule"`
+	Type        string            `json:"type"`
+	Severity    string            `json:"severity,omitempty"`
+	Status      string            `json:"status"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Value       float64           `json:"value"`
+	Threshold   float64           `json:"threshold"`
+	Summary     string            `json:"summary"`
+	Fingerprint string            `json:"fingerprint"` // Identifies the alert across notifications
+	ActiveAt    time.Time         `json:"active_at"`   // When the condition was first seen
+	StartsAt    *time.Time        `json:"starts_at,omitempty"`
+	EndsAt      *time.Time        `json:"ends_at,omitempty"`
+
+	// SilencedBy lists the silences muting the alert's notifications
+	SilencedBy []string `json:"silenced_by,omitempty"`
+
+	// notified records whether the firing notification was sent, so a
+	// resolved one is only sent after it
+	notified bool
+}
+
+// AlertRuleStatus reports the last evaluation of a rule
+type AlertRuleStatus struct {
+	config.AlertRule
+	LastEvaluation time.Time `json:"last_evaluation"`
+	Error          string    `json:"error,omitempty"`
+}
+
+// AlertsStatus holds the rules, the alerts that are pending or firing and
+// the failure notification rules
+type AlertsStatus struct {
+	Enabled bool `json:"enabled"`
+
+	// Evaluating is set on the replica holding the alerting lock, the only
+	// one evaluating rules and sending notifications. The others report
+	// no alerts.
+	Evaluating bool `json:"evaluating"`
+
+	Rules    []AlertRuleStatus   `json:"rules"`
+	Alerts   []Alert             `json:"alerts"`
+	Failures []FailureRuleStatus `json:"failures"`
+}
+
+// alertSample is an instance of a rule's condition found by an evaluation
+type alertSample struct {
+	labels  map[string]string
+	value   float64
+	summary string
+}
+
+// countSample is the number of failed jobs at a point in time
+type countSample struct {
+	at    time.Time
+	count int
+}
+
+// alerter evaluates alert rules on a schedule and notifies webhooks when
+// alerts start and stop firing. State is kept in memory, so alerts firing
+// when the process stops are re-notified after a restart.
+type alerter struct {
+	svc *Service
+	cfg config.AlertingConfig
+
+	mu     sync.Mutex
+	active map[string]*Alert // By fingerprint
+	rules  map[string]*AlertRuleStatus
+
+	// failedCounts samples the failed set for failed_delta rules when the
+	// results store can't count by time
+	failedCounts []countSample
+
+	// firstSeen records when jobs near the old end of each queue were
+	// first seen, for oldest_pending_age rules
+	firstSeen map[string]map[string]time.Time
+}
+
+// newAlerter creates an alerter for the configured rules
+func newAlerter(svc *Service, cfg config.AlertingConfig) *alerter {
+	a := &alerter{
+		svc:       svc,
+		cfg:       cfg,
+		active:    make(map[string]*Alert),
+		rules:     make(map[string]*AlertRuleStatus, len(cfg.Rules)),
+		firstSeen: make(map[string]map[string]time.Time),
+	}
+	for _, r := range cfg.Rules {
+		a.rules[r.Name] = &AlertRuleStatus{AlertRule: r}
+	}
+
+	return a
+}
+
+// RunAlerting evaluates the alert rules and looks for failed jobs every
+// interval, delivering notifications until ctx is done. It returns straight
+// away when no rules are configured.
+//
+// Replicas sharing a Redis results store take turns through a lock, so
+// each alert and failure is notified once: the replica holding it evaluates
+// every interval and keeps it, and the others stand by until it expires.
+func (s *Service) RunAlerting(ctx context.Context) {
+	if s.webhooks == nil {
+		return
+	}
+
+	cfg := s.config.Alerting
+	s.log.InfoContext(ctx, "alerting started", "rules", len(cfg.Rules), "failure_rules", len(cfg.Failures),
+		"webhooks", len(cfg.Webhooks), "interval", cfg.Interval.Duration)
+	go s.webhooks.run(ctx)
+
+	token := uuid.NewString()
+	if s.resultsRedis != nil {
+		defer func() {
+			// Release the lock on shutdown so another replica takes over
+			// without waiting for it to expire
+			rctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+			defer cancel()
+			if err := backend.ReleaseLock(rctx, s.resultsRedis, alertingLock, token); err != nil {
+				s.log.WarnContext(ctx, "failed to release alerting lock", "error", err)
+			}
+		}()
+	}
+
+	t := time.NewTicker(cfg.Interval.Duration)
+	defer t.Stop()
+	for {
+		if s.holdAlertingLock(ctx, token) {
+			if s.alerts != nil {
+				s.alerts.evaluate(ctx)
+			}
+			if s.failures != nil {
+				s.failures.check(ctx)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// alertingLockTTL is how long the alerting lock outlives the last pass of
+// the replica holding it. A pass takes up to two intervals, as the rules
+// and the failed jobs get one each.
+func (s *Service) alertingLockTTL() time.Duration {
+	return 3*s.config.Alerting.Interval.Duration + 5*time.Second
+}
+
+// holdAlertingLock takes or extends the alerting lock, and reports whether
+// this replica should evaluate. A replica standing by forgets its alerts
+// and moves its failure cursor along, so on taking over it notifies alerts
+// still firing again, and failures from one lock TTL back.
+func (s *Service) holdAlertingLock(ctx context.Context, token string) bool {
+	if s.resultsRedis == nil {
+		s.evaluating.Store(true)
+		return true
+	}
+
+	var (
+		held bool
+		err  error
+	)
+	if s.evaluating.Load() {
+		held, err = backend.ExtendLock(ctx, s.resultsRedis, alertingLock, token, s.alertingLockTTL())
+	}
+	if err == nil && !held {
+		held, err = backend.AcquireLock(ctx, s.resultsRedis, alertingLock, token, s.alertingLockTTL())
+	}
+	if err != nil {
+		if ctx.Err() == nil {
+			s.log.ErrorContext(ctx, "failed to take alerting lock", "error", err)
+		}
+		held = false
+	}
+
+	if was := s.evaluating.Swap(held); was != held {
+		if held {
+			s.log.InfoContext(ctx, "evaluating alerts")
+		} else {
+			s.log.InfoContext(ctx, "alerting left to another replica")
+		}
+	}
+	if !held {
+		if s.alerts != nil {
+			s.alerts.reset()
+		}
+		if s.failures != nil {
+			s.failures.standby(time.Now().Add(-s.alertingLockTTL()))
+		}
+	}
+	return held
+}
+
+// Alerts returns the alert rules, the alerts that are pending or firing and
+// the failure notification rules
+func (s *Service) Alerts() AlertsStatus {
+	status := AlertsStatus{Rules: []AlertRuleStatus{}, Alerts: []Alert{}, Failures: []FailureRuleStatus{}}
+	status.Enabled = s.webhooks != nil
+	status.Evaluating = s.evaluating.Load()
+	if s.failures != nil {
+		status.Failures = s.failures.status()
+	}
+	if s.alerts == nil {
+		return status
+	}
+
+	a := s.alerts
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, r := range a.cfg.Rules {
+		status.Rules = append(status.Rules, *a.rules[r.Name])
+	}
+	for _, alert := range a.active {
+		status.Alerts = append(status.Alerts, *alert)
+	}
+	sort.Slice(status.Alerts, func(i, j int) bool {
+		if status.Alerts[i].Rule != status.Alerts[j].Rule {
+			return status.Alerts[i].Rule < status.Alerts[j].Rule
+		}
+		return status.Alerts[i].Fingerprint < status.Alerts[j].Fingerprint
+	})
+
+	return status
+}
+
+// reset forgets the alerts and the samples rules were evaluated with
+func (a *alerter) reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.active = make(map[string]*Alert)
+	a.failedCounts = nil
+	a.firstSeen = make(map[string]map[string]time.Time)
+}
+
+// evaluate checks every rule once and updates the alerts
+func (a *alerter) evaluate(parent context.Context) {
+	// Rules left when the interval runs out report the timeout as their error
+	ctx, cancel := context.WithTimeout(parent, a.cfg.Interval.Duration)
+	defer cancel()
+
+	silences := a.svc.activeSilences(ctx)
+	for _, rule := range a.cfg.Rules {
+		samples, err := a.evaluateRule(ctx, rule)
+		if parent.Err() != nil {
+			return
+		}
+		a.update(rule, samples, err, silences, time.Now())
+	}
+}
+
+// evaluateRule returns the instances of a rule whose condition holds
+func (a *alerter) evaluateRule(ctx context.Context, rule config.AlertRule) (_ []alertSample, err error) {
+	ctx, span := startSpan(ctx, "EvaluateAlertRule",
+		attribute.String("rule", rule.Name),
+		attribute.String("type", rule.Type),
+	)
+	defer func() { endSpan(span, err) }()
+
+	switch rule.Type {
+	case config.AlertPendingDepth:
+		return a.evalPendingDepth(ctx, rule)
+	case config.AlertFailedDelta:
+		return a.evalFailedDelta(ctx, rule)
+	case config.AlertOldestPendingAge:
+		return a.evalOldestPending(ctx, rule)
+	case config.AlertChainFailed:
+		return a.evalChainsFailed(ctx)
+	case config.AlertGroupFailed:
+		return a.evalGroupsFailed(ctx)
+	default:
+		return nil, fmt.Errorf("unsupported rule type: %s", rule.Type)
+	}
+}
+
+// update moves the rule's alerts through their states and sends
+// notifications. A failed evaluation leaves the alerts as they were, so
+// backend outages don't resolve and re-fire everything. Silenced alerts
+// still change state, but are only notified once no silence matches.
+func (a *alerter) update(rule config.AlertRule, samples []alertSample, err error, silences []Silence, now time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	status := a.rules[rule.Name]
+	status.LastEvaluation = now
+	if err != nil {
+		status.Error = err.Error()
+		a.svc.log.Error("failed to evaluate alert rule", "rule", rule.Name, "error", err)
+		return
+	}
+	status.Error = ""
+
+	seen := make(map[string]bool, len(samples))
+	for _, s := range samples {
+		fp := alertFingerprint(rule.Name, s.labels)
+		seen[fp] = true
+
+		alert, ok := a.active[fp]
+		if !ok {
+			alert = &Alert{
+				Rule:        rule.Name,
+				Type:        rule.Type,
+				Severity:    rule.Severity,
+				Status:      AlertPending,
+				Labels:      s.labels,
+				Threshold:   alertThreshold(rule),
+				Fingerprint: fp,
+				ActiveAt:    now,
+			}
+			a.active[fp] = alert
+		}
+		alert.Value, alert.Summary = s.value, s.summary
+		alert.SilencedBy = silencedBy(silences, rule.Name, s.labels["queue"], "")
+
+		if alert.Status == AlertPending && now.Sub(alert.ActiveAt) >= rule.For.Duration {
+			alert.Status = AlertFiring
+			alert.StartsAt = &now
+		}
+		if alert.Status == AlertFiring && !alert.notified && len(alert.SilencedBy) == 0 {
+			alert.notified = true
+			a.notify(rule, *alert)
+		}
+	}
+
+	for fp, alert := range a.active {
+		if alert.Rule != rule.Name || seen[fp] {
+			continue
+		}
+		delete(a.active, fp)
+		if alert.notified {
+			alert.Status = AlertResolved
+			alert.EndsAt = &now
+			a.notify(rule, *alert)
+		}
+	}
+}
+
+// notify logs an alert transition and queues its notification
+func (a *alerter) notify(rule config.AlertRule, alert Alert) {
+	a.svc.log.Warn("alert "+alert.Status, "rule", alert.Rule, "labels", alert.Labels,
+		"value", alert.Value, "summary", alert.Summary)
+	a.svc.webhooks.send(rule.Webhooks, WebhookEventAlert, alert)
+}
+
+// ruleQueues returns the queue a rule is limited to, or every queue
+func (a *alerter) ruleQueues(ctx context.Context, rule config.AlertRule) ([]string, error) {
+	if rule.Queue != "" {
+		return []string{rule.Queue}, nil
+	}
+	return a.svc.ListQueues(ctx)
+}
+
+func (a *alerter) evalPendingDepth(ctx context.Context, rule config.AlertRule) ([]alertSample, error) {
+	queues, err := a.ruleQueues(ctx, rule)
+	if err != nil {
+		return nil, err
+	}
+
+	var samples []alertSample
+	for _, q := range queues {
+		count, err := a.svc.server.GetPendingCount(ctx, q)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get pending count of %s: %w", q, err)
+		}
+		if float64(count) > rule.Threshold {
+			samples = append(samples, alertSample{
+				labels:  map[string]string{"queue": q},
+				value:   float64(count),
+				summary: fmt.Sprintf("Queue %s has %d pending jobs (threshold %g)", q, count, rule.Threshold),
+			})
+		}
+	}
+
+	return samples, nil
+}
+
+func (a *alerter) evalFailedDelta(ctx context.Context, rule config.AlertRule) ([]alertSample, error) {
+	window := rule.Window.Duration
+
+	var delta int
+	if a.svc.resultsRedis != nil {
+		n, err := backend.CountStatus(ctx, a.svc.resultsRedis, tasqueue.StatusFailed, time.Now().Add(-window))
+		if err != nil {
+			return nil, fmt.Errorf("failed to count failed jobs: %w", err)
+		}
+		delta = int(n)
+	} else {
+		ids, err := a.svc.server.GetFailed(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get failed jobs: %w", err)
+		}
+		delta = a.failedDelta(len(ids), window, time.Now())
+	}
+
+	if float64(delta) <= rule.Threshold {
+		return nil, nil
+	}
+	return []alertSample{{
+		value:   float64(delta),
+		summary: fmt.Sprintf("%d jobs failed in the last %s (threshold %g)", delta, window, rule.Threshold),
+	}}, nil
+}
+
+// failedDelta records the size of the failed set and returns its growth
+// over the window. Until the samples span the window the growth since the
+// first sample is used. Purges shrink the set, so the growth is never
+// negative.
+func (a *alerter) failedDelta(count int, window time.Duration, now time.Time) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.failedCounts = append(a.failedCounts, countSample{at: now, count: count})
+
+	// Keep the newest sample older than the window as the baseline
+	cutoff := now.Add(-window)
+	i := 0
+	for i+1 < len(a.failedCounts) && !a.failedCounts[i+1].at.After(cutoff) {
+		i++
+	}
+	a.failedCounts = a.failedCounts[i:]
+
+	return max(0, count-a.failedCounts[0].count)
+}
+
+func (a *alerter) evalOldestPending(ctx context.Context, rule config.AlertRule) ([]alertSample, error) {
+	queues, err := a.ruleQueues(ctx, rule)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var samples []alertSample
+	for _, q := range queues {
+		age, err := a.oldestPendingAge(ctx, q, now)
+		if err != nil {
+			return nil, err
+		}
+		if age > rule.MaxAge.Duration {
+			samples = append(samples, alertSample{
+				labels: map[string]string{"queue": q},
+				value:  age.Seconds(),
+				summary: fmt.Sprintf("Oldest pending job in queue %s has waited %s (max %s)",
+					q, age.Round(time.Second), rule.MaxAge.Duration),
+			})
+		}
+	}
+
+	return samples, nil
+}
+
+// oldestPendingAge returns how long the oldest job in a queue has been
+// waiting. Job messages don't record when they were enqueued, so this is
+// the time since the job was first seen near the old end of the queue, or
+// since its ETA when that is earlier. Jobs already waiting when the UI
+// starts are timed from then.
+func (a *alerter) oldestPendingAge(ctx context.Context, queue string, now time.Time) (time.Duration, error) {
+	total, err := a.svc.server.GetPendingCount(ctx, queue)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get pending count of %s: %w", queue, err)
+	}
+
+	// Jobs are pushed onto the front of the list, so the oldest are at the end
+	var jobs []tasqueue.JobMessage
+	if total > 0 {
+		offset := max(0, int(total)-oldestPendingWindow)
+		jobs, _, err = a.svc.server.GetPendingWithPagination(ctx, queue, offset, oldestPendingWindow)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get pending jobs of %s: %w", queue, err)
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	prev := a.firstSeen[queue]
+	seen := make(map[string]time.Time, len(jobs))
+	for _, job := range jobs {
+		if t, ok := prev[job.ID]; ok {
+			seen[job.ID] = t
+		} else {
+			seen[job.ID] = now
+		}
+	}
+	a.firstSeen[queue] = seen
+
+	if len(jobs) == 0 {
+		return 0, nil
+	}
+	oldest := jobs[len(jobs)-1]
+	since := seen[oldest.ID]
+	if oldest.Job != nil && !oldest.Job.Opts.ETA.IsZero() && oldest.Job.Opts.ETA.Before(since) {
+		since = oldest.Job.Opts.ETA
+	}
+
+	return now.Sub(since), nil
+}
+
+func (a *alerter) evalChainsFailed(ctx context.Context) ([]alertSample, error) {
+	ids, err := a.svc.ListChains(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var samples []alertSample
+	for _, id := range ids {
+		chain, err := a.svc.server.GetChain(ctx, id)
+		if errors.Is(err, tasqueue.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get chain %s: %w", id, err)
+		}
+		if chain.Status == tasqueue.StatusFailed {
+			samples = append(samples, alertSample{
+				labels:  map[string]string{"chain": id},
+				value:   1,
+				summary: fmt.Sprintf("Chain %s failed at job %s", id, chain.JobID),
+			})
+		}
+	}
+
+	return samples, nil
+}
+
+func (a *alerter) evalGroupsFailed(ctx context.Context) ([]alertSample, error) {
+	ids, err := a.svc.ListGroups(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var samples []alertSample
+	for _, id := range ids {
+		group, err := a.svc.server.GetGroup(ctx, id)
+		if errors.Is(err, tasqueue.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get group %s: %w", id, err)
+		}
+		if group.Status != tasqueue.StatusFailed {
+			continue
+		}
+
+		failed := 0
+		for _, st := range group.JobStatus {
+			if st == tasqueue.StatusFailed {
+				failed++
+			}
+		}
+		samples = append(samples, alertSample{
+			labels:  map[string]string{"group": id},
+			value:   float64(failed),
+			summary: fmt.Sprintf("Group %s failed: %d of %d jobs failed", id, failed, len(group.JobStatus)),
+		})
+	}
+
+	return samples, nil
+}
+
+// alertThreshold returns the threshold reported with a rule's alerts
+func alertThreshold(rule config.AlertRule) float64 {
+	if rule.Type == config.AlertOldestPendingAge {
+		return rule.MaxAge.Seconds()
+	}
+	return rule.Threshold
+}
+
+// alertFingerprint identifies an alert by its rule and labels
+func alertFingerprint(rule string, labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(rule)
+	for _, k := range keys {
+		b.WriteString("\x00" + k + "=" + labels[k])
+	}
+
+	sum := sha256.Sum256([]byte(b.String()))
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,361 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kalbhor/tasqueue/v2"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/kalbhor/tasqueue-ui/internal/config"
+)
+
+const (
+	// archiveDayLayout names the directory of each archived day
+	archiveDayLayout = "2006-01-02"
+
+	// archiveNoTask names the directory of jobs archived without a message
+	archiveNoTask = "_unknown"
+
+	// defaultArchiveFileSize is the size after which a new archive file is
+	// started when none is configured
+	defaultArchiveFileSize = 64 << 20
+)
+
+// ErrInvalidDay is returned for archive days not formatted as YYYY-MM-DD
+var ErrInvalidDay = errors.New("invalid day (must be YYYY-MM-DD)")
+
+// ArchiveDay lists the tasks archived on a day
+type ArchiveDay struct {
+	Day   string        `json:"day"`
+	Tasks []ArchiveTask `json:"tasks"`
+}
+
+// ArchiveTask is the archive of a task's jobs on a day
+type ArchiveTask struct {
+	Task  string `json:"task"`
+	Files int    `json:"files"`
+	Bytes int64  `json:"bytes"` // Compressed
+}
+
+// ArchivedJob is a job found in the archive
+type ArchivedJob struct {
+	Day  string    `json:"day"`
+	Task string    `json:"task"`
+	File string    `json:"file"` // Relative to the archive directory
+	Job  JobDetail `json:"job"`
+}
+
+// archiver writes jobs to gzip-compressed NDJSON files, one directory per
+// day they finished on and task, in the format of ExportJobs. Each write
+// appends a gzip member to the task's newest file, and a new file is
+// started once it reaches the maximum size.
+type archiver struct {
+	dir         string
+	maxFileSize int64
+	maxAge      time.Duration
+}
+
+// newArchiver creates an archiver for the configured directory
+func newArchiver(cfg config.ArchiveConfig) *archiver {
+	a := &archiver{
+		dir:         cfg.Dir,
+		maxFileSize: cfg.MaxFileSize,
+		maxAge:      cfg.MaxAge.Duration,
+	}
+	if a.maxFileSize == 0 {
+		a.maxFileSize = defaultArchiveFileSize
+	}
+	return a
+}
+
+// taskDir returns the directory name of a task, escaped so any task name
+// is a single path element
+func taskDir(task string) string {
+	if task == "" {
+		return archiveNoTask
+	}
+	name := url.PathEscape(task)
+	if name == "." || name == ".." {
+		name = strings.ReplaceAll(name, ".", "%2E")
+	}
+	return name
+}
+
+// taskName reverses taskDir
+func taskName(dir string) string {
+	if dir == archiveNoTask {
+		return ""
+	}
+	if name, err := url.PathUnescape(dir); err == nil {
+		return name
+	}
+	return dir
+}
+
+// parseDay checks an archive day, so it can be used as a path element
+func parseDay(day string) (time.Time, error) {
+	t, err := time.Parse(archiveDayLayout, day)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q: %w", day, ErrInvalidDay)
+	}
+	return t, nil
+}
+
+// write appends jobs that finished on the same day to their task's archive.
+// The file is synced before write returns, and truncated back on failure
+// so it never ends in a partial gzip member.
+func (a *archiver) write(day time.Time, task string, jobs []JobDetail) (err error) {
+	dir := filepath.Join(a.dir, day.UTC().Format(archiveDayLayout), taskDir(task))
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return fmt.Errorf("failed to create archive directory: %w", err)
+	}
+
+	path, err := a.currentFile(dir)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0o640)
+	if err != nil {
+		return fmt.Errorf("failed to open archive file: %w", err)
+	}
+	defer f.Close()
+
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return fmt.Errorf("failed to open archive file: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			f.Truncate(size)
+		}
+	}()
+
+	gz := gzip.NewWriter(f)
+	enc := json.NewEncoder(gz)
+	for _, job := range jobs {
+		if err := enc.Encode(job); err != nil {
+			return fmt.Errorf("failed to write job %s to %s: %w", job.ID, path, err)
+		}
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("failed to sync %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+// currentFile returns the file of a task's directory to append to: the
+// newest one, or the next one when it's full
+func (a *archiver) currentFile(dir string) (string, error) {
+	files, err := archiveFiles(dir)
+	if err != nil {
+		return "", err
+	}
+	if len(files) == 0 {
+		return filepath.Join(dir, archiveFileName(1)), nil
+	}
+
+	last := files[len(files)-1]
+	fi, err := os.Stat(last)
+	if err != nil {
+		return "", fmt.Errorf("failed to read archive file: %w", err)
+	}
+	if fi.Size() < a.maxFileSize {
+		return last, nil
+	}
+	return filepath.Join(dir, archiveFileName(len(files)+1)), nil
+}
+
+// archiveFileName names the nth file of a task's directory
+func archiveFileName(n int) string {
+	return fmt.Sprintf("part-%05d.ndjson.gz", n)
+}
+
+// archiveFiles returns the archive files of a task's directory, oldest first
+func archiveFiles(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "part-*.ndjson.gz"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list archive files: %w", err)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// prune removes the days that ended more than the maximum age ago, and
+// returns them
+func (a *archiver) prune(now time.Time) ([]string, error) {
+	if a.maxAge <= 0 {
+		return nil, nil
+	}
+
+	entries, err := os.ReadDir(a.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive directory: %w", err)
+	}
+
+	var removed []string
+	for _, e := range entries {
+		day, err := parseDay(e.Name())
+		if err != nil || !e.IsDir() || now.Sub(day.Add(24*time.Hour)) <= a.maxAge {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(a.dir, e.Name())); err != nil {
+			return removed, fmt.Errorf("failed to remove archived day %s: %w", e.Name(), err)
+		}
+		removed = append(removed, e.Name())
+	}
+	return removed, nil
+}
+
+// ListArchive returns the archived days, newest first, with their tasks
+func (s *Service) ListArchive(ctx context.Context) (_ []ArchiveDay, err error) {
+	_, span := startSpan(ctx, "ListArchive")
+	defer func() { endSpan(span, err) }()
+
+	if s.archive == nil {
+		return nil, fmt.Errorf("no archive directory is configured: %w", errors.ErrUnsupported)
+	}
+
+	entries, err := os.ReadDir(s.archive.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []ArchiveDay{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive directory: %w", err)
+	}
+
+	days := []ArchiveDay{}
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		if _, err := parseDay(e.Name()); err != nil || !e.IsDir() {
+			continue
+		}
+
+		tasks, err := os.ReadDir(filepath.Join(s.archive.dir, e.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archived day %s: %w", e.Name(), err)
+		}
+		day := ArchiveDay{Day: e.Name(), Tasks: []ArchiveTask{}}
+		for _, t := range tasks {
+			if !t.IsDir() {
+				continue
+			}
+			files, err := archiveFiles(filepath.Join(s.archive.dir, e.Name(), t.Name()))
+			if err != nil {
+				return nil, err
+			}
+			at := ArchiveTask{Task: taskName(t.Name()), Files: len(files)}
+			for _, f := range files {
+				if fi, err := os.Stat(f); err == nil {
+					at.Bytes += fi.Size()
+				}
+			}
+			day.Tasks = append(day.Tasks, at)
+		}
+		days = append(days, day)
+	}
+	return days, nil
+}
+
+// SearchArchive looks for a job in the files archived on a day. Every file
+// of the day is read until the job is found, so it takes longer on busy days.
+func (s *Service) SearchArchive(ctx context.Context, day, id string) (_ ArchivedJob, err error) {
+	ctx, span := startSpan(ctx, "SearchArchive", attribute.String("day", day), attribute.String("job.id", id))
+	defer func() { endSpan(span, err) }()
+
+	if s.archive == nil {
+		return ArchivedJob{}, fmt.Errorf("no archive directory is configured: %w", errors.ErrUnsupported)
+	}
+	if _, err := parseDay(day); err != nil {
+		return ArchivedJob{}, err
+	}
+
+	tasks, err := os.ReadDir(filepath.Join(s.archive.dir, day))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return ArchivedJob{}, fmt.Errorf("failed to read archived day %s: %w", day, err)
+	}
+
+	// A damaged file doesn't stop the search, but is reported if the job
+	// isn't found elsewhere
+	var damaged error
+	for _, t := range tasks {
+		if !t.IsDir() {
+			continue
+		}
+		files, err := archiveFiles(filepath.Join(s.archive.dir, day, t.Name()))
+		if err != nil {
+			return ArchivedJob{}, err
+		}
+		for _, path := range files {
+			if err := ctx.Err(); err != nil {
+				return ArchivedJob{}, err
+			}
+
+			job, found, err := searchArchiveFile(path, id)
+			if err != nil {
+				s.log.WarnContext(ctx, "failed to read archive file", "file", path, "error", err)
+				damaged = err
+			}
+			if found {
+				rel, _ := filepath.Rel(s.archive.dir, path)
+				return ArchivedJob{Day: day, Task: taskName(t.Name()), File: rel, Job: job}, nil
+			}
+		}
+	}
+
+	if damaged != nil {
+		return ArchivedJob{}, fmt.Errorf("job %s not found on %s, and some files couldn't be read: %w", id, day, damaged)
+	}
+	return ArchivedJob{}, fmt.Errorf("job %s is not archived on %s: %w", id, day, tasqueue.ErrNotFound)
+}
+
+// searchArchiveFile reads an archive file until it finds the job with id.
+// Jobs read before an error are still searched.
+func searchArchiveFile(path, id string) (JobDetail, bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return JobDetail{}, false, err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return JobDetail{}, false, err
+	}
+	defer gz.Close()
+
+	r := bufio.NewReader(gz)
+	for {
+		line, err := r.ReadBytes('\n')
+		if bytes.Contains(line, []byte(id)) {
+			var job JobDetail
+			if jerr := json.Unmarshal(line, &job); jerr == nil && job.ID == id {
+				return job, true, nil
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return JobDetail{}, false, nil
+		}
+		if err != nil {
+			return JobDetail{}, false, err
+		}
+	}
+}
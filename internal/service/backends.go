@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/kalbhor/tasqueue/v2"
+	inmemorybroker "github.com/kalbhor/tasqueue/v2/brokers/in-memory"
+	natsbroker "github.com/kalbhor/tasqueue/v2/brokers/nats-js"
+	inmemoryresults "github.com/kalbhor/tasqueue/v2/results/in-memory"
+	"github.com/nats-io/nats.go"
+
+	"github.com/kalbhor/tasqueue-ui/internal/backend"
+	"github.com/kalbhor/tasqueue-ui/internal/config"
+)
+
+// pinger checks that a backend is reachable
+type pinger func(ctx context.Context) error
+
+// redisPinger pings a Redis client
+func redisPinger(rdb redis.UniversalClient) pinger {
+	return func(ctx context.Context) error {
+		return rdb.Ping(ctx).Err()
+	}
+}
+
+// newBroker creates the configured broker along with a pinger for it. The
+// Redis client is returned as well for Redis brokers, and is nil otherwise.
+func newBroker(cfg config.BrokerConfig, lo *slog.Logger) (tasqueue.Broker, redis.UniversalClient, pinger, error) {
+	switch cfg.Type {
+	case "redis":
+		rdb, err := backend.NewRedisClient(cfg.Redis)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to create redis client: %w", err)
+		}
+		return backend.NewRedisBroker(rdb, lo), rdb, redisPinger(rdb), nil
+
+	case "nats-js":
+		// No streams are passed so the UI never creates or alters the
+		// streams owned by the workers.
+		b, err := natsbroker.New(natsbroker.Options{
+			URL:         cfg.NATS.URL,
+			EnabledAuth: cfg.NATS.Username != "",
+			Username:    cfg.NATS.Username,
+			Password:    cfg.NATS.Password,
+		}, lo)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to create nats broker: %w", err)
+		}
+
+		// The broker doesn't expose its connection, so a separate one is
+		// kept for health checks.
+		var opts []nats.Option
+		if cfg.NATS.Username != "" {
+			opts = append(opts, nats.UserInfo(cfg.NATS.Username, cfg.NATS.Password))
+		}
+		nc, err := nats.Connect(cfg.NATS.URL, opts...)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to connect to nats: %w", err)
+		}
+		return b, nil, nc.FlushWithContext, nil
+
+	case "in-memory":
+		return inmemorybroker.New(), nil, nil, nil
+
+	default:
+		return nil, nil, nil, fmt.Errorf("unsupported broker type: %s", cfg.Type)
+	}
+}
+
+// newResults creates the configured results store along with a pinger for
+// it. The Redis client is returned as well for Redis stores, and is nil otherwise.
+func newResults(cfg config.ResultsConfig, lo *slog.Logger) (tasqueue.Results, redis.UniversalClient, pinger, error) {
+	switch cfg.Type {
+	case "redis":
+		rdb, err := backend.NewRedisClient(cfg.Redis)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to create redis client: %w", err)
+		}
+		return backend.NewRedisResults(rdb, lo), rdb, redisPinger(rdb), nil
+
+	case "in-memory":
+		return inmemoryresults.New(), nil, nil, nil
+
+	default:
+		return nil, nil, nil, fmt.Errorf("unsupported results type: %s", cfg.Type)
+	}
+}
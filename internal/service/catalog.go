@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/kalbhor/tasqueue-ui/internal/backend"
+	"github.com/kalbhor/tasqueue-ui/internal/jsonschema"
+	"github.com/kalbhor/tasqueue-ui/worker"
+)
+
+// Task catalog sources, from lowest to highest precedence
+const (
+	CatalogRegistered = "registered" // Registered with tasqueue, which only records the queue and concurrency
+	CatalogWorker     = "worker"     // Published by a worker with worker.Describe
+	CatalogConfig     = "config"     // From the -tasks-config file
+)
+
+// ErrInvalidPayload is returned when a job's payload doesn't match the
+// schema of its task
+var ErrInvalidPayload = errors.New("invalid payload")
+
+// CatalogTask is a task in the task catalog
+type CatalogTask struct {
+	worker.TaskSpec
+	Concurrency uint32 `json:"concurrency,omitempty"`
+
+	// Source is where the task's description comes from: config, worker or
+	// registered. Fields missing from it are filled in from the others.
+	Source string `json:"source"`
+}
+
+// TaskCatalog returns every known task, by name. Tasks are merged from the
+// tasks registered with tasqueue, those published by workers and the
+// -tasks-config file, in increasing order of precedence.
+func (s *Service) TaskCatalog(ctx context.Context) (_ []CatalogTask, err error) {
+	ctx, span := startSpan(ctx, "TaskCatalog")
+	defer func() { endSpan(span, err) }()
+
+	catalog, err := s.catalog(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tasks := make([]CatalogTask, 0, len(catalog))
+	for _, t := range catalog {
+		tasks = append(tasks, t)
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].Name < tasks[j].Name
+	})
+	return tasks, nil
+}
+
+// GetCatalogTask returns a single task from the task catalog
+func (s *Service) GetCatalogTask(ctx context.Context, name string) (_ CatalogTask, err error) {
+	ctx, span := startSpan(ctx, "GetCatalogTask")
+	defer func() { endSpan(span, err) }()
+
+	catalog, err := s.catalog(ctx)
+	if err != nil {
+		return CatalogTask{}, err
+	}
+	t, ok := catalog[name]
+	if !ok {
+		return CatalogTask{}, fmt.Errorf("%w: %s", ErrTaskNotFound, name)
+	}
+	return t, nil
+}
+
+// catalog merges the task catalog's sources
+func (s *Service) catalog(ctx context.Context) (map[string]CatalogTask, error) {
+	registered, err := s.server.GetTasks()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get registered tasks: %w", err)
+	}
+
+	catalog := make(map[string]CatalogTask, len(registered)+len(s.config.Catalog.Tasks))
+	for _, t := range registered {
+		catalog[t.Name] = CatalogTask{
+			TaskSpec:    worker.TaskSpec{Name: t.Name, Queue: t.Queue},
+			Concurrency: t.Concurrency,
+			Source:      CatalogRegistered,
+		}
+	}
+
+	if s.resultsRedis != nil {
+		published, err := backend.GetCatalog(ctx, s.resultsRedis)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get published tasks: %w", err)
+		}
+		for name, v := range published {
+			var spec worker.TaskSpec
+			if err := json.Unmarshal([]byte(v), &spec); err != nil {
+				s.log.WarnContext(ctx, "skipping undecodable published task", "task", name, "error", err)
+				continue
+			}
+			spec.Name = name
+			mergeTask(catalog, spec, CatalogWorker)
+		}
+	}
+
+	for _, spec := range s.config.Catalog.Tasks {
+		mergeTask(catalog, spec, CatalogConfig)
+	}
+
+	return catalog, nil
+}
+
+// mergeTask overlays the set fields of a task description from a higher
+// precedence source
+func mergeTask(catalog map[string]CatalogTask, spec worker.TaskSpec, source string) {
+	t := catalog[spec.Name]
+	t.Name, t.Source = spec.Name, source
+	if spec.Description != "" {
+		t.Description = spec.Description
+	}
+	if spec.Owner != "" {
+		t.Owner = spec.Owner
+	}
+	if spec.Queue != "" {
+		t.Queue = spec.Queue
+	}
+	if len(spec.Schema) > 0 {
+		t.Schema = spec.Schema
+	}
+	catalog[spec.Name] = t
+}
+
+// payloadValidator checks payloads against the schemas of the task catalog,
+// compiling each schema once
+type payloadValidator struct {
+	catalog  map[string]CatalogTask
+	compiled map[string]*jsonschema.Schema
+}
+
+// newPayloadValidator reads the task catalog for validating payloads
+func (s *Service) newPayloadValidator(ctx context.Context) (*payloadValidator, error) {
+	catalog, err := s.catalog(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &payloadValidator{catalog: catalog, compiled: make(map[string]*jsonschema.Schema)}, nil
+}
+
+// validate checks a payload against its task's schema. Payloads of tasks
+// without a schema are always valid.
+func (v *payloadValidator) validate(task string, payload []byte) error {
+	t := v.catalog[task]
+	if len(t.Schema) == 0 {
+		return nil
+	}
+
+	sc, ok := v.compiled[task]
+	if !ok {
+		var err error
+		if sc, err = jsonschema.Compile(t.Schema); err != nil {
+			return fmt.Errorf("task %s has an invalid schema: %w", task, err)
+		}
+		v.compiled[task] = sc
+	}
+
+	if err := sc.Validate(payload); err != nil {
+		return fmt.Errorf("%w for task %s: %v", ErrInvalidPayload, task, err)
+	}
+	return nil
+}
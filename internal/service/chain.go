@@ -0,0 +1,387 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kalbhor/tasqueue/v2"
+	"github.com/vmihailenco/msgpack/v5"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/kalbhor/tasqueue-ui/worker"
+)
+
+// JobMissing is the status of a chain step or group job that was enqueued
+// but is no longer in the results store
+const JobMissing = "missing"
+
+const (
+	// chainMsgKey prefixes chain message keys in the results store,
+	// relative to backend.ResultPrefix
+	chainMsgKey = "chain:msg:"
+
+	// chainResumesKey prefixes the keys recording how a chain was resumed,
+	// relative to backend.ResultPrefix
+	chainResumesKey = "chain:resumes:"
+
+	// chainLockPrefix names the lock held while a chain is resumed
+	chainLockPrefix = "chain:"
+)
+
+// ErrNotResumable is returned when resuming a chain that hasn't failed
+var ErrNotResumable = errors.New("only failed chains can be resumed")
+
+// ChainResume is the outcome of resuming a failed chain
+type ChainResume struct {
+	ChainID string `json:"chain_id"`
+	Failed  string `json:"failed"` // ID of the failed job
+	Skipped bool   `json:"skipped,omitempty"`
+
+	// JobID is the job enqueued to continue the chain: a copy of the
+	// failed job, or the next step when skipping it. It is empty when the
+	// skipped step was the last one.
+	JobID string    `json:"job_id,omitempty"`
+	At    time.Time `json:"at"`
+}
+
+// ChainStep is a job of a chain, whether or not it has been enqueued yet
+type ChainStep struct {
+	Position int    `json:"position"`         // 1 for the first job of the chain
+	JobID    string `json:"job_id,omitempty"` // Empty until the step is enqueued
+	Task     string `json:"task,omitempty"`
+	Queue    string `json:"queue,omitempty"`
+
+	// Status is the job's status, or not_enqueued, skipped or missing for
+	// steps that aren't in the results store
+	Status  string `json:"status"`
+	Retried uint32 `json:"retried,omitempty"`
+	Error   string `json:"error,omitempty"`
+
+	// ResumedFrom lists the failed jobs of the step the chain was resumed
+	// from, oldest first
+	ResumedFrom []string `json:"resumed_from,omitempty"`
+
+	ProcessedAt *time.Time     `json:"processed_at,omitempty"`
+	Timing      *worker.Timing `json:"timing,omitempty"`      // Only set for tasks run with worker.Timed
+	DurationMS  float64        `json:"duration_ms,omitempty"` // Processing time from Timing
+	Result      []byte         `json:"result,omitempty"`
+}
+
+// chainSteps reconstructs every step of a chain. Enqueued steps are found
+// by following each job's on-success IDs from the first job, as the
+// chain's own record of its jobs lags behind; the rest come from the job
+// definitions nested in the last enqueued job. It also returns the job
+// messages of the enqueued steps.
+func (s *Service) chainSteps(ctx context.Context, chain tasqueue.ChainMessage) ([]ChainStep, []tasqueue.JobMessage, error) {
+	id := chain.JobID
+	if len(chain.PrevJobs) > 0 {
+		id = chain.PrevJobs[0]
+	}
+
+	resumes, err := s.chainResumes(ctx, chain.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	resumed := make(map[string]ChainResume, len(resumes))
+	for _, r := range resumes {
+		resumed[r.Failed] = r
+	}
+
+	var (
+		steps       = make([]ChainStep, 0)
+		jobs        = make([]tasqueue.JobMessage, 0)
+		seen        = make(map[string]bool)
+		next        *tasqueue.Job
+		ended       string   // Status of the step the walk stopped at
+		resumedFrom []string // Failed jobs of the current step
+	)
+	for id != "" && !seen[id] && len(steps) < maxGraphNodes {
+		seen[id] = true
+		step := ChainStep{Position: len(steps) + 1, JobID: id, ResumedFrom: resumedFrom}
+		resumedFrom = nil
+
+		msg, err := s.server.GetJob(ctx, id)
+		if errors.Is(err, tasqueue.ErrNotFound) {
+			step.Status = JobMissing
+			steps = append(steps, step)
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get chain job %s: %w", id, err)
+		}
+		jobs = append(jobs, msg)
+
+		if err := s.fillChainStep(ctx, &step, msg); err != nil {
+			return nil, nil, err
+		}
+
+		// A resumed step continues with the job enqueued in its place, a
+		// skipped one with the next step
+		if r, ok := resumed[id]; ok && msg.Status == tasqueue.StatusFailed {
+			id, next = r.JobID, nil
+			if !r.Skipped {
+				resumedFrom = append(step.ResumedFrom, step.JobID)
+				continue
+			}
+			step.Status = JobSkipped
+			steps = append(steps, step)
+			continue
+		}
+
+		steps = append(steps, step)
+		ended = msg.Status
+
+		id, next = "", nil
+		if msg.Status == tasqueue.StatusDone && len(msg.OnSuccessIDs) > 0 {
+			id = msg.OnSuccessIDs[0]
+		} else if msg.Job != nil && len(msg.Job.OnSuccess) > 0 {
+			next = msg.Job.OnSuccess[0]
+		}
+	}
+
+	status := JobNotEnqueued
+	if ended == tasqueue.StatusFailed || ended == JobCancelled {
+		status = JobSkipped
+	}
+	for ; next != nil && len(steps) < maxGraphNodes; next = firstOnSuccess(next) {
+		steps = append(steps, ChainStep{
+			Position: len(steps) + 1,
+			JobID:    next.Opts.ID,
+			Task:     next.Task,
+			Queue:    next.Opts.Queue,
+			Status:   status,
+		})
+	}
+
+	return steps, jobs, nil
+}
+
+// fillChainStep sets a step's details from its job message, timing and
+// result
+func (s *Service) fillChainStep(ctx context.Context, step *ChainStep, msg tasqueue.JobMessage) error {
+	step.Queue, step.Status = msg.Queue, msg.Status
+	step.Retried, step.Error = msg.Retried, msg.PrevErr
+	if msg.Job != nil {
+		step.Task = msg.Job.Task
+	}
+	if !msg.ProcessedAt.IsZero() {
+		at := msg.ProcessedAt
+		step.ProcessedAt = &at
+	}
+
+	b, err := s.server.GetResult(ctx, worker.TimingKey(msg.ID))
+	switch {
+	case err == nil:
+		var t worker.Timing
+		if json.Unmarshal(b, &t) == nil {
+			step.Timing = &t
+			step.DurationMS = float64(t.Duration()) / float64(time.Millisecond)
+		}
+	case !errors.Is(err, tasqueue.ErrNotFound):
+		return fmt.Errorf("failed to get timing of job %s: %w", msg.ID, err)
+	}
+
+	b, err = s.server.GetResult(ctx, msg.ID)
+	switch {
+	case err == nil:
+		step.Result = b
+	case !errors.Is(err, tasqueue.ErrNotFound):
+		return fmt.Errorf("failed to get result of job %s: %w", msg.ID, err)
+	}
+
+	return nil
+}
+
+// blockedAt returns the position of the first step that hasn't succeeded
+// or been skipped, or 0 when there is none
+func blockedAt(steps []ChainStep) int {
+	for _, st := range steps {
+		if st.Status != tasqueue.StatusDone && st.Status != JobSkipped {
+			return st.Position
+		}
+	}
+	return 0
+}
+
+// firstOnSuccess returns the job a chain continues with after job
+func firstOnSuccess(job *tasqueue.Job) *tasqueue.Job {
+	if len(job.OnSuccess) == 0 {
+		return nil
+	}
+	return job.OnSuccess[0]
+}
+
+// ResumeChain continues a failed chain by enqueueing a copy of its failed
+// job, which carries the rest of the chain with it. With skip, the step
+// after the failed one is enqueued instead. Either way the job gets the
+// result of the step before the failed one, as tasqueue would pass it.
+func (s *Service) ResumeChain(ctx context.Context, id string, skip bool) (_ ChainResume, err error) {
+	ctx, span := startSpan(ctx, "ResumeChain", attribute.String("chain.id", id), attribute.Bool("skip", skip))
+	defer func() { endSpan(span, err) }()
+
+	// Hold the chain while it's resumed, so a concurrent resume sees it
+	// resumed rather than enqueueing the step again
+	unlock, err := s.lock(ctx, chainLockPrefix+id, time.Minute)
+	if err != nil {
+		return ChainResume{}, fmt.Errorf("chain %s: %w", id, err)
+	}
+	defer unlock()
+
+	chain, err := s.server.GetChain(ctx, id)
+	if err != nil {
+		return ChainResume{}, fmt.Errorf("failed to get chain: %w", err)
+	}
+	if chain.Status != tasqueue.StatusFailed {
+		return ChainResume{}, fmt.Errorf("chain %s is %s: %w", id, chain.Status, ErrNotResumable)
+	}
+
+	steps, _, err := s.chainSteps(ctx, chain)
+	if err != nil {
+		return ChainResume{}, fmt.Errorf("failed to get steps of chain %s: %w", id, err)
+	}
+	// A chain already resumed from its failed job is blocked at the job
+	// enqueued in its place, even if saving the chain failed
+	at := blockedAt(steps)
+	if at == 0 || steps[at-1].Status != tasqueue.StatusFailed {
+		return ChainResume{}, fmt.Errorf("chain %s has no failed job to resume from: %w", id, ErrNotResumable)
+	}
+
+	failed, err := s.server.GetJob(ctx, steps[at-1].JobID)
+	if err != nil {
+		return ChainResume{}, fmt.Errorf("failed to get failed job: %w", err)
+	}
+	if failed.Job == nil {
+		return ChainResume{}, fmt.Errorf("job %s has no task to resume", failed.ID)
+	}
+
+	// The job's ID is chosen up front so the resume can be recorded before
+	// it's enqueued. A copy gets a new ID so it doesn't overwrite the
+	// failed job.
+	var job *tasqueue.Job
+	if !skip {
+		cp := *failed.Job
+		cp.Opts.ID = uuid.NewString()
+		job = &cp
+	} else if next := firstOnSuccess(failed.Job); next != nil {
+		cp := *next
+		if cp.Opts.ID == "" {
+			cp.Opts.ID = uuid.NewString()
+		}
+		job = &cp
+	}
+
+	res := ChainResume{ChainID: id, Failed: failed.ID, Skipped: skip, At: time.Now()}
+	if job != nil {
+		v, err := s.newPayloadValidator(ctx)
+		if err != nil {
+			return ChainResume{}, err
+		}
+		if err := v.validate(job.Task, job.Payload); err != nil {
+			return ChainResume{}, err
+		}
+		res.JobID = job.Opts.ID
+	}
+
+	// Record the resume first, so a failure after enqueueing can't lead to
+	// the step being enqueued again: chainSteps follows the resume, and the
+	// chain is no longer blocked at a failed job. The record is taken back
+	// if enqueueing fails.
+	resumes, err := s.chainResumes(ctx, id)
+	if err != nil {
+		return ChainResume{}, err
+	}
+	if err := s.saveChainResumes(ctx, id, append(resumes, res)); err != nil {
+		return ChainResume{}, err
+	}
+	if job != nil {
+		if _, err := s.enqueueWithResult(ctx, *job, failed.PrevJobResult); err != nil {
+			s.log.ErrorContext(ctx, "failed to resume chain", "id", id, "error", err)
+			if rerr := s.saveChainResumes(context.WithoutCancel(ctx), id, resumes); rerr != nil {
+				s.log.ErrorContext(ctx, "failed to take back resume of chain", "id", id, "job_id", res.JobID, "error", rerr)
+			}
+			return ChainResume{}, fmt.Errorf("failed to enqueue job: %w", err)
+		}
+	}
+
+	// Point the chain at the new job, where tasqueue picks up tracking it
+	chain.Status = tasqueue.StatusProcessing
+	if res.JobID == "" {
+		chain.Status = tasqueue.StatusDone
+	} else {
+		chain.JobID = res.JobID
+	}
+	b, err := msgpack.Marshal(chain)
+	if err != nil {
+		return ChainResume{}, fmt.Errorf("failed to encode chain: %w", err)
+	}
+	if err := s.results.Set(ctx, chainMsgKey+id, b); err != nil {
+		return ChainResume{}, fmt.Errorf("failed to save chain: %w", err)
+	}
+
+	s.log.InfoContext(ctx, "resumed chain", "id", id, "failed", failed.ID, "skipped", skip, "job_id", res.JobID)
+	return res, nil
+}
+
+// chainResumes returns how a chain was resumed, oldest first
+func (s *Service) chainResumes(ctx context.Context, id string) ([]ChainResume, error) {
+	b, err := s.server.GetResult(ctx, chainResumesKey+id)
+	if errors.Is(err, tasqueue.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get resumes of chain %s: %w", id, err)
+	}
+
+	var resumes []ChainResume
+	if err := json.Unmarshal(b, &resumes); err != nil {
+		return nil, fmt.Errorf("failed to decode resumes of chain %s: %w", id, err)
+	}
+	return resumes, nil
+}
+
+// saveChainResumes saves how a chain was resumed, for chainSteps to follow
+func (s *Service) saveChainResumes(ctx context.Context, id string, resumes []ChainResume) error {
+	b, err := json.Marshal(resumes)
+	if err != nil {
+		return fmt.Errorf("failed to encode resumes of chain %s: %w", id, err)
+	}
+	if err := s.results.Set(ctx, chainResumesKey+id, b); err != nil {
+		return fmt.Errorf("failed to save resumes of chain %s: %w", id, err)
+	}
+	return nil
+}
+
+// enqueueWithResult enqueues a job of a chain along with the result of the
+// previous step, which tasqueue's Enqueue has no way to pass. Scheduled
+// jobs go through Enqueue, as only it sets up their next run.
+func (s *Service) enqueueWithResult(ctx context.Context, job tasqueue.Job, prev []byte) (string, error) {
+	if len(prev) == 0 || job.Opts.Schedule != "" {
+		return s.server.Enqueue(ctx, job)
+	}
+
+	msg := tasqueue.JobMessage{Meta: tasqueue.DefaultMeta(job.Opts), Job: &job}
+	msg.Status = tasqueue.StatusStarted
+	msg.PrevJobResult = prev
+
+	b, err := msgpack.Marshal(msg)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode job: %w", err)
+	}
+	if err := s.results.Set(ctx, jobMsgKey+msg.ID, b); err != nil {
+		return "", fmt.Errorf("failed to save job: %w", err)
+	}
+
+	if !job.Opts.ETA.IsZero() {
+		err = s.broker.EnqueueScheduled(ctx, b, msg.Queue, job.Opts.ETA)
+	} else {
+		err = s.broker.Enqueue(ctx, b, msg.Queue)
+	}
+	if err != nil {
+		return "", err
+	}
+	return msg.ID, nil
+}
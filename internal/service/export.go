@@ -0,0 +1,436 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/kalbhor/tasqueue/v2"
+	"github.com/vmihailenco/msgpack/v5"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/kalbhor/tasqueue-ui/internal/backend"
+)
+
+// Export formats
+const (
+	ExportNDJSON = "ndjson"
+	ExportCSV    = "csv"
+)
+
+// StatusPending selects the jobs waiting in a queue, in addition to the
+// successful and failed statuses of the results store
+const StatusPending = "pending"
+
+// exportBatch is the number of jobs loaded from the backends at a time
+const exportBatch = 500
+
+// ExportOptions selects the jobs written by ExportJobs and how
+type ExportOptions struct {
+	JobFilter
+
+	// Status is successful, failed or pending. Pending jobs are read from
+	// Queue, or the default queue when it is empty.
+	Status string
+
+	// Since and Until bound the time jobs were processed, [Since, Until).
+	// Jobs that haven't been processed are excluded when either is set.
+	Since time.Time
+	Until time.Time
+
+	// Format is ndjson or csv
+	Format string
+
+	// Columns selects the fields written, in order. When empty CSV gets
+	// every column and NDJSON the full job message with its result data.
+	Columns []string
+}
+
+// Validate checks the options, filling in defaults
+func (o *ExportOptions) Validate() error {
+	switch o.Status {
+	case tasqueue.StatusDone, tasqueue.StatusFailed:
+	case StatusPending:
+		if o.Queue == "" {
+			o.Queue = tasqueue.DefaultQueue
+		}
+	default:
+		return fmt.Errorf("invalid status: %q (must be successful, failed or pending)", o.Status)
+	}
+
+	switch o.Format {
+	case "":
+		o.Format = ExportNDJSON
+	case ExportNDJSON, ExportCSV:
+	default:
+		return fmt.Errorf("invalid format: %q (must be ndjson or csv)", o.Format)
+	}
+
+	if !o.Since.IsZero() && !o.Until.IsZero() && !o.Since.Before(o.Until) {
+		return fmt.Errorf("since must be before until")
+	}
+
+	for _, c := range o.Columns {
+		if _, ok := exportColumnIndex[c]; !ok {
+			return fmt.Errorf("unknown column: %q (must be one of %s)", c, strings.Join(ExportColumns(), ", "))
+		}
+	}
+
+	return nil
+}
+
+// matchTime reports whether a job was processed within the time bounds
+func (o *ExportOptions) matchTime(msg tasqueue.JobMessage) bool {
+	if o.Since.IsZero() && o.Until.IsZero() {
+		return true
+	}
+	if msg.ProcessedAt.IsZero() {
+		return false
+	}
+	if !o.Since.IsZero() && msg.ProcessedAt.Before(o.Since) {
+		return false
+	}
+	if !o.Until.IsZero() && !msg.ProcessedAt.Before(o.Until) {
+		return false
+	}
+
+	return true
+}
+
+// exportColumn is a field of an exported job
+type exportColumn struct {
+	name  string
+	value func(j JobDetail) any
+}
+
+// exportColumns lists every column in their default order
+var exportColumns = []exportColumn{
+	{"id", func(j JobDetail) any { return j.ID }},
+	{"task", func(j JobDetail) any { return jobField(j, func(job *tasqueue.Job) any { return job.Task }) }},
+	{"queue", func(j JobDetail) any { return j.Queue }},
+	{"status", func(j JobDetail) any { return j.Status }},
+	{"retried", func(j JobDetail) any { return j.Retried }},
+	{"max_retry", func(j JobDetail) any { return j.MaxRetry }},
+	{"error", func(j JobDetail) any { return j.PrevErr }},
+	{"processed_at", func(j JobDetail) any { return exportTime(j.ProcessedAt) }},
+	{"schedule", func(j JobDetail) any { return j.Schedule }},
+	{"eta", func(j JobDetail) any {
+		return jobField(j, func(job *tasqueue.Job) any { return exportTime(job.Opts.ETA) })
+	}},
+	{"timeout", func(j JobDetail) any {
+		return jobField(j, func(job *tasqueue.Job) any { return job.Opts.Timeout.String() })
+	}},
+	{"on_success_ids", func(j JobDetail) any { return j.OnSuccessIDs }},
+	{"on_success_tasks", func(j JobDetail) any {
+		return jobField(j, func(job *tasqueue.Job) any { return jobTasks(job.OnSuccess) })
+	}},
+	{"on_error_tasks", func(j JobDetail) any {
+		return jobField(j, func(job *tasqueue.Job) any { return jobTasks(job.OnError) })
+	}},
+	{"payload", func(j JobDetail) any {
+		return jobField(j, func(job *tasqueue.Job) any { return exportBytes(job.Payload) })
+	}},
+	{"result", func(j JobDetail) any { return exportBytes(j.ResultData) }},
+	{"prev_job_result", func(j JobDetail) any { return exportBytes(j.PrevJobResult) }},
+}
+
+// exportColumnIndex maps column names to their definition
+var exportColumnIndex = func() map[string]exportColumn {
+	m := make(map[string]exportColumn, len(exportColumns))
+	for _, c := range exportColumns {
+		m[c.name] = c
+	}
+	return m
+}()
+
+// ExportColumns returns the names of every export column, in default order
+func ExportColumns() []string {
+	names := make([]string, len(exportColumns))
+	for i, c := range exportColumns {
+		names[i] = c.name
+	}
+	return names
+}
+
+// ExportJobs streams the jobs selected by opts to w and returns the number
+// written. Jobs are loaded and written in batches, so memory use doesn't
+// grow with the number of jobs. If an error occurs part way through, w
+// holds the jobs written so far.
+func (s *Service) ExportJobs(ctx context.Context, w io.Writer, opts ExportOptions) (_ int, err error) {
+	ctx, span := startSpan(ctx, "ExportJobs",
+		attribute.String("status", opts.Status),
+		attribute.String("task", opts.Task),
+		attribute.String("queue", opts.Queue),
+		attribute.String("format", opts.Format),
+	)
+	defer func() { endSpan(span, err) }()
+
+	if err := opts.Validate(); err != nil {
+		return 0, err
+	}
+
+	enc, err := newExportEncoder(w, opts.Format, opts.Columns)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	errDone := errors.New("limit reached")
+	write := func(job JobDetail) error {
+		if !opts.match(job.JobMessage) || !opts.matchTime(job.JobMessage) {
+			return nil
+		}
+		if err := enc.encode(job); err != nil {
+			return fmt.Errorf("failed to write job %s: %w", job.ID, err)
+		}
+		n++
+		if opts.Limit > 0 && n >= opts.Limit {
+			return errDone
+		}
+		return nil
+	}
+
+	if opts.Status == StatusPending {
+		err = s.exportPending(ctx, opts.Queue, enc, write)
+	} else {
+		err = s.exportResults(ctx, opts, enc, write)
+	}
+	if errors.Is(err, errDone) {
+		err = nil
+	}
+	if ferr := enc.flush(); err == nil {
+		err = ferr
+	}
+	if err != nil {
+		s.log.ErrorContext(ctx, "failed to export jobs", "status", opts.Status, "exported", n, "error", err)
+		return n, err
+	}
+
+	s.log.InfoContext(ctx, "exported jobs", "status", opts.Status, "queue", opts.Queue, "task", opts.Task, "format", opts.Format, "exported", n)
+	return n, nil
+}
+
+// exportPending pages through the jobs waiting in a queue. A Redis queue
+// is read oldest first from its stable end, so each job queued when the
+// export started is written at most once; other brokers are paged by
+// offset, so jobs enqueued or consumed meanwhile shift the pages.
+func (s *Service) exportPending(ctx context.Context, queue string, enc *exportEncoder, write func(JobDetail) error) error {
+	if s.brokerRedis != nil {
+		return backend.PendingMessages(ctx, s.brokerRedis, queue, exportBatch, func(msgs []string) error {
+			for _, m := range msgs {
+				var job tasqueue.JobMessage
+				if err := msgpack.Unmarshal([]byte(m), &job); err != nil {
+					return fmt.Errorf("failed to decode pending job: %w", err)
+				}
+				if err := write(JobDetail{JobMessage: job}); err != nil {
+					return err
+				}
+			}
+			return enc.flush()
+		})
+	}
+
+	// Brokers may cap the page size, so advance by the jobs actually returned
+	for offset := 0; ; {
+		jobs, total, err := s.server.GetPendingWithPagination(ctx, queue, offset, exportBatch)
+		if err != nil {
+			return fmt.Errorf("failed to get pending jobs: %w", err)
+		}
+		for _, job := range jobs {
+			if err := write(JobDetail{JobMessage: job}); err != nil {
+				return err
+			}
+		}
+		if err := enc.flush(); err != nil {
+			return err
+		}
+		offset += len(jobs)
+		if len(jobs) == 0 || int64(offset) >= total {
+			return nil
+		}
+	}
+}
+
+// exportResults pages through the successful or failed jobs, loading each
+// job's message and result data
+func (s *Service) exportResults(ctx context.Context, opts ExportOptions, enc *exportEncoder, write func(JobDetail) error) error {
+	writeIDs := func(ids []string) error {
+		for _, id := range ids {
+			msg, err := s.server.GetJob(ctx, id)
+			if errors.Is(err, tasqueue.ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("failed to get job %s: %w", id, err)
+			}
+
+			job := JobDetail{JobMessage: msg}
+			if opts.match(msg) && opts.matchTime(msg) {
+				job.ResultData, err = s.server.GetResult(ctx, id)
+				if err != nil && !errors.Is(err, tasqueue.ErrNotFound) {
+					return fmt.Errorf("failed to get result of job %s: %w", id, err)
+				}
+			}
+			if err := write(job); err != nil {
+				return err
+			}
+		}
+		return enc.flush()
+	}
+
+	if s.resultsRedis != nil {
+		// A job is marked after it's processed, so its mark is never
+		// earlier than Since when its processing time isn't
+		return backend.StatusIDs(ctx, s.resultsRedis, opts.Status, opts.Since, exportBatch, writeIDs)
+	}
+
+	// Other results stores only return the full list of IDs
+	ids, err := s.GetJobsByStatus(ctx, opts.Status)
+	if err != nil {
+		return err
+	}
+	for len(ids) > 0 {
+		batch := ids[:min(exportBatch, len(ids))]
+		ids = ids[len(batch):]
+		if err := writeIDs(batch); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// exportEncoder writes jobs as NDJSON or CSV
+type exportEncoder struct {
+	json    *json.Encoder
+	csv     *csv.Writer
+	columns []exportColumn
+}
+
+// newExportEncoder creates an encoder, writing the CSV header straight away
+func newExportEncoder(w io.Writer, format string, names []string) (*exportEncoder, error) {
+	e := &exportEncoder{}
+	for _, name := range names {
+		e.columns = append(e.columns, exportColumnIndex[name])
+	}
+
+	if format == ExportNDJSON {
+		e.json = json.NewEncoder(w)
+		return e, nil
+	}
+
+	if len(e.columns) == 0 {
+		e.columns = exportColumns
+	}
+	e.csv = csv.NewWriter(w)
+	header := make([]string, len(e.columns))
+	for i, c := range e.columns {
+		header[i] = c.name
+	}
+	if err := e.csv.Write(header); err != nil {
+		return nil, fmt.Errorf("failed to write header: %w", err)
+	}
+
+	return e, nil
+}
+
+// encode writes a single job
+func (e *exportEncoder) encode(job JobDetail) error {
+	if e.json != nil {
+		if len(e.columns) == 0 {
+			return e.json.Encode(job)
+		}
+
+		// Marshal selected columns in order rather than via a map
+		var b strings.Builder
+		b.WriteByte('{')
+		for i, c := range e.columns {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			k, _ := json.Marshal(c.name)
+			v, err := json.Marshal(c.value(job))
+			if err != nil {
+				return err
+			}
+			b.Write(k)
+			b.WriteByte(':')
+			b.Write(v)
+		}
+		b.WriteByte('}')
+		return e.json.Encode(json.RawMessage(b.String()))
+	}
+
+	record := make([]string, len(e.columns))
+	for i, c := range e.columns {
+		switch v := c.value(job).(type) {
+		case []string:
+			record[i] = strings.Join(v, " ")
+		case nil:
+		default:
+			record[i] = fmt.Sprint(v)
+		}
+	}
+	return e.csv.Write(record)
+}
+
+// flush writes out any buffered CSV rows
+func (e *exportEncoder) flush() error {
+	if e.csv == nil {
+		return nil
+	}
+	e.csv.Flush()
+	return e.csv.Error()
+}
+
+// jobField returns a field of the job's task, or nil when the message has none
+func jobField(j JobDetail, fn func(job *tasqueue.Job) any) any {
+	if j.Job == nil {
+		return nil
+	}
+	return fn(j.Job)
+}
+
+// jobTasks returns the task names of a list of jobs
+func jobTasks(jobs []*tasqueue.Job) []string {
+	tasks := make([]string, 0, len(jobs))
+	for _, job := range jobs {
+		if job != nil {
+			tasks = append(tasks, job.Task)
+		}
+	}
+	return tasks
+}
+
+// exportTime formats a timestamp, or returns "" when unset
+func exportTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// exportBytes returns printable data as text and anything else as base64
+// with a "base64:" prefix, so payloads stay readable in spreadsheets
+func exportBytes(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+
+	s := string(b)
+	if utf8.ValidString(s) && strings.IndexFunc(s, func(r rune) bool {
+		return !unicode.IsPrint(r) && !unicode.IsSpace(r)
+	}) < 0 {
+		return s
+	}
+
+	return "base64:" + base64.StdEncoding.EncodeToString(b)
+}
@@ -0,0 +1,312 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kalbhor/tasqueue/v2"
+
+	"github.com/kalbhor/tasqueue-ui/internal/backend"
+	"github.com/kalbhor/tasqueue-ui/internal/config"
+)
+
+// WebhookEventJobFailed is the event header value of failure notifications
+const WebhookEventJobFailed = "job_failed"
+
+// maxFailuresPerCheck caps the failed jobs handled by a single check; the
+// rest are picked up by the next one
+const maxFailuresPerCheck = 1000
+
+// FailureNotification is the body of failed job notifications
+type FailureNotification struct {
+	Rule     string    `json:"rule"`
+	JobID    string    `json:"job_id"`
+	Task     string    `json:"task"`
+	Queue    string    `json:"queue"`
+	Error    string    `json:"error"`
+	Retried  uint32    `json:"retried"`
+	MaxRetry uint32    `json:"max_retry"`
+	FailedAt time.Time `json:"failed_at"`
+	URL      string    `json:"url,omitempty"` // Link to the job in the UI; set when base_url is configured
+
+	// Suppressed counts the identical failures held back since the last
+	// notification
+	Suppressed int `json:"suppressed,omitempty"`
+
+	// Summary is set on the notification sent when a dedup window ends
+	// with failures held back. It describes the last of them, and
+	// Suppressed counts them all.
+	Summary bool `json:"summary,omitempty"`
+}
+
+// FailureRuleStatus reports the notifications sent for a failure rule
+type FailureRuleStatus struct {
+	config.FailureRule
+	Notified         int        `json:"notified"`
+	Suppressed       int        `json:"suppressed"`
+	Silenced         int        `json:"silenced"`
+	LastNotification *time.Time `json:"last_notification,omitempty"`
+}
+
+// failureWatcher finds jobs that failed since the last check by diffing the
+// failed set, and notifies the webhooks of every rule they match. Only
+// failures after the watcher starts are notified.
+type failureWatcher struct {
+	svc *Service
+	cfg config.AlertingConfig
+
+	// cursor is the score of the newest job handled from the Redis failed
+	// set, and atCursor the jobs handled with that score, as scores can tie
+	cursor   float64
+	atCursor map[string]bool
+
+	// failed is the failed set at the last check, for results stores that
+	// can't be read by score. It is nil until the first check.
+	failed map[string]bool
+
+	mu    sync.Mutex
+	rules map[string]*FailureRuleStatus
+	dedup map[string]*dedupEntry // By fingerprint of rule, task, queue and error
+}
+
+// dedupEntry holds back repeats of a notified failure until the window ends
+type dedupEntry struct {
+	rule       config.FailureRule
+	until      time.Time
+	suppressed int
+	last       FailureNotification // The last failure held back
+}
+
+// newFailureWatcher creates a watcher for the configured failure rules
+func newFailureWatcher(svc *Service, cfg config.AlertingConfig) *failureWatcher {
+	w := &failureWatcher{
+		svc:      svc,
+		cfg:      cfg,
+		cursor:   float64(time.Now().UnixNano()),
+		atCursor: make(map[string]bool),
+		rules:    make(map[string]*FailureRuleStatus, len(cfg.Failures)),
+		dedup:    make(map[string]*dedupEntry),
+	}
+	for _, r := range cfg.Failures {
+		w.rules[r.Name] = &FailureRuleStatus{FailureRule: r}
+	}
+
+	return w
+}
+
+// check notifies the jobs that failed since the last check
+func (w *failureWatcher) check(parent context.Context) {
+	ctx, cancel := context.WithTimeout(parent, w.cfg.Interval.Duration)
+	defer cancel()
+
+	silences := w.svc.activeSilences(ctx)
+
+	var err error
+	if w.svc.resultsRedis != nil {
+		err = w.checkSorted(ctx, silences)
+	} else {
+		err = w.checkSet(ctx, silences)
+	}
+	if err != nil && parent.Err() == nil {
+		w.svc.log.Error("failed to check for failed jobs", "error", err)
+	}
+
+	w.prune(time.Now())
+}
+
+// checkSorted reads the Redis failed set from the cursor onwards, oldest
+// first. The cursor only moves past jobs that were handled, so a job that
+// couldn't be read is retried by the next check.
+func (w *failureWatcher) checkSorted(ctx context.Context, silences []Silence) (err error) {
+	ctx, span := startSpan(ctx, "CheckFailedJobs")
+	defer func() { endSpan(span, err) }()
+
+	zs, err := backend.StatusAfter(ctx, w.svc.resultsRedis, tasqueue.StatusFailed, w.cursor, maxFailuresPerCheck)
+	if err != nil {
+		return fmt.Errorf("failed to read failed jobs: %w", err)
+	}
+
+	for _, z := range zs {
+		id, _ := z.Member.(string)
+		if z.Score == w.cursor && w.atCursor[id] {
+			continue
+		}
+		if err := w.handle(ctx, id, time.Unix(0, int64(z.Score)), silences); err != nil {
+			return err
+		}
+
+		if z.Score != w.cursor {
+			w.cursor, w.atCursor = z.Score, make(map[string]bool)
+		}
+		w.atCursor[id] = true
+	}
+
+	return nil
+}
+
+// checkSet diffs the whole failed set against the previous check. The first
+// check only records the set.
+func (w *failureWatcher) checkSet(ctx context.Context, silences []Silence) (err error) {
+	ctx, span := startSpan(ctx, "CheckFailedJobs")
+	defer func() { endSpan(span, err) }()
+
+	ids, err := w.svc.server.GetFailed(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get failed jobs: %w", err)
+	}
+
+	first := w.failed == nil
+	failed := make(map[string]bool, len(ids))
+	handled := 0
+	var handleErr error
+	for _, id := range ids {
+		if !first && !w.failed[id] {
+			// Jobs left out of the set are retried by the next check
+			if handled >= maxFailuresPerCheck {
+				continue
+			}
+			if err := w.handle(ctx, id, time.Now(), silences); err != nil {
+				handleErr = err
+				continue
+			}
+			handled++
+		}
+		failed[id] = true
+	}
+	w.failed = failed
+
+	return handleErr
+}
+
+// handle notifies a failed job to the rules it matches. Jobs deleted since
+// they failed are skipped.
+func (w *failureWatcher) handle(ctx context.Context, id string, failedAt time.Time, silences []Silence) error {
+	job, err := w.svc.server.GetJob(ctx, id)
+	if errors.Is(err, tasqueue.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get job %s: %w", id, err)
+	}
+
+	n := FailureNotification{
+		JobID:    job.ID,
+		Queue:    job.Queue,
+		Error:    job.PrevErr,
+		Retried:  job.Retried,
+		MaxRetry: job.MaxRetry,
+		FailedAt: failedAt,
+	}
+	if job.Job != nil {
+		n.Task = job.Job.Task
+	}
+	if w.cfg.BaseURL != "" {
+		n.URL = strings.TrimRight(w.cfg.BaseURL, "/") + "/#job=" + url.QueryEscape(job.ID)
+	}
+
+	for _, rule := range w.cfg.Failures {
+		if !rule.Matches(n.Task, n.Queue) {
+			continue
+		}
+		n.Rule = rule.Name
+		if ids := silencedBy(silences, rule.Name, n.Queue, n.Task); len(ids) > 0 {
+			w.silenced(rule, n, ids)
+			continue
+		}
+		w.notify(rule, n, time.Now())
+	}
+
+	return nil
+}
+
+// notify queues a failure notification unless an identical failure was
+// notified within the rule's dedup window
+func (w *failureWatcher) notify(rule config.FailureRule, n FailureNotification, now time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	status := w.rules[rule.Name]
+	if rule.Dedup.Duration > 0 {
+		fp := alertFingerprint(rule.Name, map[string]string{"task": n.Task, "queue": n.Queue, "error": n.Error})
+		if d, ok := w.dedup[fp]; ok {
+			if now.Before(d.until) {
+				d.suppressed++
+				d.last = n
+				status.Suppressed++
+				return
+			}
+			n.Suppressed = d.suppressed
+		}
+		w.dedup[fp] = &dedupEntry{rule: rule, until: now.Add(rule.Dedup.Duration)}
+	}
+
+	status.Notified++
+	status.LastNotification = &now
+	w.svc.log.Warn("job failed", "rule", rule.Name, "job", n.JobID, "task", n.Task,
+		"queue", n.Queue, "error", n.Error, "suppressed", n.Suppressed)
+	w.svc.webhooks.send(rule.Webhooks, WebhookEventJobFailed, n)
+}
+
+// silenced records a failure muted by a silence
+func (w *failureWatcher) silenced(rule config.FailureRule, n FailureNotification, ids []string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.rules[rule.Name].Silenced++
+	w.svc.log.Info("job failure silenced", "rule", rule.Name, "job", n.JobID, "task", n.Task,
+		"queue", n.Queue, "silences", ids)
+}
+
+// standby moves the cursor to since and forgets the dedup windows while
+// another replica notifies failures, so on taking over only failures from
+// then on are notified
+func (w *failureWatcher) standby(since time.Time) {
+	w.cursor, w.atCursor = float64(since.UnixNano()), make(map[string]bool)
+
+	w.mu.Lock()
+	w.dedup = make(map[string]*dedupEntry)
+	w.mu.Unlock()
+}
+
+// prune forgets dedup windows that have ended. A window that held back
+// failures first sends a summary of them, so they're reported even if the
+// failure doesn't happen again.
+func (w *failureWatcher) prune(now time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for fp, d := range w.dedup {
+		if now.Before(d.until) {
+			continue
+		}
+		if d.suppressed > 0 {
+			n := d.last
+			n.Suppressed, n.Summary = d.suppressed, true
+
+			status := w.rules[d.rule.Name]
+			status.Notified++
+			status.LastNotification = &now
+			w.svc.log.Warn("jobs failed during dedup window", "rule", d.rule.Name, "task", n.Task,
+				"queue", n.Queue, "error", n.Error, "suppressed", n.Suppressed, "last_job", n.JobID)
+			w.svc.webhooks.send(d.rule.Webhooks, WebhookEventJobFailed, n)
+		}
+		delete(w.dedup, fp)
+	}
+}
+
+// status returns the failure rules and what they have sent
+func (w *failureWatcher) status() []FailureRuleStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	rules := make([]FailureRuleStatus, 0, len(w.cfg.Failures))
+	for _, r := range w.cfg.Failures {
+		rules = append(rules, *w.rules[r.Name])
+	}
+	return rules
+}
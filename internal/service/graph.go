@@ -0,0 +1,431 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kalbhor/tasqueue/v2"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Graph node kinds
+const (
+	NodeJob   = "job"
+	NodeChain = "chain"
+	NodeGroup = "group"
+)
+
+// Graph edge kinds
+const (
+	EdgeChain     = "chain"      // From a chain to its first job, and between consecutive jobs of a chain
+	EdgeOnSuccess = "on_success" // To a job enqueued when the source job succeeds
+	EdgeOnError   = "on_error"   // To a job enqueued when the source job fails
+	EdgeMember    = "member"     // From a group to each of its jobs
+	EdgeResume    = "resume"     // From the failed job of a resumed chain to the job enqueued to resume it
+)
+
+// Statuses of jobs that aren't in the results store
+const (
+	JobNotEnqueued = "not_enqueued" // Will be enqueued if its parent finishes the right way
+	JobSkipped     = "skipped"      // Won't run, as its parent finished the other way
+	JobUntracked   = "untracked"    // Enqueued under an ID tasqueue doesn't record
+)
+
+// maxGraphNodes caps the nodes of a graph, such as for a long running
+// scheduled job that enqueues its next run on success
+const maxGraphNodes = 500
+
+// Graph is a job, chain or group and every job it leads to, as a directed
+// acyclic graph
+type Graph struct {
+	Root      string      `json:"root"`
+	Kind      string      `json:"kind"` // Kind of the root node
+	Nodes     []GraphNode `json:"nodes"`
+	Edges     []GraphEdge `json:"edges"`
+	Truncated bool        `json:"truncated,omitempty"` // Set when the graph was cut off at 500 nodes
+}
+
+// GraphNode is a job, chain or group. Jobs that were never enqueued, or
+// were enqueued under an unknown ID, have a synthetic ID made of their
+// parent's ID, the edge kind and their index.
+type GraphNode struct {
+	ID     string `json:"id"`
+	Kind   string `json:"kind"`
+	Task   string `json:"task,omitempty"`
+	Queue  string `json:"queue,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+
+	synthetic bool // The ID was made up for the graph
+}
+
+// GraphEdge links two nodes
+type GraphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Kind string `json:"kind"`
+}
+
+// GetGraph returns the graph of a job, chain or group and every job it
+// leads to. kind selects what id is; when empty a job, then a chain, then
+// a group with that ID is looked for.
+func (s *Service) GetGraph(ctx context.Context, id, kind string) (_ Graph, err error) {
+	ctx, span := startSpan(ctx, "GetGraph", attribute.String("id", id), attribute.String("kind", kind))
+	defer func() { endSpan(span, err) }()
+
+	b := &graphBuilder{svc: s, seen: make(map[string]bool)}
+	kinds := []string{NodeJob, NodeChain, NodeGroup}
+	if kind != "" {
+		kinds = []string{kind}
+	}
+
+	for _, k := range kinds {
+		switch k {
+		case NodeJob:
+			err = b.addJobID(ctx, id, "", "")
+		case NodeChain:
+			err = b.addChain(ctx, id)
+		case NodeGroup:
+			err = b.addGroup(ctx, id)
+		default:
+			return Graph{}, fmt.Errorf("invalid kind: %q (must be job, chain or group)", k)
+		}
+		if errors.Is(err, tasqueue.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return Graph{}, err
+		}
+
+		b.graph.Root, b.graph.Kind = id, k
+		return b.graph, nil
+	}
+
+	return Graph{}, fmt.Errorf("no job, chain or group %s: %w", id, tasqueue.ErrNotFound)
+}
+
+// graphBuilder walks jobs and their follow-ups into a graph
+type graphBuilder struct {
+	svc   *Service
+	graph Graph
+	seen  map[string]bool
+
+	// resumes are how the chain being walked was resumed, by failed job
+	resumes map[string]ChainResume
+}
+
+// addNode adds a node unless it was already added, and reports whether
+// the node is new and should be walked
+func (b *graphBuilder) addNode(n GraphNode) bool {
+	if b.seen[n.ID] {
+		return false
+	}
+	if len(b.graph.Nodes) >= maxGraphNodes {
+		b.graph.Truncated = true
+		return false
+	}
+	b.seen[n.ID] = true
+	b.graph.Nodes = append(b.graph.Nodes, n)
+	return true
+}
+
+func (b *graphBuilder) addEdge(from, to, kind string) {
+	if from != "" && b.seen[to] {
+		b.graph.Edges = append(b.graph.Edges, GraphEdge{From: from, To: to, Kind: kind})
+	}
+}
+
+// addJobID adds a stored job and its follow-ups, linked from a parent node
+// unless from is empty. The job is reported with tasqueue.ErrNotFound only
+// when it's the root.
+func (b *graphBuilder) addJobID(ctx context.Context, id, from, kind string) error {
+	if b.seen[id] {
+		b.addEdge(from, id, kind)
+		return nil
+	}
+
+	msg, err := b.svc.server.GetJob(ctx, id)
+	if errors.Is(err, tasqueue.ErrNotFound) && from != "" {
+		// Deleted since; keep the link with what is known
+		b.addNode(GraphNode{ID: id, Kind: NodeJob, Status: JobUntracked})
+		b.addEdge(from, id, kind)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return b.addJob(ctx, msg, from, kind)
+}
+
+// addJob adds a stored job and its follow-ups
+func (b *graphBuilder) addJob(ctx context.Context, msg tasqueue.JobMessage, from, kind string) error {
+	n := GraphNode{ID: msg.ID, Kind: NodeJob, Queue: msg.Queue, Status: msg.Status, Error: msg.PrevErr}
+	if msg.Job != nil {
+		n.Task = msg.Job.Task
+	}
+
+	// A resumed chain continues with the job enqueued in place of its
+	// failed job, or with the next step when the failed one was skipped,
+	// and that job carries the rest of the chain
+	succeeded, failed := msg.Status == tasqueue.StatusDone, msg.Status == tasqueue.StatusFailed
+	resume, resumed := b.resumes[msg.ID]
+	resumed = resumed && failed
+	if resumed && resume.Skipped {
+		n.Status = JobSkipped
+	}
+
+	isNew := b.addNode(n)
+	b.addEdge(from, msg.ID, kind)
+	if !isNew || msg.Job == nil {
+		return nil
+	}
+
+	for i, next := range msg.Job.OnSuccess {
+		if resumed {
+			break
+		}
+		edge := EdgeOnSuccess
+		if chainEdge(kind) && i == 0 {
+			edge = EdgeChain
+		}
+
+		if i < len(msg.OnSuccessIDs) {
+			if err := b.addJobID(ctx, msg.OnSuccessIDs[i], msg.ID, edge); err != nil {
+				return err
+			}
+			continue
+		}
+
+		status := JobNotEnqueued
+		if failed {
+			status = JobSkipped
+		}
+		if err := b.addPlanned(ctx, next, msg.ID, edge, i, status); err != nil {
+			return err
+		}
+	}
+
+	for i, next := range msg.Job.OnError {
+		status := JobNotEnqueued
+		switch {
+		case succeeded:
+			status = JobSkipped
+		case failed:
+			status = JobUntracked
+		}
+		if err := b.addPlanned(ctx, next, msg.ID, EdgeOnError, i, status); err != nil {
+			return err
+		}
+	}
+
+	if resumed && resume.JobID != "" {
+		return b.addJobID(ctx, resume.JobID, msg.ID, EdgeResume)
+	}
+	return nil
+}
+
+// chainEdge reports whether a job linked by an edge of kind is a step of
+// a chain, whose first on-success job is the next step
+func chainEdge(kind string) bool {
+	return kind == EdgeChain || kind == EdgeResume
+}
+
+// addPlanned adds a follow-up job known only from its parent's job message.
+// Jobs that were enqueued with a fixed ID are looked up by it.
+func (b *graphBuilder) addPlanned(ctx context.Context, job *tasqueue.Job, from, kind string, i int, status string) error {
+	if job == nil {
+		return nil
+	}
+	if job.Opts.ID != "" && status == JobUntracked {
+		return b.addJobID(ctx, job.Opts.ID, from, kind)
+	}
+
+	id := job.Opts.ID
+	if id == "" {
+		id = from + "/" + kind + "/" + strconv.Itoa(i)
+	}
+	isNew := b.addNode(GraphNode{ID: id, Kind: NodeJob, Task: job.Task, Queue: job.Opts.Queue, Status: status,
+		synthetic: job.Opts.ID == ""})
+	b.addEdge(from, id, kind)
+	if !isNew {
+		return nil
+	}
+
+	// Nothing under a job that hasn't run has run either
+	childStatus := status
+	if status == JobUntracked {
+		childStatus = JobNotEnqueued
+	}
+	for j, next := range job.OnSuccess {
+		edge := EdgeOnSuccess
+		if chainEdge(kind) && j == 0 {
+			edge = EdgeChain
+		}
+		if err := b.addPlanned(ctx, next, id, edge, j, childStatus); err != nil {
+			return err
+		}
+	}
+	for j, next := range job.OnError {
+		if err := b.addPlanned(ctx, next, id, EdgeOnError, j, childStatus); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addChain adds a chain and its jobs from the first one on
+func (b *graphBuilder) addChain(ctx context.Context, id string) error {
+	chain, err := b.svc.server.GetChain(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	resumes, err := b.svc.chainResumes(ctx, chain.ID)
+	if err != nil {
+		return err
+	}
+	b.resumes = make(map[string]ChainResume, len(resumes))
+	for _, r := range resumes {
+		b.resumes[r.Failed] = r
+	}
+
+	b.addNode(GraphNode{ID: chain.ID, Kind: NodeChain, Status: chain.Status})
+	first := chain.JobID
+	if len(chain.PrevJobs) > 0 {
+		first = chain.PrevJobs[0]
+	}
+	if first == "" {
+		return nil
+	}
+	return b.addJobID(ctx, first, chain.ID, EdgeChain)
+}
+
+// addGroup adds a group and its jobs
+func (b *graphBuilder) addGroup(ctx context.Context, id string) error {
+	group, err := b.svc.server.GetGroup(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	b.addNode(GraphNode{ID: group.ID, Kind: NodeGroup, Status: group.Status})
+	ids := make([]string, 0, len(group.JobStatus))
+	for jobID := range group.JobStatus {
+		ids = append(ids, jobID)
+	}
+	sort.Strings(ids)
+	for _, jobID := range ids {
+		if err := b.addJobID(ctx, jobID, group.ID, EdgeMember); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// graphColors are the fill colours of node statuses in DOT and Mermaid
+var graphColors = map[string]string{
+	tasqueue.StatusStarted:    "#e5e7eb",
+	tasqueue.StatusProcessing: "#dbeafe",
+	tasqueue.StatusRetrying:   "#fed7aa",
+	tasqueue.StatusDone:       "#dcfce7",
+	tasqueue.StatusFailed:     "#fee2e2",
+	JobNotEnqueued:            "#ffffff",
+	JobSkipped:                "#f3f4f6",
+	JobUntracked:              "#fef9c3",
+}
+
+// label describes a node over several lines
+func (n GraphNode) label() []string {
+	name := n.Task
+	if n.Kind != NodeJob {
+		name = n.Kind
+	}
+	if n.synthetic {
+		return []string{name, n.Status}
+	}
+	id := n.ID
+	if len(id) > 13 {
+		id = id[:8] + "…"
+	}
+	return []string{name, id, n.Status}
+}
+
+// DOT renders the graph in the Graphviz DOT language
+func (g Graph) DOT() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "digraph %s {\n\trankdir=LR;\n\tnode [shape=box, style=\"rounded,filled\", fontname=\"Helvetica\"];\n", dotQuote(g.Root))
+	for _, n := range g.Nodes {
+		shape := "box"
+		if n.Kind != NodeJob {
+			shape = "ellipse"
+		}
+		style := ""
+		if n.Status == JobNotEnqueued || n.Status == JobSkipped {
+			style = ", style=\"rounded,filled,dashed\""
+		}
+		fmt.Fprintf(&sb, "\t%s [label=%s, shape=%s, fillcolor=%s%s];\n", dotQuote(n.ID),
+			dotQuote(strings.Join(n.label(), "\n")), shape, dotQuote(graphFill(n.Status)), style)
+	}
+	for _, e := range g.Edges {
+		style := ""
+		if e.Kind == EdgeOnError {
+			style = ", style=dashed, color=\"#dc2626\""
+		}
+		fmt.Fprintf(&sb, "\t%s -> %s [label=%s%s];\n", dotQuote(e.From), dotQuote(e.To), dotQuote(e.Kind), style)
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// dotQuote quotes a DOT ID, keeping newlines as line breaks
+func dotQuote(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+	return `"` + s + `"`
+}
+
+// Mermaid renders the graph as a Mermaid flowchart
+func (g Graph) Mermaid() string {
+	ids := make(map[string]string, len(g.Nodes))
+	var sb strings.Builder
+	sb.WriteString("flowchart LR\n")
+	for i, n := range g.Nodes {
+		ids[n.ID] = "n" + strconv.Itoa(i)
+		lines := n.label()
+		for j := range lines {
+			lines[j] = mermaidEscape(lines[j])
+		}
+		open, close := "[", "]"
+		if n.Kind != NodeJob {
+			open, close = "([", "])"
+		}
+		fmt.Fprintf(&sb, "\t%s%s\"%s\"%s\n", ids[n.ID], open, strings.Join(lines, "<br/>"), close)
+	}
+	for _, e := range g.Edges {
+		arrow := "-->"
+		if e.Kind == EdgeOnError {
+			arrow = "-.->"
+		}
+		fmt.Fprintf(&sb, "\t%s %s|%s| %s\n", ids[e.From], arrow, e.Kind, ids[e.To])
+	}
+	for i, n := range g.Nodes {
+		fmt.Fprintf(&sb, "\tstyle n%d fill:%s\n", i, graphFill(n.Status))
+	}
+	return sb.String()
+}
+
+// mermaidEscape escapes text for a quoted Mermaid label
+func mermaidEscape(s string) string {
+	return strings.NewReplacer(`"`, "#quot;", "<", "#lt;", ">", "#gt;").Replace(s)
+}
+
+// graphFill returns the fill colour of a status
+func graphFill(status string) string {
+	if c, ok := graphColors[status]; ok {
+		return c
+	}
+	return "#ffffff"
+}
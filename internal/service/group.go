@@ -0,0 +1,321 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kalbhor/tasqueue/v2"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	// groupRerunsKey prefixes the keys recording the re-runs of a group's
+	// failed jobs, relative to backend.ResultPrefix
+	groupRerunsKey = "group:reruns:"
+
+	// groupRerunOfKey prefixes the keys holding the group a re-run was
+	// made from, relative to backend.ResultPrefix
+	groupRerunOfKey = "group:rerun-of:"
+
+	// groupLockPrefix names the lock held while a group is re-run
+	groupLockPrefix = "group:"
+)
+
+// ErrNoFailedJobs is returned when re-running a group without failed jobs
+// that haven't been re-run already
+var ErrNoFailedJobs = errors.New("no failed jobs to re-run")
+
+// GroupRerun records the failed jobs of a group being re-run as a new group
+type GroupRerun struct {
+	Group      string            `json:"group"`       // Group whose failed jobs were re-run
+	RerunGroup string            `json:"rerun_group"` // Group of the re-run jobs
+	Jobs       map[string]string `json:"jobs"`        // ID of each failed job to the ID of its re-run
+	MaxRetries *uint32           `json:"max_retries,omitempty"`
+	At         time.Time         `json:"at"`
+}
+
+// GroupOutcome is the effective outcome of a group and its re-runs, taking
+// the latest attempt of each job
+type GroupOutcome struct {
+	Status     string               `json:"status"`
+	Successful int                  `json:"successful"`
+	Failed     int                  `json:"failed"`
+	Pending    int                  `json:"pending"`
+	Jobs       []GroupMemberOutcome `json:"jobs"`
+}
+
+// GroupMemberOutcome is a job of a group across its re-runs
+type GroupMemberOutcome struct {
+	JobID    string   `json:"job_id"`   // ID in the original group
+	Attempts []string `json:"attempts"` // IDs of the job and its re-runs, oldest first
+	Status   string   `json:"status"`   // Status of the latest attempt
+}
+
+// RerunGroup enqueues copies of a group's failed jobs as a new group, linked
+// to the original so GetGroup can show their combined outcome. Jobs that
+// were already re-run are left out. maxRetries, when set, replaces the
+// jobs' retry limit.
+func (s *Service) RerunGroup(ctx context.Context, id string, maxRetries *uint32) (_ GroupRerun, err error) {
+	ctx, span := startSpan(ctx, "RerunGroup", attribute.String("group.id", id))
+	defer func() { endSpan(span, err) }()
+
+	// Hold the group while it's re-run, so a concurrent re-run sees its
+	// failed jobs as re-run rather than enqueueing them again
+	unlock, err := s.lock(ctx, groupLockPrefix+id, time.Minute)
+	if err != nil {
+		return GroupRerun{}, fmt.Errorf("group %s: %w", id, err)
+	}
+	defer unlock()
+
+	group, err := s.server.GetGroup(ctx, id)
+	if err != nil {
+		return GroupRerun{}, fmt.Errorf("failed to get group: %w", err)
+	}
+	statuses, err := s.jobStatuses(ctx, group.JobStatus)
+	if err != nil {
+		return GroupRerun{}, err
+	}
+
+	reruns, err := s.groupReruns(ctx, id)
+	if err != nil {
+		return GroupRerun{}, err
+	}
+	rerun := make(map[string]bool)
+	for _, r := range reruns {
+		for old := range r.Jobs {
+			rerun[old] = true
+		}
+	}
+
+	var failed []string
+	for jobID, st := range statuses {
+		if st == tasqueue.StatusFailed && !rerun[jobID] {
+			failed = append(failed, jobID)
+		}
+	}
+	if len(failed) == 0 {
+		return GroupRerun{}, fmt.Errorf("group %s: %w", id, ErrNoFailedJobs)
+	}
+	sort.Strings(failed)
+
+	v, err := s.newPayloadValidator(ctx)
+	if err != nil {
+		return GroupRerun{}, err
+	}
+
+	// Set the IDs of the copies and their group up front, as tasqueue
+	// doesn't say which enqueued job is which, and so the re-run can be
+	// recorded before it's enqueued
+	res := GroupRerun{
+		Group:      id,
+		RerunGroup: uuid.NewString(),
+		Jobs:       make(map[string]string, len(failed)),
+		MaxRetries: maxRetries,
+		At:         time.Now(),
+	}
+	jobs := make([]tasqueue.Job, 0, len(failed))
+	for _, jobID := range failed {
+		msg, err := s.server.GetJob(ctx, jobID)
+		if err != nil {
+			return GroupRerun{}, fmt.Errorf("failed to get job %s: %w", jobID, err)
+		}
+		if msg.Job == nil {
+			return GroupRerun{}, fmt.Errorf("job %s has no task to re-run", jobID)
+		}
+		if err := v.validate(msg.Job.Task, msg.Job.Payload); err != nil {
+			return GroupRerun{}, err
+		}
+
+		job := *msg.Job
+		job.Opts.ID = uuid.NewString()
+		if maxRetries != nil {
+			job.Opts.MaxRetries = *maxRetries
+		}
+		jobs = append(jobs, job)
+		res.Jobs[jobID] = job.Opts.ID
+	}
+
+	g, err := tasqueue.NewGroup(jobs, tasqueue.GroupOpts{ID: res.RerunGroup})
+	if err != nil {
+		return GroupRerun{}, fmt.Errorf("failed to create group: %w", err)
+	}
+
+	// Record the re-run first, so a failure after enqueueing can't lead to
+	// the jobs being re-run again. The record is taken back if enqueueing
+	// fails.
+	if err := s.saveGroupReruns(ctx, id, append(reruns, res)); err != nil {
+		return GroupRerun{}, err
+	}
+	if _, err := s.server.EnqueueGroup(ctx, g); err != nil {
+		s.log.ErrorContext(ctx, "failed to re-run group", "id", id, "error", err)
+		if rerr := s.saveGroupReruns(context.WithoutCancel(ctx), id, reruns); rerr != nil {
+			s.log.ErrorContext(ctx, "failed to take back re-run of group", "id", id, "rerun_group", res.RerunGroup, "error", rerr)
+		}
+		return GroupRerun{}, fmt.Errorf("failed to enqueue group: %w", err)
+	}
+
+	if err := s.results.Set(ctx, groupRerunOfKey+res.RerunGroup, []byte(id)); err != nil {
+		return GroupRerun{}, fmt.Errorf("failed to save re-run of group %s: %w", id, err)
+	}
+
+	s.log.InfoContext(ctx, "re-ran group", "id", id, "rerun_group", res.RerunGroup, "jobs", len(jobs))
+	return res, nil
+}
+
+// groupReruns returns the re-runs of a group's failed jobs, oldest first
+func (s *Service) groupReruns(ctx context.Context, id string) ([]GroupRerun, error) {
+	b, err := s.server.GetResult(ctx, groupRerunsKey+id)
+	if errors.Is(err, tasqueue.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get re-runs of group %s: %w", id, err)
+	}
+
+	var reruns []GroupRerun
+	if err := json.Unmarshal(b, &reruns); err != nil {
+		return nil, fmt.Errorf("failed to decode re-runs of group %s: %w", id, err)
+	}
+	return reruns, nil
+}
+
+// saveGroupReruns records the re-runs of a group's failed jobs
+func (s *Service) saveGroupReruns(ctx context.Context, id string, reruns []GroupRerun) error {
+	b, err := json.Marshal(reruns)
+	if err != nil {
+		return fmt.Errorf("failed to encode re-runs of group %s: %w", id, err)
+	}
+	if err := s.results.Set(ctx, groupRerunsKey+id, b); err != nil {
+		return fmt.Errorf("failed to save re-runs of group %s: %w", id, err)
+	}
+	return nil
+}
+
+// groupRerunOf returns the group a group re-ran the failed jobs of, or ""
+func (s *Service) groupRerunOf(ctx context.Context, id string) (string, error) {
+	b, err := s.server.GetResult(ctx, groupRerunOfKey+id)
+	if errors.Is(err, tasqueue.ErrNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get original of group %s: %w", id, err)
+	}
+	return string(b), nil
+}
+
+// groupOutcome combines a group with its re-runs, and theirs in turn
+func (s *Service) groupOutcome(ctx context.Context, group tasqueue.GroupMessage, reruns []GroupRerun) (*GroupOutcome, error) {
+	statuses, err := s.jobStatuses(ctx, group.JobStatus)
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]GroupMemberOutcome, 0, len(statuses))
+	for jobID := range statuses {
+		members = append(members, GroupMemberOutcome{JobID: jobID, Attempts: []string{jobID}})
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].JobID < members[j].JobID
+	})
+
+	// latest maps each member's latest attempt to the member
+	latest := make(map[string]int, len(members))
+	for i, m := range members {
+		latest[m.JobID] = i
+	}
+
+	seen := map[string]bool{group.ID: true}
+	for len(reruns) > 0 {
+		r := reruns[0]
+		reruns = reruns[1:]
+		if seen[r.RerunGroup] {
+			continue
+		}
+		seen[r.RerunGroup] = true
+
+		g, err := s.server.GetGroup(ctx, r.RerunGroup)
+		if errors.Is(err, tasqueue.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get group %s: %w", r.RerunGroup, err)
+		}
+		st, err := s.jobStatuses(ctx, g.JobStatus)
+		if err != nil {
+			return nil, err
+		}
+		for jobID, status := range st {
+			statuses[jobID] = status
+		}
+
+		for old, id := range r.Jobs {
+			if i, ok := latest[old]; ok {
+				members[i].Attempts = append(members[i].Attempts, id)
+				delete(latest, old)
+				latest[id] = i
+			}
+		}
+
+		nested, err := s.groupReruns(ctx, r.RerunGroup)
+		if err != nil {
+			return nil, err
+		}
+		reruns = append(reruns, nested...)
+	}
+
+	out := &GroupOutcome{Jobs: members}
+	for i := range members {
+		m := &members[i]
+		m.Status = statuses[m.Attempts[len(m.Attempts)-1]]
+		if m.Status == "" {
+			m.Status = JobMissing
+		}
+
+		switch m.Status {
+		case tasqueue.StatusDone:
+			out.Successful++
+		case tasqueue.StatusStarted, tasqueue.StatusProcessing, tasqueue.StatusRetrying:
+			out.Pending++
+		default:
+			out.Failed++
+		}
+	}
+
+	switch {
+	case out.Pending > 0:
+		out.Status = tasqueue.StatusProcessing
+	case out.Failed > 0:
+		out.Status = tasqueue.StatusFailed
+	default:
+		out.Status = tasqueue.StatusDone
+	}
+	return out, nil
+}
+
+// jobStatuses copies a group's job statuses, looking up those that aren't
+// final. Tasqueue stops updating them once any job of the group fails.
+func (s *Service) jobStatuses(ctx context.Context, statuses map[string]string) (map[string]string, error) {
+	out := make(map[string]string, len(statuses))
+	for id, st := range statuses {
+		if st == tasqueue.StatusDone || st == tasqueue.StatusFailed {
+			out[id] = st
+			continue
+		}
+
+		msg, err := s.server.GetJob(ctx, id)
+		switch {
+		case errors.Is(err, tasqueue.ErrNotFound):
+			out[id] = JobMissing
+		case err != nil:
+			return nil, fmt.Errorf("failed to get job %s: %w", id, err)
+		default:
+			out[id] = msg.Status
+		}
+	}
+	return out, nil
+}
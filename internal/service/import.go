@@ -0,0 +1,278 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/kalbhor/tasqueue/v2"
+	"github.com/robfig/cron/v3"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// MaxImportLine is the longest line ImportJobs accepts; longer lines are
+// reported as failed without being buffered in full
+const MaxImportLine = 16 << 20
+
+// Outcomes of an imported line
+const (
+	ImportEnqueued = "enqueued"
+	ImportValid    = "valid" // Passed validation in a dry run
+	ImportFailed   = "failed"
+)
+
+// Rewrite maps values to replacements. The "*" key, if present, replaces
+// every value without an exact match.
+type Rewrite map[string]string
+
+// ParseRewrite parses rewrite rules of the form "from=to", or "to" alone to
+// replace every value
+func ParseRewrite(rules []string) (Rewrite, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	rw := make(Rewrite, len(rules))
+	for _, rule := range rules {
+		from, to, ok := strings.Cut(rule, "=")
+		if !ok {
+			from, to = "*", rule
+		}
+		if from == "" || to == "" {
+			return nil, fmt.Errorf("invalid rewrite rule: %q (must be from=to or to)", rule)
+		}
+		rw[from] = to
+	}
+
+	return rw, nil
+}
+
+// apply returns the replacement of v, or v when no rule matches
+func (rw Rewrite) apply(v string) string {
+	if to, ok := rw[v]; ok {
+		return to
+	}
+	if to, ok := rw["*"]; ok {
+		return to
+	}
+	return v
+}
+
+// ImportOptions controls how ImportJobs replays job messages
+type ImportOptions struct {
+	Queues Rewrite // Rewrites the queue of each job
+	Tasks  Rewrite // Rewrites the task of each job
+
+	// Rate caps the number of jobs enqueued per second; zero means no limit
+	Rate float64
+
+	// DryRun validates and rewrites every line without enqueueing
+	DryRun bool
+}
+
+// ImportResult reports the outcome of a single line
+type ImportResult struct {
+	Line   int    `json:"line"`
+	Status string `json:"status"`           // enqueued, valid or failed
+	ID     string `json:"id,omitempty"`     // ID of the job in the file
+	NewID  string `json:"new_id,omitempty"` // ID of the enqueued job
+	Task   string `json:"task,omitempty"`
+	Queue  string `json:"queue,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ImportSummary counts the outcomes of an import
+type ImportSummary struct {
+	Lines    int  `json:"lines"`
+	Enqueued int  `json:"enqueued"`
+	Valid    int  `json:"valid"`
+	Failed   int  `json:"failed"`
+	DryRun   bool `json:"dry_run"`
+}
+
+// Add counts a line's outcome
+func (s *ImportSummary) Add(res ImportResult) {
+	s.Lines++
+	switch res.Status {
+	case ImportEnqueued:
+		s.Enqueued++
+	case ImportValid:
+		s.Valid++
+	default:
+		s.Failed++
+	}
+}
+
+// ImportJobs reads job messages from r, one JSON object per line as written
+// by ExportJobs, and enqueues a copy of each job with a new ID. Every
+// non-blank line is reported to fn as it's processed; an invalid line is
+// reported as failed and doesn't stop the import. The import stops early
+// only if fn, reading r or ctx fails.
+func (s *Service) ImportJobs(ctx context.Context, r io.Reader, opts ImportOptions, fn func(ImportResult) error) (_ ImportSummary, err error) {
+	ctx, span := startSpan(ctx, "ImportJobs", attribute.Bool("dry_run", opts.DryRun))
+	defer func() { endSpan(span, err) }()
+
+	summary := ImportSummary{DryRun: opts.DryRun}
+	if opts.Rate < 0 {
+		return summary, fmt.Errorf("invalid rate: %v", opts.Rate)
+	}
+
+	v, err := s.newPayloadValidator(ctx)
+	if err != nil {
+		return summary, err
+	}
+
+	var tick <-chan time.Time
+	if opts.Rate > 0 && !opts.DryRun {
+		t := time.NewTicker(time.Duration(float64(time.Second) / opts.Rate))
+		defer t.Stop()
+		tick = t.C
+	}
+
+	br := bufio.NewReaderSize(r, 64<<10)
+	for n := 1; ; n++ {
+		line, err := readLine(br, MaxImportLine)
+		if errors.Is(err, io.EOF) && line == nil {
+			break
+		}
+		if err != nil && !errors.Is(err, errLineTooLong) && !errors.Is(err, io.EOF) {
+			return summary, fmt.Errorf("failed to read line %d: %w", n, err)
+		}
+
+		res := ImportResult{Line: n}
+		var job tasqueue.Job
+		switch {
+		case errors.Is(err, errLineTooLong):
+			res.Error = err.Error()
+		case len(bytes.TrimSpace(line)) == 0:
+			continue
+		default:
+			job, err = prepareImport(line, opts, &res)
+			if err == nil {
+				err = v.validate(job.Task, job.Payload)
+			}
+			if err != nil {
+				res.Error = err.Error()
+			}
+		}
+
+		switch {
+		case res.Error != "":
+			res.Status = ImportFailed
+		case opts.DryRun:
+			res.Status = ImportValid
+		default:
+			if tick != nil {
+				select {
+				case <-ctx.Done():
+					return summary, ctx.Err()
+				case <-tick:
+				}
+			}
+
+			res.NewID, err = s.server.Enqueue(ctx, job)
+			if err != nil {
+				res.Status, res.Error = ImportFailed, fmt.Sprintf("failed to enqueue job: %v", err)
+			} else {
+				res.Status = ImportEnqueued
+			}
+		}
+
+		summary.Add(res)
+		if err := fn(res); err != nil {
+			return summary, err
+		}
+		if err := ctx.Err(); err != nil {
+			return summary, err
+		}
+	}
+
+	s.log.InfoContext(ctx, "imported jobs", "lines", summary.Lines, "enqueued", summary.Enqueued,
+		"failed", summary.Failed, "dry_run", opts.DryRun)
+	return summary, nil
+}
+
+// prepareImport decodes and validates a job message, applies the rewrites
+// and returns the job to enqueue. The result's fields are filled in as far
+// as the line could be read.
+func prepareImport(line []byte, opts ImportOptions, res *ImportResult) (tasqueue.Job, error) {
+	var msg tasqueue.JobMessage
+	if err := json.Unmarshal(line, &msg); err != nil {
+		return tasqueue.Job{}, fmt.Errorf("invalid job message: %w", err)
+	}
+	res.ID = msg.ID
+	if msg.Job == nil {
+		return tasqueue.Job{}, fmt.Errorf("job message has no job")
+	}
+
+	job := *msg.Job
+	job.Task = opts.Tasks.apply(job.Task)
+	if job.Opts.Queue == "" {
+		job.Opts.Queue = msg.Queue
+	}
+	job.Opts.Queue = opts.Queues.apply(job.Opts.Queue)
+	res.Task, res.Queue = job.Task, job.Opts.Queue
+
+	if job.Task == "" {
+		return job, fmt.Errorf("job has no task")
+	}
+	if job.Opts.Queue == "" {
+		return job, fmt.Errorf("job has no queue")
+	}
+	if job.Opts.Timeout < 0 {
+		return job, fmt.Errorf("timeout must not be negative")
+	}
+	if job.Opts.Schedule != "" {
+		if _, err := cron.ParseStandard(job.Opts.Schedule); err != nil {
+			return job, fmt.Errorf("invalid schedule %q: %w", job.Opts.Schedule, err)
+		}
+	}
+
+	// Clear the ID so the copy gets a fresh one instead of overwriting the original
+	job.Opts.ID = ""
+	return job, nil
+}
+
+// errLineTooLong is returned by readLine for lines over the size limit
+var errLineTooLong = fmt.Errorf("line exceeds %d bytes", MaxImportLine)
+
+// readLine returns the next line without its newline. A line longer than
+// max is discarded and reported with errLineTooLong. The last line is
+// returned with io.EOF when it has no trailing newline.
+func readLine(br *bufio.Reader, max int) ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := br.ReadSlice('\n')
+		if len(line)+len(chunk) > max+1 {
+			// Skip the rest of the line
+			for errors.Is(err, bufio.ErrBufferFull) {
+				_, err = br.ReadSlice('\n')
+			}
+			if err != nil && !errors.Is(err, io.EOF) {
+				return nil, err
+			}
+			return []byte{}, errLineTooLong
+		}
+		line = append(line, chunk...)
+
+		switch {
+		case err == nil:
+			return bytes.TrimSuffix(line, []byte("\n")), nil
+		case errors.Is(err, bufio.ErrBufferFull):
+			continue
+		case errors.Is(err, io.EOF):
+			if len(line) == 0 {
+				return nil, io.EOF
+			}
+			return line, io.EOF
+		default:
+			return nil, err
+		}
+	}
+}
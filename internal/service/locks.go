@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/kalbhor/tasqueue-ui/internal/backend"
+)
+
+// ErrLocked is returned when another request, possibly on another replica,
+// is already changing the same chain or group
+var ErrLocked = errors.New("another request is in progress")
+
+// localLocks stands in for the shared locks when the results store isn't
+// Redis, which is only the case for a single in-process UI
+type localLocks struct {
+	mu   sync.Mutex
+	held map[string]bool
+}
+
+// lock takes the named lock for ttl, and returns the function releasing it.
+// It fails with ErrLocked if the lock is held. Locks are shared by every
+// replica using the same results store.
+func (s *Service) lock(ctx context.Context, name string, ttl time.Duration) (func(), error) {
+	if s.resultsRedis == nil {
+		s.locks.mu.Lock()
+		defer s.locks.mu.Unlock()
+		if s.locks.held[name] {
+			return nil, ErrLocked
+		}
+		if s.locks.held == nil {
+			s.locks.held = make(map[string]bool)
+		}
+		s.locks.held[name] = true
+
+		return func() {
+			s.locks.mu.Lock()
+			delete(s.locks.held, name)
+			s.locks.mu.Unlock()
+		}, nil
+	}
+
+	token := uuid.NewString()
+	ok, err := backend.AcquireLock(ctx, s.resultsRedis, name, token, ttl)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire lock %s: %w", name, err)
+	}
+	if !ok {
+		return nil, ErrLocked
+	}
+
+	return func() {
+		// Release the lock even when ctx was cancelled
+		rctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+		if err := backend.ReleaseLock(rctx, s.resultsRedis, name, token); err != nil {
+			s.log.WarnContext(ctx, "failed to release lock", "lock", name, "error", err)
+		}
+	}, nil
+}
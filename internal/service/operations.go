@@ -0,0 +1,214 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kalbhor/tasqueue/v2"
+	"github.com/vmihailenco/msgpack/v5"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/kalbhor/tasqueue-ui/internal/backend"
+)
+
+// JobCancelled is the status of a job cancelled before a worker picked it up
+const JobCancelled = "cancelled"
+
+var (
+	// ErrNotRetryable is returned when retrying a job that hasn't failed
+	ErrNotRetryable = errors.New("only failed jobs can be retried")
+
+	// ErrNotCancellable is returned when cancelling a job that isn't
+	// waiting in its queue
+	ErrNotCancellable = errors.New("only pending jobs can be cancelled")
+)
+
+// JobFilter narrows the jobs returned by ListJobs and removed by PurgeJobs.
+// Empty fields match every job.
+type JobFilter struct {
+	Task  string `json:"task,omitempty"`
+	Queue string `json:"queue,omitempty"`
+	Limit int    `json:"limit,omitempty"` // Maximum number of jobs; zero means no limit
+}
+
+// empty reports whether the filter matches every job
+func (f JobFilter) empty() bool {
+	return f.Task == "" && f.Queue == "" && f.Limit <= 0
+}
+
+// match reports whether a job message passes the task and queue filters
+func (f JobFilter) match(msg tasqueue.JobMessage) bool {
+	if f.Task != "" && (msg.Job == nil || msg.Job.Task != f.Task) {
+		return false
+	}
+	if f.Queue != "" && msg.Queue != f.Queue {
+		return false
+	}
+
+	return true
+}
+
+// ListJobs returns the messages of jobs with the given status (successful
+// or failed) that match the filter, newest first. Jobs whose message has
+// been removed from the results store are skipped.
+func (s *Service) ListJobs(ctx context.Context, status string, f JobFilter) (_ []tasqueue.JobMessage, err error) {
+	ctx, span := startSpan(ctx, "ListJobs",
+		attribute.String("status", status),
+		attribute.String("task", f.Task),
+		attribute.String("queue", f.Queue),
+	)
+	defer func() { endSpan(span, err) }()
+
+	ids, err := s.GetJobsByStatus(ctx, status)
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := []tasqueue.JobMessage{}
+	for _, id := range ids {
+		msg, err := s.server.GetJob(ctx, id)
+		if errors.Is(err, tasqueue.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get job %s: %w", id, err)
+		}
+		if !f.match(msg) {
+			continue
+		}
+
+		jobs = append(jobs, msg)
+		if f.Limit > 0 && len(jobs) >= f.Limit {
+			break
+		}
+	}
+
+	return jobs, nil
+}
+
+// RetryJob enqueues a copy of a failed job with the same task, payload and
+// options, and returns the ID of the new job. The failed job is left as is.
+func (s *Service) RetryJob(ctx context.Context, id string) (_ string, err error) {
+	ctx, span := startSpan(ctx, "RetryJob", attribute.String("job.id", id))
+	defer func() { endSpan(span, err) }()
+
+	msg, err := s.server.GetJob(ctx, id)
+	if err != nil {
+		return "", fmt.Errorf("failed to get job: %w", err)
+	}
+	if msg.Status != tasqueue.StatusFailed {
+		return "", fmt.Errorf("job %s is %s: %w", id, msg.Status, ErrNotRetryable)
+	}
+	if msg.Job == nil {
+		return "", fmt.Errorf("job %s has no task to retry", id)
+	}
+
+	v, err := s.newPayloadValidator(ctx)
+	if err != nil {
+		return "", err
+	}
+	if err := v.validate(msg.Job.Task, msg.Job.Payload); err != nil {
+		return "", err
+	}
+
+	// Clear the ID so the copy gets a fresh one instead of overwriting the original
+	job := *msg.Job
+	job.Opts.ID = ""
+
+	newID, err := s.server.Enqueue(ctx, job)
+	if err != nil {
+		s.log.ErrorContext(ctx, "failed to retry job", "id", id, "error", err)
+		return "", fmt.Errorf("failed to enqueue job: %w", err)
+	}
+
+	s.log.InfoContext(ctx, "retried job", "id", id, "new_id", newID, "task", job.Task)
+	return newID, nil
+}
+
+// CancelJob removes a pending job from its queue, or its queue's scheduled
+// set, and marks it cancelled. Jobs a worker has picked up, even if they
+// haven't started yet, can't be cancelled.
+func (s *Service) CancelJob(ctx context.Context, id string) (err error) {
+	ctx, span := startSpan(ctx, "CancelJob", attribute.String("job.id", id))
+	defer func() { endSpan(span, err) }()
+
+	if s.brokerRedis == nil {
+		return fmt.Errorf("cancelling jobs requires a Redis broker: %w", errors.ErrUnsupported)
+	}
+
+	msg, err := s.server.GetJob(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to get job: %w", err)
+	}
+	if msg.Status != tasqueue.StatusStarted && msg.Status != tasqueue.StatusRetrying {
+		return fmt.Errorf("job %s is %s: %w", id, msg.Status, ErrNotCancellable)
+	}
+
+	removed, err := backend.RemovePending(ctx, s.brokerRedis, msg.Queue, func(raw string) bool {
+		if !strings.Contains(raw, id) {
+			return false
+		}
+		var m tasqueue.JobMessage
+		return msgpack.Unmarshal([]byte(raw), &m) == nil && m.ID == id
+	})
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return fmt.Errorf("job %s was already picked up by a worker: %w", id, ErrNotCancellable)
+	}
+
+	msg.Status = JobCancelled
+	msg.ProcessedAt = time.Now()
+	b, err := msgpack.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to encode job: %w", err)
+	}
+	if err := s.results.Set(ctx, jobMsgKey+id, b); err != nil {
+		return fmt.Errorf("failed to save job: %w", err)
+	}
+
+	s.log.InfoContext(ctx, "cancelled job", "id", id, "queue", msg.Queue)
+	return nil
+}
+
+// PurgeJobs deletes every job with the given status (successful or failed)
+// that matches the filter, and returns the number of jobs deleted
+func (s *Service) PurgeJobs(ctx context.Context, status string, f JobFilter) (_ int, err error) {
+	ctx, span := startSpan(ctx, "PurgeJobs",
+		attribute.String("status", status),
+		attribute.String("task", f.Task),
+		attribute.String("queue", f.Queue),
+	)
+	defer func() { endSpan(span, err) }()
+
+	var ids []string
+	if f.empty() {
+		// Nothing to filter on, so there's no need to load every message
+		ids, err = s.GetJobsByStatus(ctx, status)
+		if err != nil {
+			return 0, err
+		}
+	} else {
+		jobs, err := s.ListJobs(ctx, status, f)
+		if err != nil {
+			return 0, err
+		}
+		for _, job := range jobs {
+			ids = append(ids, job.ID)
+		}
+	}
+
+	for i, id := range ids {
+		if err := s.server.DeleteJob(ctx, id); err != nil {
+			s.log.ErrorContext(ctx, "failed to purge jobs", "status", status, "deleted", i, "error", err)
+			return i, fmt.Errorf("failed to delete job %s: %w", id, err)
+		}
+	}
+
+	s.log.InfoContext(ctx, "purged jobs", "status", status, "task", f.Task, "queue", f.Queue, "deleted", len(ids))
+	return len(ids), nil
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Dependency statuses reported by the readiness check
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// DependencyStatus reports the health of a single backend
+type DependencyStatus struct {
+	Name      string  `json:"name"`
+	Type      string  `json:"type"`
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// ReadinessReport holds the result of checking every backend
+type ReadinessReport struct {
+	Ready        bool               `json:"ready"`
+	Dependencies []DependencyStatus `json:"dependencies"`
+	CheckedAt    time.Time          `json:"checked_at"`
+}
+
+// readinessCache holds the last readiness report. The mutex is held for the
+// duration of a check so concurrent probes share a single round of pings.
+type readinessCache struct {
+	mu     sync.Mutex
+	report ReadinessReport
+}
+
+// CheckReadiness pings the broker and results store, each bounded by the
+// configured timeout. Reports are cached for the configured TTL so frequent
+// probes don't add load to the backends.
+func (s *Service) CheckReadiness(ctx context.Context) ReadinessReport {
+	s.ready.mu.Lock()
+	defer s.ready.mu.Unlock()
+
+	ttl := s.config.Readiness.CacheTTL
+	if !s.ready.report.CheckedAt.IsZero() && time.Since(s.ready.report.CheckedAt) < ttl {
+		return s.ready.report
+	}
+
+	ctx, span := startSpan(ctx, "CheckReadiness")
+	defer span.End()
+
+	// A probe disconnecting mustn't leave a failed report in the cache
+	ctx = context.WithoutCancel(ctx)
+
+	deps := []DependencyStatus{
+		{Name: "broker", Type: s.config.Broker.Type},
+		{Name: "results", Type: s.config.Results.Type},
+	}
+	pings := []pinger{s.brokerPing, s.resultsPing}
+
+	var wg sync.WaitGroup
+	for i := range deps {
+		wg.Add(1)
+		go func(d *DependencyStatus, ping pinger) {
+			defer wg.Done()
+			s.checkDependency(ctx, d, ping)
+		}(&deps[i], pings[i])
+	}
+	wg.Wait()
+
+	report := ReadinessReport{
+		Ready:        true,
+		Dependencies: deps,
+		CheckedAt:    time.Now(),
+	}
+	for _, d := range deps {
+		if d.Status != StatusUp {
+			report.Ready = false
+		}
+	}
+	span.SetAttributes(attribute.Bool("ready", report.Ready))
+
+	s.ready.report = report
+	return report
+}
+
+// checkDependency pings a single backend and records its status and latency.
+// Backends without a pinger live in this process and are always up.
+func (s *Service) checkDependency(ctx context.Context, d *DependencyStatus, ping pinger) {
+	d.Status = StatusUp
+	if ping == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.config.Readiness.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := ping(ctx)
+	d.LatencyMS = float64(time.Since(start).Microseconds()) / 1000
+
+	if err != nil {
+		d.Status = StatusDown
+		d.Error = err.Error()
+		s.log.WarnContext(ctx, "readiness check failed", "dependency", d.Name, "type", d.Type, "error", err)
+	}
+}
@@ -0,0 +1,443 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/kalbhor/tasqueue/v2"
+	"github.com/vmihailenco/msgpack/v5"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/kalbhor/tasqueue-ui/internal/backend"
+	"github.com/kalbhor/tasqueue-ui/internal/config"
+)
+
+// retentionLock is the lock a replica holds while it deletes expired jobs
+const retentionLock = "retention"
+
+// RetentionStatus reports the retention policies and what this replica's
+// janitor has deleted since it started
+type RetentionStatus struct {
+	Enabled  bool                     `json:"enabled"`
+	DryRun   bool                     `json:"dry_run"`
+	Interval string                   `json:"interval,omitempty"`
+	Policies []config.RetentionPolicy `json:"policies"`
+
+	Runs    int64         `json:"runs"`    // Passes run by this replica
+	Skipped int64         `json:"skipped"` // Passes left to another replica holding the lock
+	Errors  int64         `json:"errors"`  // Passes that stopped on an error
+	LastRun *RetentionRun `json:"last_run,omitempty"`
+
+	// Deleted counts the jobs deleted by status and task. In a dry run it
+	// counts the jobs found expired, again on every pass.
+	Deleted []RetentionCount `json:"deleted"`
+}
+
+// RetentionRun is a single pass of the janitor
+type RetentionRun struct {
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Scanned    int       `json:"scanned"` // Jobs old enough for at least one policy
+	Deleted    int       `json:"deleted"`
+	Error      string    `json:"error,omitempty"`
+
+	// Jobs counts the jobs deleted, or found expired in a dry run, by
+	// status and task
+	Jobs []RetentionCount `json:"jobs"`
+
+	counts map[retentionKey]int64
+}
+
+// RetentionCount is the number of jobs with a status and task deleted
+type RetentionCount struct {
+	Status string `json:"status"`
+	Task   string `json:"task"`
+	Jobs   int64  `json:"jobs"`
+}
+
+// retentionKey identifies the counts of RetentionStatus.Deleted
+type retentionKey struct {
+	status, task string
+}
+
+// janitor deletes finished jobs once their retention policy expires. Each
+// pass walks the success and failed sets oldest first, up to the shortest
+// max age of their policies, and deletes the jobs whose own policy has
+// expired in batches, pausing after each. Replicas share a lock in the
+// results store, so only one of them runs a pass at a time.
+type janitor struct {
+	svc *Service
+	cfg config.RetentionConfig
+
+	mu      sync.Mutex
+	runs    int64
+	skipped int64
+	errors  int64
+	lastRun *RetentionRun
+	deleted map[retentionKey]int64
+}
+
+// newJanitor creates a janitor for the configured policies
+func newJanitor(svc *Service, cfg config.RetentionConfig) *janitor {
+	return &janitor{
+		svc:     svc,
+		cfg:     cfg,
+		deleted: make(map[retentionKey]int64),
+	}
+}
+
+// RunRetention deletes expired jobs every interval until ctx is done. It
+// returns straight away when no policies are configured.
+func (s *Service) RunRetention(ctx context.Context) {
+	if s.janitor == nil {
+		return
+	}
+
+	cfg := s.config.Retention
+	s.log.InfoContext(ctx, "retention started", "policies", len(cfg.Policies), "interval", cfg.Interval.Duration,
+		"batch_size", cfg.BatchSize, "batch_interval", cfg.BatchInterval.Duration, "dry_run", cfg.DryRun)
+
+	t := time.NewTicker(cfg.Interval.Duration)
+	defer t.Stop()
+	for {
+		s.janitor.run(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Retention returns the retention policies and the janitor's counts
+func (s *Service) Retention() RetentionStatus {
+	status := RetentionStatus{Policies: []config.RetentionPolicy{}, Deleted: []RetentionCount{}}
+	if s.janitor == nil {
+		return status
+	}
+
+	j := s.janitor
+	status.Enabled = true
+	status.DryRun = j.cfg.DryRun
+	status.Interval = j.cfg.Interval.String()
+	status.Policies = j.cfg.Policies
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	status.Runs, status.Skipped, status.Errors = j.runs, j.skipped, j.errors
+	if j.lastRun != nil {
+		run := *j.lastRun
+		status.LastRun = &run
+	}
+	status.Deleted = retentionCounts(j.deleted)
+
+	return status
+}
+
+// retentionCounts lists counts by status and task, sorted
+func retentionCounts(counts map[retentionKey]int64) []RetentionCount {
+	out := make([]RetentionCount, 0, len(counts))
+	for k, n := range counts {
+		out = append(out, RetentionCount{Status: k.status, Task: k.task, Jobs: n})
+	}
+	sort.Slice(out, func(i, k int) bool {
+		if out[i].Status != out[k].Status {
+			return out[i].Status < out[k].Status
+		}
+		return out[i].Task < out[k].Task
+	})
+	return out
+}
+
+// lockTTL is how long the lock is held for without being extended. It is
+// extended after every batch, so it only has to outlast one batch and the
+// pause after it.
+func (j *janitor) lockTTL() time.Duration {
+	return time.Minute + 2*j.cfg.BatchInterval.Duration
+}
+
+// run makes one pass over the jobs if no other replica is making one
+func (j *janitor) run(ctx context.Context) {
+	conn := j.svc.resultsRedis
+	token := uuid.NewString()
+
+	ok, err := backend.AcquireLock(ctx, conn, retentionLock, token, j.lockTTL())
+	if err != nil {
+		if ctx.Err() == nil {
+			j.svc.log.ErrorContext(ctx, "failed to acquire retention lock", "error", err)
+		}
+		return
+	}
+	if !ok {
+		j.svc.log.DebugContext(ctx, "retention pass left to another replica")
+		j.mu.Lock()
+		j.skipped++
+		j.mu.Unlock()
+		return
+	}
+	defer func() {
+		// Release the lock even when ctx was cancelled mid pass
+		rctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+		if err := backend.ReleaseLock(rctx, conn, retentionLock, token); err != nil {
+			j.svc.log.WarnContext(ctx, "failed to release retention lock", "error", err)
+		}
+	}()
+
+	run := RetentionRun{StartedAt: time.Now(), counts: make(map[retentionKey]int64)}
+	err = j.pass(ctx, token, &run)
+	if err == nil && j.svc.archive != nil && !j.cfg.DryRun {
+		var days []string
+		days, err = j.svc.archive.prune(time.Now())
+		if len(days) > 0 {
+			j.svc.log.InfoContext(ctx, "removed expired archive days", "days", days)
+		}
+	}
+	run.FinishedAt = time.Now()
+	run.Jobs = retentionCounts(run.counts)
+	if err != nil {
+		run.Error = err.Error()
+	}
+
+	j.mu.Lock()
+	j.runs++
+	if err != nil {
+		j.errors++
+	}
+	j.lastRun = &run
+	j.mu.Unlock()
+
+	switch {
+	case err != nil && ctx.Err() != nil:
+		j.svc.log.InfoContext(ctx, "retention pass interrupted", "deleted", run.Deleted)
+	case err != nil:
+		j.svc.log.ErrorContext(ctx, "retention pass failed", "scanned", run.Scanned, "deleted", run.Deleted, "error", err)
+	default:
+		j.svc.log.InfoContext(ctx, "retention pass finished", "scanned", run.Scanned, "deleted", run.Deleted,
+			"dry_run", j.cfg.DryRun, "duration", run.FinishedAt.Sub(run.StartedAt))
+	}
+}
+
+// pass deletes the expired jobs of each status
+func (j *janitor) pass(ctx context.Context, token string, run *RetentionRun) (err error) {
+	ctx, span := startSpan(ctx, "RetentionPass", attribute.Bool("dry_run", j.cfg.DryRun))
+	defer func() {
+		span.SetAttributes(attribute.Int("jobs.scanned", run.Scanned), attribute.Int("jobs.deleted", run.Deleted))
+		endSpan(span, err)
+	}()
+
+	for _, status := range []string{tasqueue.StatusDone, tasqueue.StatusFailed} {
+		if err := j.passStatus(ctx, token, status, run); err != nil {
+			return fmt.Errorf("%s jobs: %w", status, err)
+		}
+	}
+	return nil
+}
+
+// passStatus walks the jobs of a status old enough for at least one of its
+// policies and deletes those whose policy has expired
+func (j *janitor) passStatus(ctx context.Context, token, status string, run *RetentionRun) error {
+	var shortest time.Duration
+	for _, p := range j.cfg.Policies {
+		if p.Status == status && p.MaxAge.Duration > 0 && (shortest == 0 || p.MaxAge.Duration < shortest) {
+			shortest = p.MaxAge.Duration
+		}
+	}
+	if shortest == 0 {
+		return nil
+	}
+
+	conn := j.svc.resultsRedis
+	now := time.Now()
+	max := float64(now.Add(-shortest).UnixNano())
+
+	// The walk continues from the score of the last job read, skipping the
+	// jobs kept with that score, as scores can tie and deleted jobs no
+	// longer take up a position
+	var (
+		min  float64
+		skip int
+	)
+	for {
+		zs, err := backend.StatusBetween(ctx, conn, status, min, max, skip, j.cfg.BatchSize)
+		if err != nil {
+			return fmt.Errorf("failed to read jobs: %w", err)
+		}
+		if len(zs) == 0 {
+			return nil
+		}
+		run.Scanned += len(zs)
+
+		expired, err := j.expired(ctx, status, zs, now)
+		if err != nil {
+			return err
+		}
+		if err := j.delete(ctx, status, expired, run); err != nil {
+			return err
+		}
+		if len(zs) < j.cfg.BatchSize {
+			return nil
+		}
+
+		last := zs[len(zs)-1].Score
+		if last != min {
+			min, skip = last, 0
+		}
+		for i := len(zs) - 1; i >= 0 && zs[i].Score == last; i-- {
+			id, _ := zs[i].Member.(string)
+			if _, ok := expired[id]; j.cfg.DryRun || !ok {
+				skip++
+			}
+		}
+
+		held, err := backend.ExtendLock(ctx, conn, retentionLock, token, j.lockTTL())
+		if err != nil {
+			return fmt.Errorf("failed to extend retention lock: %w", err)
+		}
+		if !held {
+			return fmt.Errorf("lost the retention lock")
+		}
+
+		if len(expired) > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(j.cfg.BatchInterval.Duration):
+			}
+		}
+	}
+}
+
+// expiredJob is a job whose retention policy has expired
+type expiredJob struct {
+	task     string
+	finished time.Time
+	msg      *tasqueue.JobMessage // nil when the message is gone
+}
+
+// expired returns the jobs whose policy has expired by ID. Jobs whose
+// message is gone have no task.
+func (j *janitor) expired(ctx context.Context, status string, zs []redis.Z, now time.Time) (map[string]expiredJob, error) {
+	keys := make([]string, len(zs))
+	for i, z := range zs {
+		id, _ := z.Member.(string)
+		keys[i] = jobMsgKey + id
+	}
+	msgs, err := backend.GetResults(ctx, j.svc.resultsRedis, keys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get jobs: %w", err)
+	}
+
+	out := make(map[string]expiredJob)
+	for i, z := range zs {
+		id, _ := z.Member.(string)
+		job := expiredJob{finished: time.Unix(0, int64(z.Score))}
+		if msgs[i] != nil {
+			var msg tasqueue.JobMessage
+			if err := msgpack.Unmarshal(msgs[i], &msg); err == nil {
+				job.msg = &msg
+				if msg.Job != nil {
+					job.task = msg.Job.Task
+				}
+			}
+		}
+
+		for _, p := range j.cfg.Policies {
+			if !p.Matches(status, job.task) {
+				continue
+			}
+			if p.MaxAge.Duration > 0 && now.Sub(job.finished) > p.MaxAge.Duration {
+				out[id] = job
+			}
+			break
+		}
+	}
+	return out, nil
+}
+
+// delete deletes expired jobs, archiving them first when an archive is
+// configured, or only counts them in a dry run
+func (j *janitor) delete(ctx context.Context, status string, expired map[string]expiredJob, run *RetentionRun) error {
+	if len(expired) == 0 {
+		return nil
+	}
+
+	ids := make([]string, 0, len(expired))
+	for id := range expired {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	if j.cfg.DryRun {
+		j.svc.log.InfoContext(ctx, "would delete expired jobs", "status", status, "jobs", len(ids))
+		j.svc.log.DebugContext(ctx, "would delete expired jobs", "status", status, "ids", ids)
+	} else {
+		if j.svc.archive != nil {
+			if err := j.archive(ctx, status, ids, expired); err != nil {
+				return err
+			}
+		}
+		if err := backend.DeleteJobs(ctx, j.svc.resultsRedis, status, ids); err != nil {
+			return fmt.Errorf("failed to delete jobs: %w", err)
+		}
+		j.svc.log.InfoContext(ctx, "deleted expired jobs", "status", status, "jobs", len(ids))
+		j.svc.log.DebugContext(ctx, "deleted expired jobs", "status", status, "ids", ids)
+	}
+	run.Deleted += len(ids)
+
+	j.mu.Lock()
+	for _, job := range expired {
+		j.deleted[retentionKey{status, job.task}]++
+		run.counts[retentionKey{status, job.task}]++
+	}
+	j.mu.Unlock()
+	return nil
+}
+
+// archive writes expired jobs with their results to the archive, grouped
+// by the day they finished on and their task. Nothing is deleted when it
+// fails, so the jobs are archived again on the next pass.
+func (j *janitor) archive(ctx context.Context, status string, ids []string, expired map[string]expiredJob) error {
+	results, err := backend.GetResults(ctx, j.svc.resultsRedis, ids)
+	if err != nil {
+		return fmt.Errorf("failed to get results: %w", err)
+	}
+
+	type group struct {
+		day  time.Time
+		task string
+	}
+	var (
+		order  []group
+		groups = make(map[group][]JobDetail)
+	)
+	for i, id := range ids {
+		e := expired[id]
+		job := JobDetail{ResultData: results[i]}
+		if e.msg != nil {
+			job.JobMessage = *e.msg
+		} else {
+			job.JobMessage = tasqueue.JobMessage{Meta: tasqueue.Meta{ID: id, Status: status}}
+		}
+
+		g := group{day: e.finished.UTC().Truncate(24 * time.Hour), task: e.task}
+		if _, ok := groups[g]; !ok {
+			order = append(order, g)
+		}
+		groups[g] = append(groups[g], job)
+	}
+
+	for _, g := range order {
+		if err := j.svc.archive.write(g.day, g.task, groups[g]); err != nil {
+			return fmt.Errorf("failed to archive jobs: %w", err)
+		}
+	}
+	j.svc.log.DebugContext(ctx, "archived expired jobs", "status", status, "jobs", len(ids), "files", len(order))
+	return nil
+}
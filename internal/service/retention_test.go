@@ -0,0 +1,287 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/kalbhor/tasqueue/v2"
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/kalbhor/tasqueue-ui/internal/backend"
+	"github.com/kalbhor/tasqueue-ui/internal/config"
+)
+
+// statusKeys are the Redis sets of finished jobs by status
+var statusKeys = map[string]string{
+	tasqueue.StatusDone:   backend.ResultPrefix + "success",
+	tasqueue.StatusFailed: backend.ResultPrefix + "failed",
+}
+
+// testJob is a finished job seeded into the results store. Jobs without a
+// task are seeded without a message.
+type testJob struct {
+	id, status, task string
+	finished         time.Time
+}
+
+// newTestJanitor returns a janitor over an in-memory Redis holding jobs
+func newTestJanitor(t *testing.T, cfg config.RetentionConfig, jobs []testJob) (*janitor, redis.UniversalClient) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	conn := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { conn.Close() })
+
+	ctx := context.Background()
+	for _, j := range jobs {
+		if j.task != "" {
+			msg := tasqueue.JobMessage{
+				Meta: tasqueue.Meta{ID: j.id, Status: j.status},
+				Job:  &tasqueue.Job{Task: j.task},
+			}
+			b, err := msgpack.Marshal(msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := conn.Set(ctx, backend.ResultPrefix+jobMsgKey+j.id, b, 0).Err(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		z := &redis.Z{Score: float64(j.finished.UnixNano()), Member: j.id}
+		if err := conn.ZAdd(ctx, statusKeys[j.status], z).Err(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if cfg.BatchSize == 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.BatchInterval.Duration == 0 {
+		cfg.BatchInterval.Duration = time.Millisecond
+	}
+	svc := &Service{resultsRedis: conn, log: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	return newJanitor(svc, cfg), conn
+}
+
+// remaining returns the IDs left in a status set, sorted
+func remaining(t *testing.T, conn redis.UniversalClient, status string) []string {
+	t.Helper()
+
+	ids, err := conn.ZRange(context.Background(), statusKeys[status], 0, -1).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// runJanitor makes a pass and returns it, failing the test if it failed
+func runJanitor(t *testing.T, j *janitor) RetentionRun {
+	t.Helper()
+
+	// A walk that doesn't move on fails rather than hangs
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	j.run(ctx)
+	run := j.lastRun
+	if run == nil {
+		t.Fatal("the janitor didn't run")
+	}
+	if run.Error != "" {
+		t.Fatalf("retention pass failed: %s", run.Error)
+	}
+	return *run
+}
+
+func maxAge(d time.Duration) config.Duration {
+	return config.Duration{Duration: d}
+}
+
+// TestRetentionTies checks that jobs finished at the same time are each
+// read once when they span batches, whether the ones read are deleted or
+// kept, and that a dry run finds the same jobs a real run deletes
+func TestRetentionTies(t *testing.T) {
+	now := time.Now()
+	var (
+		jobs    []testJob
+		kept    []string
+		deleted int
+	)
+	add := func(id, task string, finished time.Time, expired bool) {
+		jobs = append(jobs, testJob{id: id, status: tasqueue.StatusDone, task: task, finished: finished})
+		if expired {
+			deleted++
+		} else {
+			kept = append(kept, id)
+		}
+	}
+
+	// Ten jobs tie, alternately kept and expired, between jobs finished
+	// earlier and later
+	for i := 0; i < 3; i++ {
+		add(fmt.Sprintf("early-%d", i), "report", now.Add(-time.Duration(5+i)*time.Hour), true)
+	}
+	tie := now.Add(-2 * time.Hour)
+	for i := 0; i < 10; i++ {
+		if i%2 == 0 {
+			add(fmt.Sprintf("tie-%02d", i), "audit", tie, false)
+		} else {
+			add(fmt.Sprintf("tie-%02d", i), "report", tie, true)
+		}
+	}
+	add("late-0", "report", now.Add(-90*time.Minute), true)
+	add("recent-0", "report", now.Add(-10*time.Minute), false)
+	sort.Strings(kept)
+
+	policies := []config.RetentionPolicy{
+		{Status: tasqueue.StatusDone, Tasks: []string{"audit"}},
+		{Status: tasqueue.StatusDone, MaxAge: maxAge(time.Hour)},
+	}
+	scanned := len(jobs) - 1 // Every job but the recent one is old enough to read
+
+	for _, batch := range []int{1, 3, 4, 10, 100} {
+		t.Run(fmt.Sprintf("batch of %d", batch), func(t *testing.T) {
+			cfg := config.RetentionConfig{BatchSize: batch, Policies: policies, DryRun: true}
+			dry, conn := newTestJanitor(t, cfg, jobs)
+
+			dryRun := runJanitor(t, dry)
+			if dryRun.Scanned != scanned || dryRun.Deleted != deleted {
+				t.Errorf("dry run scanned %d and found %d expired, want %d and %d",
+					dryRun.Scanned, dryRun.Deleted, scanned, deleted)
+			}
+			if got := remaining(t, conn, tasqueue.StatusDone); len(got) != len(jobs) {
+				t.Errorf("dry run left %d jobs, want all %d", len(got), len(jobs))
+			}
+
+			// A real run over the same store deletes what the dry run found
+			cfg.DryRun = false
+			j := newJanitor(dry.svc, cfg)
+			run := runJanitor(t, j)
+			if run.Scanned != scanned || run.Deleted != deleted {
+				t.Errorf("scanned %d and deleted %d, want %d and %d", run.Scanned, run.Deleted, scanned, deleted)
+			}
+			if !reflect.DeepEqual(run.Jobs, dryRun.Jobs) {
+				t.Errorf("deleted %v, the dry run found %v", run.Jobs, dryRun.Jobs)
+			}
+			if got := remaining(t, conn, tasqueue.StatusDone); !reflect.DeepEqual(got, kept) {
+				t.Errorf("kept %v, want %v", got, kept)
+			}
+		})
+	}
+}
+
+// TestRetentionFirstMatch checks that a job is kept or deleted by the
+// first policy matching it only
+func TestRetentionFirstMatch(t *testing.T) {
+	old := time.Now().Add(-2 * time.Hour)
+	jobs := []testJob{
+		{id: "billing", status: tasqueue.StatusFailed, task: "billing.charge", finished: old},
+		{id: "email", status: tasqueue.StatusFailed, task: "email.send", finished: old},
+		{id: "gone", status: tasqueue.StatusFailed, finished: old}, // Message deleted, so no task
+		{id: "done", status: tasqueue.StatusDone, task: "billing.charge", finished: old},
+	}
+
+	tests := []struct {
+		name     string
+		policies []config.RetentionPolicy
+		failed   []string // Failed jobs left
+		done     []string // Successful jobs left
+	}{
+		{
+			name: "kept forever by the first policy",
+			policies: []config.RetentionPolicy{
+				{Status: tasqueue.StatusFailed, Tasks: []string{"billing.*"}},
+				{Status: tasqueue.StatusFailed, MaxAge: maxAge(time.Hour)},
+			},
+			failed: []string{"billing"},
+			done:   []string{"done"},
+		},
+		{
+			name: "catch-all first",
+			policies: []config.RetentionPolicy{
+				{Status: tasqueue.StatusFailed, MaxAge: maxAge(time.Hour)},
+				{Status: tasqueue.StatusFailed, Tasks: []string{"billing.*"}},
+			},
+			failed: nil,
+			done:   []string{"done"},
+		},
+		{
+			name: "longer first policy not expired",
+			policies: []config.RetentionPolicy{
+				{Status: tasqueue.StatusFailed, Tasks: []string{"billing.*"}, MaxAge: maxAge(24 * time.Hour)},
+				{Status: tasqueue.StatusFailed, MaxAge: maxAge(time.Hour)},
+			},
+			failed: []string{"billing"},
+			done:   []string{"done"},
+		},
+		{
+			name: "no policy for the status",
+			policies: []config.RetentionPolicy{
+				{Status: tasqueue.StatusDone, Tasks: []string{"email.*"}, MaxAge: maxAge(time.Hour)},
+			},
+			failed: []string{"billing", "email", "gone"},
+			done:   []string{"done"},
+		},
+		{
+			name: "policy per status",
+			policies: []config.RetentionPolicy{
+				{Status: tasqueue.StatusDone, MaxAge: maxAge(time.Hour)},
+				{Status: tasqueue.StatusFailed, Tasks: []string{"email.*"}, MaxAge: maxAge(time.Hour)},
+			},
+			failed: []string{"billing", "gone"},
+			done:   nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j, conn := newTestJanitor(t, config.RetentionConfig{Policies: tt.policies}, jobs)
+			runJanitor(t, j)
+
+			if got := remaining(t, conn, tasqueue.StatusFailed); !equalIDs(got, tt.failed) {
+				t.Errorf("failed jobs left: %v, want %v", got, tt.failed)
+			}
+			if got := remaining(t, conn, tasqueue.StatusDone); !equalIDs(got, tt.done) {
+				t.Errorf("successful jobs left: %v, want %v", got, tt.done)
+			}
+		})
+	}
+}
+
+// TestRetentionDeletesMessages checks that a deleted job's message goes
+// with it
+func TestRetentionDeletesMessages(t *testing.T) {
+	old := time.Now().Add(-2 * time.Hour)
+	j, conn := newTestJanitor(t, config.RetentionConfig{
+		Policies: []config.RetentionPolicy{{Status: tasqueue.StatusFailed, MaxAge: maxAge(time.Hour)}},
+	}, []testJob{{id: "a", status: tasqueue.StatusFailed, task: "email.send", finished: old}})
+
+	run := runJanitor(t, j)
+	want := []RetentionCount{{Status: tasqueue.StatusFailed, Task: "email.send", Jobs: 1}}
+	if !reflect.DeepEqual(run.Jobs, want) {
+		t.Errorf("deleted %v, want %v", run.Jobs, want)
+	}
+	n, err := conn.Exists(context.Background(), backend.ResultPrefix+jobMsgKey+"a").Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Error("the message of a deleted job was kept")
+	}
+}
+
+// equalIDs compares lists of IDs, treating nil and empty as equal
+func equalIDs(a, b []string) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
@@ -0,0 +1,391 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/robfig/cron/v3"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/kalbhor/tasqueue-ui/internal/backend"
+	"github.com/kalbhor/tasqueue-ui/internal/config"
+)
+
+// Silence states
+const (
+	SilenceActive  = "active"
+	SilencePending = "pending" // Not started yet, or between the windows of a schedule
+	SilenceExpired = "expired"
+)
+
+// Sources of the user who created or expired a silence
+const (
+	CreatedByProxy   = "proxy"   // Authenticated by a proxy, from its X-Forwarded-User header
+	CreatedByRequest = "request" // Given in the request body, unverified
+)
+
+// silenceRetention is how long expired silences are kept for reference
+const silenceRetention = 7 * 24 * time.Hour
+
+var (
+	// ErrInvalidSilence is returned when creating a silence that fails validation
+	ErrInvalidSilence = errors.New("invalid silence")
+
+	// ErrSilenceNotFound is returned for unknown silence IDs
+	ErrSilenceNotFound = errors.New("silence not found")
+
+	// ErrProxyUserRequired is returned when creating or expiring a silence
+	// without a user authenticated by a proxy while one is required
+	ErrProxyUserRequired = errors.New("a user authenticated by the proxy is required")
+)
+
+// Silence mutes the alerts and failure notifications it matches while it
+// is active. With a Schedule it is a recurring maintenance window, active
+// for Duration each time the schedule fires between StartsAt and EndsAt.
+type Silence struct {
+	ID string `json:"id"`
+
+	// Rule, Queue and Task are glob patterns matched against the name of an
+	// alert or failure rule, and the queue and task it's about. An empty
+	// pattern matches anything; a set pattern doesn't match notifications
+	// without the field, such as the task of a pending_depth alert.
+	Rule  string `json:"rule,omitempty"`
+	Queue string `json:"queue,omitempty"`
+	Task  string `json:"task,omitempty"`
+
+	StartsAt time.Time `json:"starts_at"`
+	EndsAt   time.Time `json:"ends_at"`
+
+	Schedule string          `json:"schedule,omitempty"` // Cron expression of a maintenance window
+	Duration config.Duration `json:"duration"`           // Length of each maintenance window
+
+	Reason    string    `json:"reason"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// CreatedBySource says where CreatedBy came from: proxy or request. It
+	// is empty for silences created before it was recorded.
+	CreatedBySource string `json:"created_by_source,omitempty"`
+
+	// ExpiredBy is who expired the silence early, and ExpiredBySource where
+	// that came from, as for CreatedBy. ExpiredBy is empty when the request
+	// didn't come through the proxy.
+	ExpiredBy       string `json:"expired_by,omitempty"`
+	ExpiredBySource string `json:"expired_by_source,omitempty"`
+
+	// Status is active, pending or expired as of when the silence was read
+	Status string `json:"status"`
+}
+
+// matches reports whether the silence covers a notification from the named
+// rule about the given queue and task
+func (sl Silence) matches(rule, queue, task string) bool {
+	return silenceMatch(sl.Rule, rule) && silenceMatch(sl.Queue, queue) && silenceMatch(sl.Task, task)
+}
+
+// silenceMatch matches a silence pattern against a value
+func silenceMatch(pattern, v string) bool {
+	if pattern == "" {
+		return true
+	}
+	if v == "" {
+		return false
+	}
+	ok, _ := path.Match(pattern, v)
+	return ok
+}
+
+// state returns the status of the silence at now
+func (sl Silence) state(now time.Time) string {
+	switch {
+	case !now.Before(sl.EndsAt):
+		return SilenceExpired
+	case now.Before(sl.StartsAt):
+		return SilencePending
+	case sl.Schedule == "":
+		return SilenceActive
+	}
+
+	sched, err := cron.ParseStandard(sl.Schedule)
+	if err != nil {
+		return SilencePending
+	}
+	// Active when the schedule fired within the last Duration
+	if next := sched.Next(now.Add(-sl.Duration.Duration)); !next.After(now) {
+		return SilenceActive
+	}
+	return SilencePending
+}
+
+// validate checks a new silence
+func (sl Silence) validate(now time.Time) error {
+	if sl.Rule == "" && sl.Queue == "" && sl.Task == "" {
+		return fmt.Errorf("%w: at least one of rule, queue or task is required", ErrInvalidSilence)
+	}
+	for _, p := range []string{sl.Rule, sl.Queue, sl.Task} {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("%w: invalid pattern: %s", ErrInvalidSilence, p)
+		}
+	}
+	if sl.EndsAt.IsZero() {
+		return fmt.Errorf("%w: ends_at is required", ErrInvalidSilence)
+	}
+	if !sl.EndsAt.After(sl.StartsAt) || !sl.EndsAt.After(now) {
+		return fmt.Errorf("%w: ends_at must be after starts_at and in the future", ErrInvalidSilence)
+	}
+	if sl.Schedule != "" {
+		if _, err := cron.ParseStandard(sl.Schedule); err != nil {
+			return fmt.Errorf("%w: invalid schedule %q: %v", ErrInvalidSilence, sl.Schedule, err)
+		}
+		if sl.Duration.Duration <= 0 {
+			return fmt.Errorf("%w: duration must be positive with a schedule", ErrInvalidSilence)
+		}
+	} else if sl.Duration.Duration != 0 {
+		return fmt.Errorf("%w: duration requires a schedule", ErrInvalidSilence)
+	}
+	if sl.Reason == "" {
+		return fmt.Errorf("%w: reason is required", ErrInvalidSilence)
+	}
+	if sl.CreatedBy == "" {
+		return fmt.Errorf("%w: created_by is required", ErrInvalidSilence)
+	}
+
+	return nil
+}
+
+// silenceStore persists silences
+type silenceStore interface {
+	put(ctx context.Context, sl Silence) error
+	all(ctx context.Context) ([]Silence, error)
+	remove(ctx context.Context, ids ...string) error
+}
+
+// newSilenceStore stores silences in the results store's Redis, or in
+// memory for other results stores
+func newSilenceStore(conn redis.UniversalClient) silenceStore {
+	if conn != nil {
+		return &redisSilences{conn: conn}
+	}
+	return &memorySilences{silences: make(map[string]Silence)}
+}
+
+// redisSilences keeps silences in a Redis hash, so they survive restarts
+// and are shared by every UI instance
+type redisSilences struct {
+	conn redis.UniversalClient
+}
+
+func (r *redisSilences) put(ctx context.Context, sl Silence) error {
+	b, err := json.Marshal(sl)
+	if err != nil {
+		return err
+	}
+	return backend.SetSilence(ctx, r.conn, sl.ID, b)
+}
+
+func (r *redisSilences) all(ctx context.Context) ([]Silence, error) {
+	raw, err := backend.GetSilences(ctx, r.conn)
+	if err != nil {
+		return nil, err
+	}
+
+	silences := make([]Silence, 0, len(raw))
+	for id, v := range raw {
+		var sl Silence
+		if err := json.Unmarshal([]byte(v), &sl); err != nil {
+			return nil, fmt.Errorf("failed to decode silence %s: %w", id, err)
+		}
+		silences = append(silences, sl)
+	}
+	return silences, nil
+}
+
+func (r *redisSilences) remove(ctx context.Context, ids ...string) error {
+	return backend.DeleteSilences(ctx, r.conn, ids...)
+}
+
+// memorySilences keeps silences for the lifetime of the process
+type memorySilences struct {
+	mu       sync.Mutex
+	silences map[string]Silence
+}
+
+func (m *memorySilences) put(_ context.Context, sl Silence) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.silences[sl.ID] = sl
+	return nil
+}
+
+func (m *memorySilences) all(_ context.Context) ([]Silence, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	silences := make([]Silence, 0, len(m.silences))
+	for _, sl := range m.silences {
+		silences = append(silences, sl)
+	}
+	return silences, nil
+}
+
+func (m *memorySilences) remove(_ context.Context, ids ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, id := range ids {
+		delete(m.silences, id)
+	}
+	return nil
+}
+
+// ListSilences returns every silence, newest first. Silences that expired
+// more than a week ago are removed.
+func (s *Service) ListSilences(ctx context.Context) (_ []Silence, err error) {
+	ctx, span := startSpan(ctx, "ListSilences")
+	defer func() { endSpan(span, err) }()
+
+	all, err := s.silences.all(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get silences: %w", err)
+	}
+
+	now := time.Now()
+	silences := make([]Silence, 0, len(all))
+	var old []string
+	for _, sl := range all {
+		if now.Sub(sl.EndsAt) > silenceRetention {
+			old = append(old, sl.ID)
+			continue
+		}
+		sl.Status = sl.state(now)
+		silences = append(silences, sl)
+	}
+	if err := s.silences.remove(ctx, old...); err != nil {
+		s.log.WarnContext(ctx, "failed to remove old silences", "error", err)
+	}
+
+	sort.Slice(silences, func(i, j int) bool {
+		return silences[i].CreatedAt.After(silences[j].CreatedAt)
+	})
+	return silences, nil
+}
+
+// GetSilence returns a single silence
+func (s *Service) GetSilence(ctx context.Context, id string) (_ Silence, err error) {
+	ctx, span := startSpan(ctx, "GetSilence", attribute.String("silence.id", id))
+	defer func() { endSpan(span, err) }()
+
+	all, err := s.silences.all(ctx)
+	if err != nil {
+		return Silence{}, fmt.Errorf("failed to get silences: %w", err)
+	}
+	for _, sl := range all {
+		if sl.ID == id {
+			sl.Status = sl.state(time.Now())
+			return sl, nil
+		}
+	}
+
+	return Silence{}, fmt.Errorf("%w: %s", ErrSilenceNotFound, id)
+}
+
+// CreateSilence validates and stores a new silence. StartsAt defaults to
+// now; the ID and creation time are always set here.
+func (s *Service) CreateSilence(ctx context.Context, sl Silence) (_ Silence, err error) {
+	ctx, span := startSpan(ctx, "CreateSilence")
+	defer func() { endSpan(span, err) }()
+
+	if s.config.Server.RequireProxyUser && sl.CreatedBySource != CreatedByProxy {
+		return Silence{}, ErrProxyUserRequired
+	}
+
+	now := time.Now()
+	if sl.StartsAt.IsZero() {
+		sl.StartsAt = now
+	}
+	if err := sl.validate(now); err != nil {
+		return Silence{}, err
+	}
+
+	sl.ID = randomID()
+	sl.CreatedAt = now
+	sl.Status = ""
+	if err := s.silences.put(ctx, sl); err != nil {
+		return Silence{}, fmt.Errorf("failed to store silence: %w", err)
+	}
+
+	s.log.InfoContext(ctx, "created silence", "id", sl.ID, "rule", sl.Rule, "queue", sl.Queue, "task", sl.Task,
+		"starts_at", sl.StartsAt, "ends_at", sl.EndsAt, "schedule", sl.Schedule, "created_by", sl.CreatedBy, "created_by_source", sl.CreatedBySource, "reason", sl.Reason)
+	sl.Status = sl.state(now)
+	return sl, nil
+}
+
+// ExpireSilence ends a silence now, recording who ended it and where that
+// came from (CreatedByProxy or CreatedByRequest). It is kept, expired, for
+// reference.
+func (s *Service) ExpireSilence(ctx context.Context, id, by, source string) (_ Silence, err error) {
+	ctx, span := startSpan(ctx, "ExpireSilence", attribute.String("silence.id", id))
+	defer func() { endSpan(span, err) }()
+
+	if s.config.Server.RequireProxyUser && source != CreatedByProxy {
+		return Silence{}, ErrProxyUserRequired
+	}
+
+	sl, err := s.GetSilence(ctx, id)
+	if err != nil {
+		return Silence{}, err
+	}
+
+	now := time.Now()
+	if sl.Status != SilenceExpired {
+		sl.EndsAt = now
+		if sl.StartsAt.After(now) {
+			sl.StartsAt = now
+		}
+		sl.ExpiredBy, sl.ExpiredBySource = by, source
+		sl.Status = ""
+		if err := s.silences.put(ctx, sl); err != nil {
+			return Silence{}, fmt.Errorf("failed to store silence: %w", err)
+		}
+		s.log.InfoContext(ctx, "expired silence", "id", sl.ID, "expired_by", sl.ExpiredBy, "expired_by_source", sl.ExpiredBySource)
+	}
+
+	sl.Status = SilenceExpired
+	return sl, nil
+}
+
+// activeSilences returns the silences active now. Errors are logged rather
+// than returned, so a failing store doesn't hold back notifications.
+func (s *Service) activeSilences(ctx context.Context) []Silence {
+	all, err := s.silences.all(ctx)
+	if err != nil {
+		s.log.ErrorContext(ctx, "failed to get silences", "error", err)
+		return nil
+	}
+
+	now := time.Now()
+	var active []Silence
+	for _, sl := range all {
+		if sl.state(now) == SilenceActive {
+			active = append(active, sl)
+		}
+	}
+	return active
+}
+
+// silencedBy returns the IDs of the silences covering a notification
+func silencedBy(silences []Silence, rule, queue, task string) []string {
+	var ids []string
+	for _, sl := range silences {
+		if sl.matches(rule, queue, task) {
+			ids = append(ids, sl.ID)
+		}
+	}
+	return ids
+}
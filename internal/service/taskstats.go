@@ -0,0 +1,390 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kalbhor/tasqueue/v2"
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/kalbhor/tasqueue-ui/internal/backend"
+	"github.com/kalbhor/tasqueue-ui/worker"
+)
+
+// jobMsgKey prefixes job message keys in the results store, relative to
+// backend.ResultPrefix
+const jobMsgKey = "job:msg:"
+
+const (
+	// taskStatsBatch is the number of finished jobs read per round trip
+	taskStatsBatch = 500
+
+	// durationSamples is the number of recent processing times kept per
+	// task for percentiles
+	durationSamples = 1000
+)
+
+// ErrTaskNotFound is returned for tasks no finished job has run
+var ErrTaskNotFound = errors.New("task not found")
+
+// TaskStats summarises the finished jobs of a task
+type TaskStats struct {
+	Name        string  `json:"name"`
+	Successful  int64   `json:"successful"`
+	Failed      int64   `json:"failed"`
+	Retried     int64   `json:"retried"`      // Jobs that needed at least one retry
+	Retries     int64   `json:"retries"`      // Retries across every job
+	RetryRate   float64 `json:"retry_rate"`   // Share of jobs retried at least once
+	FailureRate float64 `json:"failure_rate"` // Share of jobs that failed
+
+	LastSuccess *time.Time   `json:"last_success,omitempty"`
+	LastFailure *TaskFailure `json:"last_failure,omitempty"`
+
+	// Duration summarises processing times; it is only set for tasks whose
+	// workers record them with worker.Timed
+	Duration *DurationStats `json:"duration,omitempty"`
+}
+
+// TaskFailure is the most recent failure of a task
+type TaskFailure struct {
+	JobID string    `json:"job_id"`
+	Queue string    `json:"queue"`
+	Error string    `json:"error"`
+	At    time.Time `json:"at"`
+}
+
+// DurationStats summarises processing times in milliseconds. The average
+// covers every recorded job; percentiles cover the most recent ones.
+type DurationStats struct {
+	Samples int64   `json:"samples"`
+	Avg     float64 `json:"avg_ms"`
+	Min     float64 `json:"min_ms"`
+	Max     float64 `json:"max_ms"`
+	P50     float64 `json:"p50_ms"`
+	P90     float64 `json:"p90_ms"`
+	P95     float64 `json:"p95_ms"`
+	P99     float64 `json:"p99_ms"`
+}
+
+// TaskStatsReport holds the statistics of every task
+type TaskStatsReport struct {
+	Tasks     []TaskStats `json:"tasks"`
+	Jobs      int64       `json:"jobs"`       // Finished jobs counted
+	Complete  bool        `json:"complete"`   // False until the jobs already in the results store are counted
+	UpdatedAt *time.Time  `json:"updated_at"` // End of the last update
+}
+
+// taskAcc accumulates the statistics of a task
+type taskAcc struct {
+	TaskStats
+
+	// Processing times in milliseconds: totals over every job, and a ring
+	// of the most recent for percentiles
+	count     int64
+	sum       float64
+	min, max  float64
+	durations []float64
+	next      int
+}
+
+// addDuration records a processing time
+func (a *taskAcc) addDuration(ms float64) {
+	if a.count == 0 || ms < a.min {
+		a.min = ms
+	}
+	if ms > a.max {
+		a.max = ms
+	}
+	a.count++
+	a.sum += ms
+
+	if len(a.durations) < durationSamples {
+		a.durations = append(a.durations, ms)
+		return
+	}
+	a.durations[a.next] = ms
+	a.next = (a.next + 1) % durationSamples
+}
+
+// stats returns the task's statistics with the rates and durations filled in
+func (a *taskAcc) stats() TaskStats {
+	st := a.TaskStats
+	if total := st.Successful + st.Failed; total > 0 {
+		st.RetryRate = float64(st.Retried) / float64(total)
+		st.FailureRate = float64(st.Failed) / float64(total)
+	}
+	if a.count == 0 {
+		return st
+	}
+
+	sorted := append([]float64(nil), a.durations...)
+	sort.Float64s(sorted)
+	st.Duration = &DurationStats{
+		Samples: a.count,
+		Avg:     a.sum / float64(a.count),
+		Min:     a.min,
+		Max:     a.max,
+		P50:     percentile(sorted, 0.50),
+		P90:     percentile(sorted, 0.90),
+		P95:     percentile(sorted, 0.95),
+		P99:     percentile(sorted, 0.99),
+	}
+	return st
+}
+
+// percentile returns the nearest-rank percentile of sorted values
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return sorted[max(i, 0)]
+}
+
+// taskStats counts finished jobs per task. Each update only reads the jobs
+// finished since the last one: with a Redis results store by walking the
+// success and failed sets from a score cursor, otherwise by diffing the
+// sets against the jobs already counted. Counts are cumulative, so jobs
+// deleted from the results store stay counted.
+type taskStats struct {
+	svc *Service
+
+	// Only touched by update
+	cursors map[string]*statusCursor // By status, for Redis results stores
+	counted map[string]bool          // Job IDs, for other results stores
+
+	mu        sync.Mutex
+	tasks     map[string]*taskAcc
+	jobs      int64
+	complete  bool
+	updatedAt time.Time
+}
+
+// statusCursor is the score of the newest job counted from a status set,
+// and the jobs counted with that score, as scores can tie
+type statusCursor struct {
+	score float64
+	ids   map[string]bool
+}
+
+// newTaskStats creates empty task statistics
+func newTaskStats(svc *Service) *taskStats {
+	return &taskStats{
+		svc: svc,
+		cursors: map[string]*statusCursor{
+			tasqueue.StatusDone:   {ids: make(map[string]bool)},
+			tasqueue.StatusFailed: {ids: make(map[string]bool)},
+		},
+		counted: make(map[string]bool),
+		tasks:   make(map[string]*taskAcc),
+	}
+}
+
+// RunTaskStats counts the jobs already in the results store, then the jobs
+// finished since, every interval until ctx is done
+func (s *Service) RunTaskStats(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		if err := s.stats.update(ctx); err != nil && ctx.Err() == nil {
+			s.log.ErrorContext(ctx, "failed to update task statistics", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// TaskStats returns the statistics of every task, by name
+func (s *Service) TaskStats() TaskStatsReport {
+	st := s.stats
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	report := TaskStatsReport{Tasks: make([]TaskStats, 0, len(st.tasks)), Jobs: st.jobs, Complete: st.complete}
+	if !st.updatedAt.IsZero() {
+		report.UpdatedAt = &st.updatedAt
+	}
+	for _, acc := range st.tasks {
+		report.Tasks = append(report.Tasks, acc.stats())
+	}
+	sort.Slice(report.Tasks, func(i, j int) bool {
+		return report.Tasks[i].Name < report.Tasks[j].Name
+	})
+
+	return report
+}
+
+// GetTaskStats returns the statistics of a single task
+func (s *Service) GetTaskStats(name string) (TaskStats, error) {
+	st := s.stats
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	acc, ok := st.tasks[name]
+	if !ok {
+		return TaskStats{}, fmt.Errorf("%w: %s", ErrTaskNotFound, name)
+	}
+	return acc.stats(), nil
+}
+
+// update counts the jobs finished since the last update
+func (t *taskStats) update(ctx context.Context) (err error) {
+	ctx, span := startSpan(ctx, "UpdateTaskStats")
+	defer func() { endSpan(span, err) }()
+
+	for _, status := range []string{tasqueue.StatusDone, tasqueue.StatusFailed} {
+		if t.svc.resultsRedis != nil {
+			err = t.updateSorted(ctx, status)
+		} else {
+			err = t.updateSet(ctx, status)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	t.mu.Lock()
+	t.complete = true
+	t.updatedAt = time.Now()
+	t.mu.Unlock()
+	return nil
+}
+
+// updateSorted reads a Redis status set from the cursor onwards, oldest
+// first, in batches
+func (t *taskStats) updateSorted(ctx context.Context, status string) error {
+	cur := t.cursors[status]
+	for {
+		zs, err := backend.StatusAfter(ctx, t.svc.resultsRedis, status, cur.score, taskStatsBatch)
+		if err != nil {
+			return fmt.Errorf("failed to read %s jobs: %w", status, err)
+		}
+
+		ids := make([]string, 0, len(zs))
+		at := make([]float64, 0, len(zs))
+		for _, z := range zs {
+			id, _ := z.Member.(string)
+			if z.Score == cur.score && cur.ids[id] {
+				continue
+			}
+			ids, at = append(ids, id), append(at, z.Score)
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		keys := make([]string, 0, 2*len(ids))
+		for _, id := range ids {
+			keys = append(keys, jobMsgKey+id, worker.TimingKey(id))
+		}
+		vals, err := backend.GetResults(ctx, t.svc.resultsRedis, keys)
+		if err != nil {
+			return fmt.Errorf("failed to read job messages: %w", err)
+		}
+
+		t.mu.Lock()
+		for i, id := range ids {
+			if vals[2*i] != nil {
+				var msg tasqueue.JobMessage
+				if err := msgpack.Unmarshal(vals[2*i], &msg); err != nil {
+					t.svc.log.WarnContext(ctx, "skipping undecodable job message", "id", id, "error", err)
+				} else {
+					t.add(status, msg, time.Unix(0, int64(at[i])), vals[2*i+1])
+				}
+			}
+
+			if at[i] != cur.score {
+				cur.score, cur.ids = at[i], make(map[string]bool)
+			}
+			cur.ids[id] = true
+		}
+		t.mu.Unlock()
+
+		if len(zs) < taskStatsBatch {
+			return nil
+		}
+	}
+}
+
+// updateSet diffs a whole status set against the jobs already counted
+func (t *taskStats) updateSet(ctx context.Context, status string) error {
+	var (
+		ids []string
+		err error
+	)
+	if status == tasqueue.StatusDone {
+		ids, err = t.svc.server.GetSuccess(ctx)
+	} else {
+		ids, err = t.svc.server.GetFailed(ctx)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get %s jobs: %w", status, err)
+	}
+
+	for _, id := range ids {
+		if t.counted[id] {
+			continue
+		}
+
+		msg, err := t.svc.server.GetJob(ctx, id)
+		if errors.Is(err, tasqueue.ErrNotFound) {
+			t.counted[id] = true
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get job %s: %w", id, err)
+		}
+		timing, _ := t.svc.server.GetResult(ctx, worker.TimingKey(id))
+
+		t.mu.Lock()
+		t.add(status, msg, msg.ProcessedAt, timing)
+		t.mu.Unlock()
+		t.counted[id] = true
+	}
+
+	return nil
+}
+
+// add counts a finished job. The caller holds t.mu.
+func (t *taskStats) add(status string, msg tasqueue.JobMessage, at time.Time, timing []byte) {
+	if msg.Job == nil || msg.Job.Task == "" {
+		return
+	}
+
+	acc, ok := t.tasks[msg.Job.Task]
+	if !ok {
+		acc = &taskAcc{TaskStats: TaskStats{Name: msg.Job.Task}}
+		t.tasks[msg.Job.Task] = acc
+	}
+	t.jobs++
+
+	if status == tasqueue.StatusDone {
+		acc.Successful++
+		if acc.LastSuccess == nil || at.After(*acc.LastSuccess) {
+			acc.LastSuccess = &at
+		}
+	} else {
+		acc.Failed++
+		if acc.LastFailure == nil || at.After(acc.LastFailure.At) {
+			acc.LastFailure = &TaskFailure{JobID: msg.ID, Queue: msg.Queue, Error: msg.PrevErr, At: at}
+		}
+	}
+	if msg.Retried > 0 {
+		acc.Retried++
+		acc.Retries += int64(msg.Retried)
+	}
+
+	var tm worker.Timing
+	if len(timing) > 0 && json.Unmarshal(timing, &tm) == nil && tm.Duration() >= 0 {
+		acc.addDuration(float64(tm.Duration()) / float64(time.Millisecond))
+	}
+}
@@ -2,24 +2,79 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"sort"
 	"strings"
+	"sync/atomic"
 
+	"github.com/go-redis/redis/v8"
 	"github.com/kalbhor/tasqueue/v2"
-	inmemorybroker "github.com/kalbhor/tasqueue/v2/brokers/in-memory"
-	redisbroker "github.com/kalbhor/tasqueue/v2/brokers/redis"
-	inmemoryresults "github.com/kalbhor/tasqueue/v2/results/in-memory"
-	redisresults "github.com/kalbhor/tasqueue/v2/results/redis"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 
+	"github.com/kalbhor/tasqueue-ui/internal/backend"
 	"github.com/kalbhor/tasqueue-ui/internal/config"
 )
 
+// Key prefixes tasqueue uses for chain and group messages in the results store
+const (
+	chainKeyPrefix = backend.ResultPrefix + "chain:msg:"
+	groupKeyPrefix = backend.ResultPrefix + "group:msg:"
+)
+
 // Service provides access to Tasqueue data
 type Service struct {
-	server *tasqueue.Server
-	broker tasqueue.Broker
-	config config.Config
+	server  *tasqueue.Server
+	broker  tasqueue.Broker
+	results tasqueue.Results
+	config  config.Config
+	log     *slog.Logger
+
+	// Redis clients of the broker and results store, used for operations
+	// tasqueue doesn't expose such as SCAN. They are nil for other backends.
+	brokerRedis  redis.UniversalClient
+	resultsRedis redis.UniversalClient
+
+	// Pingers of the broker and results store; nil for in-process backends
+	brokerPing  pinger
+	resultsPing pinger
+
+	// ready caches the last readiness report
+	ready readinessCache
+
+	// webhooks delivers alerts and failure notifications; nil when no
+	// rules are configured
+	webhooks *webhookSender
+
+	// alerts evaluates alert rules; nil when none are configured
+	alerts *alerter
+
+	// evaluating is set while this replica holds the alerting lock
+	evaluating atomic.Bool
+
+	// failures notifies webhooks of failed jobs; nil when no failure rules
+	// are configured
+	failures *failureWatcher
+
+	// silences holds the silences that mute notifications
+	silences silenceStore
+
+	// stats counts finished jobs per task
+	stats *taskStats
+
+	// janitor deletes expired jobs; nil when no retention policies are
+	// configured
+	janitor *janitor
+
+	// archive holds jobs the janitor deleted; nil when no archive directory
+	// is configured
+	archive *archiver
+
+	// locks are the locks taken without a Redis results store
+	locks localLocks
 }
 
 // DashboardStats holds overview statistics
@@ -40,68 +95,96 @@ type JobDetail struct {
 // ChainDetail extends ChainMessage with job details
 type ChainDetail struct {
 	tasqueue.ChainMessage
-	Jobs []tasqueue.JobMessage `json:"jobs"`
+	Jobs  []tasqueue.JobMessage `json:"jobs"`  // Jobs enqueued so far
+	Steps []ChainStep           `json:"steps"` // Every job of the chain, including those not yet enqueued
+
+	// BlockedAt is the position of the step the chain is waiting on or
+	// failed at, or 0 once every step has succeeded
+	BlockedAt int `json:"blocked_at"`
 }
 
 // GroupDetail extends GroupMessage with job details
 type GroupDetail struct {
 	tasqueue.GroupMessage
 	Jobs []tasqueue.JobMessage `json:"jobs"`
+
+	RerunOf string       `json:"rerun_of,omitempty"` // Group this one re-ran the failed jobs of
+	Reruns  []GroupRerun `json:"reruns,omitempty"`   // Re-runs of this group's failed jobs
+
+	// Combined is the outcome of the group and its re-runs; it is only set
+	// for groups that were re-run
+	Combined *GroupOutcome `json:"combined,omitempty"`
 }
 
 // NewService creates a new Tasqueue service instance
 func NewService(cfg config.Config) (*Service, error) {
-	var (
-		broker  tasqueue.Broker
-		results tasqueue.Results
-		err     error
-	)
-
-	switch cfg.Broker.Type {
-	case "redis":
-		broker = redisbroker.New(redisbroker.Options{
-			Addrs:    []string{cfg.Broker.Redis.Addr},
-			Password: cfg.Broker.Redis.Password,
-			DB:       cfg.Broker.Redis.DB,
-		}, slog.Default())
-
-		results = redisresults.New(redisresults.Options{
-			Addrs:    []string{cfg.Broker.Redis.Addr},
-			Password: cfg.Broker.Redis.Password,
-			DB:       cfg.Broker.Redis.DB,
-		}, slog.Default())
-
-	case "in-memory":
-		broker = inmemorybroker.New()
-		results = inmemoryresults.New()
-
-	// TODO: Add NATS JetStream support
-	// case "nats-js":
-	//     ...
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
 
-	default:
-		return nil, fmt.Errorf("unsupported broker type: %s", cfg.Broker.Type)
+	lo := slog.Default()
+
+	broker, brokerRedis, brokerPing, err := newBroker(cfg.Broker, lo)
+	if err != nil {
+		return nil, err
+	}
+
+	results, resultsRedis, resultsPing, err := newResults(cfg.Results, lo)
+	if err != nil {
+		return nil, err
 	}
 
+	// Let tasqueue emit its own spans when a tracer provider is installed
+	tp, _ := otel.GetTracerProvider().(*sdktrace.TracerProvider)
+
 	// Create server instance (read-only, no task handlers registered)
 	srv, err := tasqueue.NewServer(tasqueue.ServerOpts{
-		Broker:  broker,
-		Results: results,
-		Logger:  slog.Default().Handler(),
+		Broker:        broker,
+		Results:       results,
+		Logger:        lo.Handler(),
+		TraceProvider: tp,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create tasqueue server: %w", err)
 	}
 
-	return &Service{
-		server: srv,
-		broker: broker,
-		config: cfg,
-	}, nil
+	s := &Service{
+		server:       srv,
+		broker:       broker,
+		results:      results,
+		config:       cfg,
+		log:          lo,
+		brokerRedis:  brokerRedis,
+		resultsRedis: resultsRedis,
+		brokerPing:   brokerPing,
+		resultsPing:  resultsPing,
+		silences:     newSilenceStore(resultsRedis),
+	}
+	s.stats = newTaskStats(s)
+	if cfg.Alerting.Enabled() {
+		s.webhooks = newWebhookSender(cfg.Alerting.Webhooks, lo)
+	}
+	if len(cfg.Alerting.Rules) > 0 {
+		s.alerts = newAlerter(s, cfg.Alerting)
+	}
+	if len(cfg.Alerting.Failures) > 0 {
+		s.failures = newFailureWatcher(s, cfg.Alerting)
+	}
+	if cfg.Retention.Enabled() {
+		s.janitor = newJanitor(s, cfg.Retention)
+	}
+	if cfg.Retention.Archive.Dir != "" {
+		s.archive = newArchiver(cfg.Retention.Archive)
+	}
+
+	return s, nil
 }
 
 // GetDashboardStats returns overview statistics
-func (s *Service) GetDashboardStats(ctx context.Context) (DashboardStats, error) {
+func (s *Service) GetDashboardStats(ctx context.Context) (_ DashboardStats, err error) {
+	ctx, span := startSpan(ctx, "GetDashboardStats")
+	defer func() { endSpan(span, err) }()
+
 	stats := DashboardStats{
 		QueueStats: make(map[string]int),
 	}
@@ -125,16 +208,17 @@ func (s *Service) GetDashboardStats(ctx context.Context) (DashboardStats, error)
 	if err == nil {
 		stats.TotalPending = int(pendingCount)
 		stats.QueueStats[tasqueue.DefaultQueue] = int(pendingCount)
+	} else {
+		s.log.WarnContext(ctx, "failed to get pending count", "queue", tasqueue.DefaultQueue, "error", err)
 	}
 
-	// Get registered tasks
-	registeredTasks, err := s.server.GetTasks()
+	// Get the tasks of the task catalog
+	catalog, err := s.TaskCatalog(ctx)
 	if err != nil {
-		return stats, fmt.Errorf("failed to get registered tasks: %w", err)
+		return stats, err
 	}
-	// Extract task names from TaskInfo structs
-	taskNames := make([]string, len(registeredTasks))
-	for i, task := range registeredTasks {
+	taskNames := make([]string, len(catalog))
+	for i, task := range catalog {
 		taskNames[i] = task.Name
 	}
 	stats.RegisteredTasks = taskNames
@@ -143,9 +227,13 @@ func (s *Service) GetDashboardStats(ctx context.Context) (DashboardStats, error)
 }
 
 // GetJob returns a specific job by ID with its result data
-func (s *Service) GetJob(ctx context.Context, id string) (JobDetail, error) {
+func (s *Service) GetJob(ctx context.Context, id string) (_ JobDetail, err error) {
+	ctx, span := startSpan(ctx, "GetJob", attribute.String("job.id", id))
+	defer func() { endSpan(span, err) }()
+
 	job, err := s.server.GetJob(ctx, id)
 	if err != nil {
+		s.log.DebugContext(ctx, "failed to get job", "id", id, "error", err)
 		return JobDetail{}, fmt.Errorf("failed to get job: %w", err)
 	}
 
@@ -157,6 +245,8 @@ func (s *Service) GetJob(ctx context.Context, id string) (JobDetail, error) {
 	resultData, err := s.server.GetResult(ctx, id)
 	if err == nil {
 		detail.ResultData = resultData
+	} else if !errors.Is(err, tasqueue.ErrNotFound) {
+		s.log.WarnContext(ctx, "failed to get job result", "id", id, "error", err)
 	}
 
 	return detail, nil
@@ -172,7 +262,10 @@ type PendingJobsResult struct {
 
 // GetPendingJobs returns pending jobs for a specific queue
 // Deprecated: Use GetPendingJobsWithPagination for better performance
-func (s *Service) GetPendingJobs(ctx context.Context, queue string) ([]tasqueue.JobMessage, error) {
+func (s *Service) GetPendingJobs(ctx context.Context, queue string) (_ []tasqueue.JobMessage, err error) {
+	ctx, span := startSpan(ctx, "GetPendingJobs", attribute.String("queue", queue))
+	defer func() { endSpan(span, err) }()
+
 	if queue == "" {
 		queue = tasqueue.DefaultQueue
 	}
@@ -186,7 +279,10 @@ func (s *Service) GetPendingJobs(ctx context.Context, queue string) ([]tasqueue.
 }
 
 // GetPendingJobsWithPagination returns paginated pending jobs for a specific queue
-func (s *Service) GetPendingJobsWithPagination(ctx context.Context, queue string, offset, limit int) (PendingJobsResult, error) {
+func (s *Service) GetPendingJobsWithPagination(ctx context.Context, queue string, offset, limit int) (_ PendingJobsResult, err error) {
+	ctx, span := startSpan(ctx, "GetPendingJobsWithPagination", attribute.String("queue", queue), attribute.Int("offset", offset), attribute.Int("limit", limit))
+	defer func() { endSpan(span, err) }()
+
 	if queue == "" {
 		queue = tasqueue.DefaultQueue
 	}
@@ -213,7 +309,10 @@ func (s *Service) GetPendingJobsWithPagination(ctx context.Context, queue string
 }
 
 // GetPendingCount returns the count of pending jobs in a specific queue
-func (s *Service) GetPendingCount(ctx context.Context, queue string) (int64, error) {
+func (s *Service) GetPendingCount(ctx context.Context, queue string) (_ int64, err error) {
+	ctx, span := startSpan(ctx, "GetPendingCount", attribute.String("queue", queue))
+	defer func() { endSpan(span, err) }()
+
 	if queue == "" {
 		queue = tasqueue.DefaultQueue
 	}
@@ -227,7 +326,10 @@ func (s *Service) GetPendingCount(ctx context.Context, queue string) (int64, err
 }
 
 // GetJobsByStatus returns jobs filtered by status
-func (s *Service) GetJobsByStatus(ctx context.Context, status string) ([]string, error) {
+func (s *Service) GetJobsByStatus(ctx context.Context, status string) (_ []string, err error) {
+	ctx, span := startSpan(ctx, "GetJobsByStatus", attribute.String("status", status))
+	defer func() { endSpan(span, err) }()
+
 	switch status {
 	case "successful":
 		return s.server.GetSuccess(ctx)
@@ -239,34 +341,39 @@ func (s *Service) GetJobsByStatus(ctx context.Context, status string) ([]string,
 }
 
 // GetChain returns chain details with all job information
-func (s *Service) GetChain(ctx context.Context, id string) (ChainDetail, error) {
+func (s *Service) GetChain(ctx context.Context, id string) (_ ChainDetail, err error) {
+	ctx, span := startSpan(ctx, "GetChain", attribute.String("chain.id", id))
+	defer func() { endSpan(span, err) }()
+
 	chain, err := s.server.GetChain(ctx, id)
 	if err != nil {
 		return ChainDetail{}, fmt.Errorf("failed to get chain: %w", err)
 	}
 
-	detail := ChainDetail{
-		ChainMessage: chain,
-		Jobs:         make([]tasqueue.JobMessage, 0),
+	steps, jobs, err := s.chainSteps(ctx, chain)
+	if err != nil {
+		return ChainDetail{}, fmt.Errorf("failed to get steps of chain %s: %w", id, err)
 	}
 
-	// Fetch all jobs in the chain
-	allJobIDs := append(chain.PrevJobs, chain.JobID)
-	for _, jobID := range allJobIDs {
-		if jobID == "" {
-			continue
-		}
-		job, err := s.server.GetJob(ctx, jobID)
-		if err == nil {
-			detail.Jobs = append(detail.Jobs, job)
-		}
+	// Tasqueue reports a chain whose job was cancelled as still processing
+	at := blockedAt(steps)
+	if at > 0 && steps[at-1].Status == JobCancelled {
+		chain.Status = JobCancelled
 	}
 
-	return detail, nil
+	return ChainDetail{
+		ChainMessage: chain,
+		Jobs:         jobs,
+		Steps:        steps,
+		BlockedAt:    at,
+	}, nil
 }
 
 // GetGroup returns group details with all job information
-func (s *Service) GetGroup(ctx context.Context, id string) (GroupDetail, error) {
+func (s *Service) GetGroup(ctx context.Context, id string) (_ GroupDetail, err error) {
+	ctx, span := startSpan(ctx, "GetGroup", attribute.String("group.id", id))
+	defer func() { endSpan(span, err) }()
+
 	group, err := s.server.GetGroup(ctx, id)
 	if err != nil {
 		return GroupDetail{}, fmt.Errorf("failed to get group: %w", err)
@@ -285,43 +392,114 @@ func (s *Service) GetGroup(ctx context.Context, id string) (GroupDetail, error)
 		}
 	}
 
+	if detail.RerunOf, err = s.groupRerunOf(ctx, id); err != nil {
+		return GroupDetail{}, err
+	}
+	if detail.Reruns, err = s.groupReruns(ctx, id); err != nil {
+		return GroupDetail{}, err
+	}
+	if len(detail.Reruns) > 0 {
+		if detail.Combined, err = s.groupOutcome(ctx, group, detail.Reruns); err != nil {
+			return GroupDetail{}, err
+		}
+	}
+
 	return detail, nil
 }
 
 // ListChains returns all chain IDs from the results store
 // Note: This requires scanning the results store with the chain prefix
-func (s *Service) ListChains(ctx context.Context) ([]string, error) {
-	// This is a placeholder - actual implementation depends on results backend
-	// For Redis, we'd use SCAN with pattern "chain:msg:*"
-	// For now, return empty list
-	return []string{}, nil
+func (s *Service) ListChains(ctx context.Context) (_ []string, err error) {
+	ctx, span := startSpan(ctx, "ListChains")
+	defer func() { endSpan(span, err) }()
+
+	if s.resultsRedis == nil {
+		return []string{}, nil
+	}
+
+	return s.scanIDs(ctx, chainKeyPrefix)
 }
 
 // ListGroups returns all group IDs from the results store
-func (s *Service) ListGroups(ctx context.Context) ([]string, error) {
-	// This is a placeholder - actual implementation depends on results backend
-	// For Redis, we'd use SCAN with pattern "group:msg:*"
-	// For now, return empty list
-	return []string{}, nil
+func (s *Service) ListGroups(ctx context.Context) (_ []string, err error) {
+	ctx, span := startSpan(ctx, "ListGroups")
+	defer func() { endSpan(span, err) }()
+
+	if s.resultsRedis == nil {
+		return []string{}, nil
+	}
+
+	return s.scanIDs(ctx, groupKeyPrefix)
+}
+
+// ListQueues returns the names of all queues: the default queue and the
+// queues of registered tasks, which may currently be empty, plus with a Redis
+// broker the list keys matching the configured queue pattern. Other lists in
+// the same database, such as those of other applications, are not queues.
+func (s *Service) ListQueues(ctx context.Context) (_ []string, err error) {
+	ctx, span := startSpan(ctx, "ListQueues")
+	defer func() { endSpan(span, err) }()
+
+	registeredTasks, err := s.server.GetTasks()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get registered tasks: %w", err)
+	}
+	queues := knownQueues(registeredTasks)
+
+	if pattern := s.config.Broker.QueuePattern; s.brokerRedis != nil && pattern != "" {
+		keys, err := backend.ScanKeys(ctx, s.brokerRedis, pattern, "list")
+		if err != nil {
+			s.log.ErrorContext(ctx, "failed to scan queues", "error", err)
+			return nil, fmt.Errorf("failed to scan queues: %w", err)
+		}
+		queues = append(queues, keys...)
+	}
+
+	return uniqueSorted(queues), nil
 }
 
 // DeleteJob removes a job's metadata from the results store
-func (s *Service) DeleteJob(ctx context.Context, id string) error {
-	return s.server.DeleteJob(ctx, id)
+func (s *Service) DeleteJob(ctx context.Context, id string) (err error) {
+	ctx, span := startSpan(ctx, "DeleteJob", attribute.String("job.id", id))
+	defer func() { endSpan(span, err) }()
+
+	if err := s.server.DeleteJob(ctx, id); err != nil {
+		s.log.ErrorContext(ctx, "failed to delete job", "id", id, "error", err)
+		return err
+	}
+
+	s.log.InfoContext(ctx, "deleted job", "id", id)
+	return nil
 }
 
-// ScanKeys is a helper to scan keys with a prefix (Redis-specific)
+// ScanKeys is a helper to scan keys with a prefix in the results store (Redis-specific)
 // This is used to list all chains/groups
-func (s *Service) ScanKeys(ctx context.Context, pattern string) ([]string, error) {
+func (s *Service) ScanKeys(ctx context.Context, pattern string) (_ []string, err error) {
+	ctx, span := startSpan(ctx, "ScanKeys", attribute.String("pattern", pattern))
+	defer func() { endSpan(span, err) }()
+
 	// Only works with Redis backend
-	if s.config.Broker.Type != "redis" {
-		return nil, fmt.Errorf("scan operation only supported with Redis broker")
+	if s.resultsRedis == nil {
+		return nil, fmt.Errorf("scan operation only supported with Redis results")
 	}
 
-	// Access the Redis client through the broker
-	// This requires type assertion - handle carefully
-	// For now, return empty list as placeholder
-	return []string{}, nil
+	return backend.ScanKeys(ctx, s.resultsRedis, pattern, "")
+}
+
+// scanIDs scans all keys with the given prefix and returns the IDs in them
+func (s *Service) scanIDs(ctx context.Context, prefix string) ([]string, error) {
+	keys, err := s.ScanKeys(ctx, prefix+"*")
+	if err != nil {
+		s.log.ErrorContext(ctx, "failed to scan keys", "prefix", prefix, "error", err)
+		return nil, fmt.Errorf("failed to scan keys: %w", err)
+	}
+
+	ids := make([]string, len(keys))
+	for i, key := range keys {
+		ids[i] = ExtractIDFromKey(key, prefix)
+	}
+
+	return uniqueSorted(ids), nil
 }
 
 // ExtractIDFromKey extracts the ID from a prefixed key
@@ -329,6 +507,33 @@ func ExtractIDFromKey(key, prefix string) string {
 	return strings.TrimPrefix(key, prefix)
 }
 
+// knownQueues returns the default queue plus the queue of every registered task
+func knownQueues(tasks []tasqueue.TaskInfo) []string {
+	queues := []string{tasqueue.DefaultQueue}
+	for _, task := range tasks {
+		if task.Queue != "" {
+			queues = append(queues, task.Queue)
+		}
+	}
+
+	return uniqueSorted(queues)
+}
+
+// uniqueSorted sorts a slice of strings and removes duplicates
+func uniqueSorted(in []string) []string {
+	sort.Strings(in)
+
+	out := in[:0]
+	for i, v := range in {
+		if i > 0 && v == in[i-1] {
+			continue
+		}
+		out = append(out, v)
+	}
+
+	return out
+}
+
 // SearchResult holds the search results for jobs, chains, and groups
 type SearchResult struct {
 	Job   *JobDetail   `json:"job,omitempty"`
@@ -339,18 +544,25 @@ type SearchResult struct {
 
 // Search searches for a job, chain, or group by ID
 // It tries to find the ID in all three types and returns the first match
-func (s *Service) Search(ctx context.Context, id string) (SearchResult, error) {
+func (s *Service) Search(ctx context.Context, id string) (_ SearchResult, err error) {
+	ctx, span := startSpan(ctx, "Search", attribute.String("id", id))
+	defer func() { endSpan(span, err) }()
+
 	result := SearchResult{
 		Type: "not_found",
 	}
 
-	// Try to get as job first
+	// Try to get as job first. Errors other than not found are returned
+	// straight away, so a backend failure isn't reported as no match.
 	job, err := s.GetJob(ctx, id)
 	if err == nil {
 		result.Job = &job
 		result.Type = "job"
 		return result, nil
 	}
+	if !errors.Is(err, tasqueue.ErrNotFound) {
+		return result, err
+	}
 
 	// Try to get as chain
 	chain, err := s.GetChain(ctx, id)
@@ -359,6 +571,9 @@ func (s *Service) Search(ctx context.Context, id string) (SearchResult, error) {
 		result.Type = "chain"
 		return result, nil
 	}
+	if !errors.Is(err, tasqueue.ErrNotFound) {
+		return result, err
+	}
 
 	// Try to get as group
 	group, err := s.GetGroup(ctx, id)
@@ -367,6 +582,10 @@ func (s *Service) Search(ctx context.Context, id string) (SearchResult, error) {
 		result.Type = "group"
 		return result, nil
 	}
+	if !errors.Is(err, tasqueue.ErrNotFound) {
+		return result, err
+	}
 
-	return result, fmt.Errorf("no job, chain, or group found with ID: %s", id)
+	s.log.DebugContext(ctx, "search found no match", "id", id)
+	return result, fmt.Errorf("no job, chain, or group found with ID: %s: %w", id, tasqueue.ErrNotFound)
 }
@@ -0,0 +1,28 @@
+package service
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer creates the spans of service operations. It uses the global
+// tracer provider, so spans are dropped unless tracing is configured.
+var tracer = otel.Tracer("github.com/kalbhor/tasqueue-ui/internal/service")
+
+// startSpan starts a span for the named service operation
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "service."+name, trace.WithAttributes(attrs...))
+}
+
+// endSpan records err, if any, on the span and ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
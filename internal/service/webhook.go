@@ -0,0 +1,207 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/kalbhor/tasqueue-ui/internal/config"
+)
+
+// Headers set on every webhook request
+const (
+	WebhookEventHeader     = "X-Tasqueue-Event"
+	WebhookDeliveryHeader  = "X-Tasqueue-Delivery"
+	WebhookTimestampHeader = "X-Tasqueue-Timestamp"
+	WebhookSignatureHeader = "X-Tasqueue-Signature"
+)
+
+const (
+	// webhookQueueSize is the number of deliveries buffered per webhook
+	webhookQueueSize = 1000
+
+	// maxWebhookBackoff caps the delay between retries
+	maxWebhookBackoff = time.Minute
+)
+
+// SignWebhook returns the signature of a webhook body: the hex HMAC-SHA256,
+// keyed with the webhook's secret, of the timestamp header value, a dot and
+// the body. Signing the timestamp lets receivers reject replayed requests.
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookSender posts JSON events to webhooks in the background. Each
+// webhook has its own queue and goroutine, so a slow or failing endpoint
+// doesn't hold up the others, and events reach each one in order.
+type webhookSender struct {
+	hooks map[string]*webhook
+	names []string // In configuration order
+	log   *slog.Logger
+}
+
+// webhook is a single endpoint and its queue of pending deliveries
+type webhook struct {
+	cfg   config.WebhookConfig
+	hc    *http.Client
+	queue chan delivery
+}
+
+// delivery is an event waiting to be posted
+type delivery struct {
+	id    string
+	event string
+	body  []byte
+}
+
+// newWebhookSender creates a sender for the configured webhooks
+func newWebhookSender(cfgs []config.WebhookConfig, lo *slog.Logger) *webhookSender {
+	w := &webhookSender{
+		hooks: make(map[string]*webhook, len(cfgs)),
+		log:   lo,
+	}
+	for _, cfg := range cfgs {
+		w.hooks[cfg.Name] = &webhook{
+			cfg:   cfg,
+			hc:    &http.Client{Timeout: cfg.Timeout.Duration},
+			queue: make(chan delivery, webhookQueueSize),
+		}
+		w.names = append(w.names, cfg.Name)
+	}
+
+	return w
+}
+
+// run delivers queued events until ctx is done. Undelivered events are
+// dropped on shutdown.
+func (w *webhookSender) run(ctx context.Context) {
+	for _, name := range w.names {
+		go w.hooks[name].run(ctx, w.log.With("webhook", name))
+	}
+	<-ctx.Done()
+}
+
+// send queues an event for the named webhooks, or every webhook when names
+// is empty. It never blocks: events for a webhook whose queue is full are
+// dropped and logged.
+func (w *webhookSender) send(names []string, event string, payload any) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		w.log.Error("failed to encode webhook payload", "event", event, "error", err)
+		return
+	}
+	if len(names) == 0 {
+		names = w.names
+	}
+
+	for _, name := range names {
+		hook, ok := w.hooks[name]
+		if !ok {
+			continue
+		}
+
+		d := delivery{id: randomID(), event: event, body: body}
+		select {
+		case hook.queue <- d:
+		default:
+			w.log.Error("webhook queue full, dropping event", "webhook", name, "event", event, "delivery", d.id)
+		}
+	}
+}
+
+func (h *webhook) run(ctx context.Context, lo *slog.Logger) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case d := <-h.queue:
+			h.deliverWithRetry(ctx, d, lo)
+		}
+	}
+}
+
+// deliverWithRetry posts a delivery until it succeeds, fails permanently or
+// runs out of attempts, backing off exponentially between attempts
+func (h *webhook) deliverWithRetry(ctx context.Context, d delivery, lo *slog.Logger) {
+	backoff := h.cfg.Backoff.Duration
+	for attempt := 1; ; attempt++ {
+		retry, err := h.deliver(ctx, d)
+		if err == nil {
+			lo.Debug("delivered webhook", "event", d.event, "delivery", d.id, "attempt", attempt)
+			return
+		}
+		if !retry || attempt >= h.cfg.MaxAttempts {
+			lo.Error("failed to deliver webhook", "event", d.event, "delivery", d.id, "attempts", attempt, "error", err)
+			return
+		}
+
+		lo.Warn("webhook delivery failed, retrying", "event", d.event, "delivery", d.id,
+			"attempt", attempt, "backoff", backoff, "error", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxWebhookBackoff)
+	}
+}
+
+// deliver makes a single attempt, reporting whether a failure is worth
+// retrying: network errors, timeouts, 429s and server errors are
+func (h *webhook) deliver(ctx context.Context, d delivery) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.cfg.URL, bytes.NewReader(d.body))
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "tasqueue-ui")
+	for k, v := range h.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set(WebhookEventHeader, d.event)
+	req.Header.Set(WebhookDeliveryHeader, d.id)
+	req.Header.Set(WebhookTimestampHeader, ts)
+	if h.cfg.Secret != "" {
+		req.Header.Set(WebhookSignatureHeader, SignWebhook(h.cfg.Secret, ts, d.body))
+	}
+
+	resp, err := h.hc.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode <= 299:
+		return false, nil
+	case resp.StatusCode == http.StatusRequestTimeout, resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode >= 500:
+		return true, fmt.Errorf("webhook returned %s", resp.Status)
+	default:
+		return false, fmt.Errorf("webhook returned %s", resp.Status)
+	}
+}
+
+// randomID returns a random 128-bit ID in hex, used for webhook deliveries
+// and silences
+func randomID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
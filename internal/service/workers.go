@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/kalbhor/tasqueue-ui/internal/backend"
+	"github.com/kalbhor/tasqueue-ui/worker"
+)
+
+// Worker states
+const (
+	WorkerLive    = "live"
+	WorkerStopped = "stopped" // Shut down cleanly
+	WorkerDead    = "dead"    // Stopped sending heartbeats
+)
+
+// workerRetention is how long workers that are no longer live stay listed
+const workerRetention = time.Hour
+
+// Worker is a worker's last heartbeat and its state
+type Worker struct {
+	worker.Info
+	Status string `json:"status"`
+}
+
+// ListWorkers returns the workers publishing heartbeats with the worker
+// package: live ones first, then those that stopped or died within the last
+// hour, most recently seen first. Older workers are removed. It is empty
+// unless the results store is Redis.
+func (s *Service) ListWorkers(ctx context.Context) (_ []Worker, err error) {
+	ctx, span := startSpan(ctx, "ListWorkers")
+	defer func() { endSpan(span, err) }()
+
+	if s.resultsRedis == nil {
+		return []Worker{}, nil
+	}
+
+	raw, live, err := backend.GetWorkers(ctx, s.resultsRedis)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get workers: %w", err)
+	}
+
+	now := time.Now()
+	workers := make([]Worker, 0, len(raw))
+	var old []string
+	for id, v := range raw {
+		var w Worker
+		if err := json.Unmarshal([]byte(v), &w.Info); err != nil {
+			s.log.WarnContext(ctx, "skipping undecodable worker heartbeat", "id", id, "error", err)
+			continue
+		}
+
+		switch {
+		case live[id]:
+			w.Status = WorkerLive
+		case now.Sub(w.SeenAt) > workerRetention:
+			old = append(old, id)
+			continue
+		case w.Stopped:
+			w.Status = WorkerStopped
+		default:
+			w.Status = WorkerDead
+		}
+		workers = append(workers, w)
+	}
+	if err := backend.DeleteWorkers(ctx, s.resultsRedis, old...); err != nil {
+		s.log.WarnContext(ctx, "failed to remove old workers", "error", err)
+	}
+
+	sort.Slice(workers, func(i, j int) bool {
+		if li, lj := workers[i].Status == WorkerLive, workers[j].Status == WorkerLive; li != lj {
+			return li
+		}
+		return workers[i].SeenAt.After(workers[j].SeenAt)
+	})
+	return workers, nil
+}
@@ -0,0 +1,97 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+
+	"github.com/kalbhor/tasqueue-ui/internal/config"
+)
+
+// ServiceName identifies the UI server in exported spans
+const ServiceName = "tasqueue-ui"
+
+// Setup installs a global tracer provider exporting spans as configured.
+// It returns nil and a no-op shutdown function when tracing is disabled.
+// The shutdown function flushes pending spans and must be called on exit.
+func Setup(ctx context.Context, cfg config.TracingConfig, version string) (*sdktrace.TracerProvider, func(context.Context) error, error) {
+	noop := func(context.Context) error { return nil }
+
+	var (
+		exporter sdktrace.SpanExporter
+		closer   io.Closer
+		err      error
+	)
+
+	switch cfg.Exporter {
+	case "", "none":
+		return nil, noop, nil
+
+	case "otlp":
+		var opts []otlptracehttp.Option
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+		}
+		exporter, err = otlptracehttp.New(ctx, opts...)
+		if err != nil {
+			return nil, noop, fmt.Errorf("failed to create OTLP exporter: %w", err)
+		}
+
+	case "stdout":
+		var w io.Writer = os.Stdout
+		if cfg.File != "" {
+			f, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+			if err != nil {
+				return nil, noop, fmt.Errorf("failed to open trace file: %w", err)
+			}
+			w, closer = f, f
+		}
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(w))
+		if err != nil {
+			return nil, noop, fmt.Errorf("failed to create stdout exporter: %w", err)
+		}
+
+	default:
+		return nil, noop, fmt.Errorf("unsupported trace exporter: %s", cfg.Exporter)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		attribute.String("service.name", ServiceName),
+		attribute.String("service.version", version),
+	))
+	if err != nil {
+		return nil, noop, fmt.Errorf("failed to create trace resource: %w", err)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	shutdown := func(ctx context.Context) error {
+		err := tp.Shutdown(ctx)
+		if closer != nil {
+			err = errors.Join(err, closer.Close())
+		}
+		return err
+	}
+
+	return tp, shutdown, nil
+}
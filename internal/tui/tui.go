@@ -0,0 +1,493 @@
+// Package tui implements a terminal dashboard on top of the service layer.
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/kalbhor/tasqueue/v2"
+
+	"github.com/kalbhor/tasqueue-ui/internal/service"
+)
+
+const (
+	// pageSize is the number of pending jobs loaded per page
+	pageSize = 20
+
+	// jobsLimit caps the number of failed or successful jobs listed
+	jobsLimit = 200
+
+	// callTimeout bounds every service call
+	callTimeout = 10 * time.Second
+)
+
+// view is one of the dashboard's tabs
+type view int
+
+const (
+	viewDashboard view = iota
+	viewQueues
+	viewJobs
+	viewLookup
+)
+
+var viewNames = []string{"Dashboard", "Queues", "Jobs", "Lookup"}
+
+// Options configures the terminal UI
+type Options struct {
+	// RefreshInterval is how often the current view is reloaded
+	RefreshInterval time.Duration
+}
+
+// Run starts the terminal UI and blocks until the user quits or ctx is done
+func Run(ctx context.Context, svc *service.Service, opts Options) error {
+	if opts.RefreshInterval <= 0 {
+		opts.RefreshInterval = 3 * time.Second
+	}
+
+	m := &model{
+		ctx:       ctx,
+		svc:       svc,
+		opts:      opts,
+		jobStatus: tasqueue.StatusFailed,
+	}
+
+	_, err := tea.NewProgram(m, tea.WithAltScreen(), tea.WithContext(ctx)).Run()
+	if errors.Is(err, tea.ErrProgramKilled) && ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+// model holds the state of the terminal UI
+type model struct {
+	ctx  context.Context
+	svc  *service.Service
+	opts Options
+
+	view          view
+	width, height int
+
+	stats service.DashboardStats
+
+	queues        []string
+	queueIdx      int
+	pending       service.PendingJobsResult
+	pendingOffset int
+
+	jobStatus string // failed or successful
+	jobs      []tasqueue.JobMessage
+
+	lookupInput   string
+	lookupEditing bool
+	lookup        *service.SearchResult
+
+	// cursor is the selected row of the current view's job list
+	cursor int
+
+	// detail is the job shown in the detail pane, if open
+	detail *service.JobDetail
+
+	// confirm is the action awaiting a y/n answer: "retry", "cancel" or
+	// "delete"
+	confirm string
+
+	message string // Status line message
+	err     error
+	updated time.Time
+}
+
+// Messages delivered by commands
+type (
+	tickMsg    time.Time
+	statsMsg   service.DashboardStats
+	queuesMsg  []string
+	pendingMsg service.PendingJobsResult
+	jobsMsg    []tasqueue.JobMessage
+	jobMsg     service.JobDetail
+	searchMsg  service.SearchResult
+	errMsg     struct{ err error }
+
+	// actionMsg reports the outcome of a retry, cancel or delete
+	actionMsg struct {
+		text string
+		err  error
+	}
+)
+
+func (m *model) Init() tea.Cmd {
+	return tea.Batch(m.loadStats(), m.loadQueues(), m.tick())
+}
+
+func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		return m, nil
+
+	case tea.KeyMsg:
+		return m, m.handleKey(msg)
+
+	case tickMsg:
+		// Don't move the list under the user while they are reading a job
+		if m.detail != nil || m.lookupEditing || m.confirm != "" {
+			return m, m.tick()
+		}
+		return m, tea.Batch(m.reload(), m.tick())
+
+	case statsMsg:
+		m.stats = service.DashboardStats(msg)
+		m.loaded()
+
+	case queuesMsg:
+		m.queues = msg
+		if m.queueIdx >= len(m.queues) {
+			m.queueIdx = 0
+		}
+		m.loaded()
+		if m.view == viewQueues {
+			return m, m.loadPending()
+		}
+
+	case pendingMsg:
+		m.pending = service.PendingJobsResult(msg)
+		m.clampCursor()
+		m.loaded()
+
+	case jobsMsg:
+		m.jobs = msg
+		m.clampCursor()
+		m.loaded()
+
+	case jobMsg:
+		job := service.JobDetail(msg)
+		m.detail = &job
+		m.err = nil
+
+	case searchMsg:
+		res := service.SearchResult(msg)
+		m.lookup = &res
+		m.cursor = 0
+		m.err = nil
+		if res.Job != nil {
+			m.detail = res.Job
+		}
+
+	case actionMsg:
+		m.message, m.err = msg.text, msg.err
+		if msg.err == nil {
+			m.detail = nil
+			return m, m.reload()
+		}
+
+	case errMsg:
+		m.err = msg.err
+	}
+
+	return m, nil
+}
+
+// handleKey processes a key press
+func (m *model) handleKey(k tea.KeyMsg) tea.Cmd {
+	key := k.String()
+	if key == "ctrl+c" {
+		return tea.Quit
+	}
+
+	// A pending confirmation takes every key
+	if m.confirm != "" {
+		action := m.confirm
+		m.confirm = ""
+		if key != "y" || m.detail == nil {
+			m.message = action + " aborted"
+			return nil
+		}
+		switch action {
+		case "retry":
+			return m.retry(m.detail.ID)
+		case "cancel":
+			return m.cancel(m.detail.ID)
+		}
+		return m.delete(m.detail.ID)
+	}
+
+	if m.lookupEditing {
+		switch k.Type {
+		case tea.KeyEnter:
+			m.lookupEditing = false
+			if m.lookupInput != "" {
+				return m.search(m.lookupInput)
+			}
+		case tea.KeyEsc:
+			m.lookupEditing = false
+		case tea.KeyBackspace:
+			if r := []rune(m.lookupInput); len(r) > 0 {
+				m.lookupInput = string(r[:len(r)-1])
+			}
+		case tea.KeyRunes, tea.KeySpace:
+			m.lookupInput += string(k.Runes)
+		}
+		return nil
+	}
+
+	// The detail pane handles actions on the job it shows
+	if m.detail != nil {
+		switch key {
+		case "esc", "backspace", "q":
+			m.detail = nil
+		case "r":
+			if m.detail.Status != tasqueue.StatusFailed {
+				m.message = "only failed jobs can be retried"
+				return nil
+			}
+			m.confirm = "retry"
+		case "c":
+			if m.detail.Status != tasqueue.StatusStarted && m.detail.Status != tasqueue.StatusRetrying {
+				m.message = "only pending jobs can be cancelled"
+				return nil
+			}
+			m.confirm = "cancel"
+		case "d":
+			m.confirm = "delete"
+		}
+		return nil
+	}
+
+	switch key {
+	case "q":
+		return tea.Quit
+	case "tab", "right", "l":
+		return m.switchView((m.view + 1) % view(len(viewNames)))
+	case "shift+tab", "left", "h":
+		return m.switchView((m.view + view(len(viewNames)) - 1) % view(len(viewNames)))
+	case "1", "2", "3", "4":
+		return m.switchView(view(key[0] - '1'))
+	case "R":
+		return m.reload()
+	case "up", "k":
+		if m.cursor > 0 {
+			m.cursor--
+		}
+	case "down", "j":
+		if m.cursor < len(m.rows())-1 {
+			m.cursor++
+		}
+	case "enter":
+		if rows := m.rows(); m.cursor < len(rows) {
+			return m.loadJob(rows[m.cursor].ID)
+		}
+	case "/":
+		m.view = viewLookup
+		m.lookupEditing = true
+		m.lookupInput = ""
+	}
+
+	switch m.view {
+	case viewQueues:
+		switch key {
+		case "[", "]":
+			if len(m.queues) == 0 {
+				return nil
+			}
+			step := 1
+			if key == "[" {
+				step = len(m.queues) - 1
+			}
+			m.queueIdx = (m.queueIdx + step) % len(m.queues)
+			m.pendingOffset, m.cursor = 0, 0
+			return m.loadPending()
+		case "n":
+			if int64(m.pendingOffset+pageSize) < m.pending.Total {
+				m.pendingOffset += pageSize
+				m.cursor = 0
+				return m.loadPending()
+			}
+		case "p":
+			if m.pendingOffset > 0 {
+				m.pendingOffset = max(0, m.pendingOffset-pageSize)
+				m.cursor = 0
+				return m.loadPending()
+			}
+		}
+
+	case viewJobs:
+		switch key {
+		case "f", "s":
+			m.jobStatus = tasqueue.StatusFailed
+			if key == "s" {
+				m.jobStatus = tasqueue.StatusDone
+			}
+			m.cursor = 0
+			return m.loadJobs()
+		}
+
+	case viewLookup:
+		if key == "i" {
+			m.lookupEditing = true
+		}
+	}
+
+	return nil
+}
+
+// switchView changes the current tab and loads its data
+func (m *model) switchView(v view) tea.Cmd {
+	m.view = v
+	m.cursor = 0
+	m.message = ""
+	return m.reload()
+}
+
+// reload reloads the data of the current view
+func (m *model) reload() tea.Cmd {
+	switch m.view {
+	case viewDashboard:
+		return m.loadStats()
+	case viewQueues:
+		return tea.Batch(m.loadQueues(), m.loadPending())
+	case viewJobs:
+		return m.loadJobs()
+	case viewLookup:
+		if m.lookup != nil && m.lookupInput != "" {
+			return m.search(m.lookupInput)
+		}
+	}
+	return nil
+}
+
+// rows returns the selectable jobs of the current view
+func (m *model) rows() []tasqueue.JobMessage {
+	switch m.view {
+	case viewQueues:
+		return m.pending.Jobs
+	case viewJobs:
+		return m.jobs
+	case viewLookup:
+		switch {
+		case m.lookup == nil:
+		case m.lookup.Chain != nil:
+			return m.lookup.Chain.Jobs
+		case m.lookup.Group != nil:
+			return m.lookup.Group.Jobs
+		}
+	}
+	return nil
+}
+
+// clampCursor keeps the cursor within the current list
+func (m *model) clampCursor() {
+	if n := len(m.rows()); m.cursor >= n {
+		m.cursor = max(0, n-1)
+	}
+}
+
+// loaded records a successful refresh
+func (m *model) loaded() {
+	m.err = nil
+	m.updated = time.Now()
+}
+
+// selectedQueue returns the queue shown in the queues view
+func (m *model) selectedQueue() string {
+	if m.queueIdx < len(m.queues) {
+		return m.queues[m.queueIdx]
+	}
+	return tasqueue.DefaultQueue
+}
+
+func (m *model) tick() tea.Cmd {
+	return tea.Tick(m.opts.RefreshInterval, func(t time.Time) tea.Msg {
+		return tickMsg(t)
+	})
+}
+
+// call runs fn with a bounded context, turning errors into errMsg
+func (m *model) call(fn func(ctx context.Context) (tea.Msg, error)) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(m.ctx, callTimeout)
+		defer cancel()
+
+		msg, err := fn(ctx)
+		if err != nil {
+			return errMsg{err}
+		}
+		return msg
+	}
+}
+
+func (m *model) loadStats() tea.Cmd {
+	return m.call(func(ctx context.Context) (tea.Msg, error) {
+		stats, err := m.svc.GetDashboardStats(ctx)
+		return statsMsg(stats), err
+	})
+}
+
+func (m *model) loadQueues() tea.Cmd {
+	return m.call(func(ctx context.Context) (tea.Msg, error) {
+		queues, err := m.svc.ListQueues(ctx)
+		return queuesMsg(queues), err
+	})
+}
+
+func (m *model) loadPending() tea.Cmd {
+	queue, offset := m.selectedQueue(), m.pendingOffset
+	return m.call(func(ctx context.Context) (tea.Msg, error) {
+		res, err := m.svc.GetPendingJobsWithPagination(ctx, queue, offset, pageSize)
+		return pendingMsg(res), err
+	})
+}
+
+func (m *model) loadJobs() tea.Cmd {
+	status := m.jobStatus
+	return m.call(func(ctx context.Context) (tea.Msg, error) {
+		jobs, err := m.svc.ListJobs(ctx, status, service.JobFilter{Limit: jobsLimit})
+		return jobsMsg(jobs), err
+	})
+}
+
+func (m *model) loadJob(id string) tea.Cmd {
+	return m.call(func(ctx context.Context) (tea.Msg, error) {
+		job, err := m.svc.GetJob(ctx, id)
+		return jobMsg(job), err
+	})
+}
+
+func (m *model) search(id string) tea.Cmd {
+	return m.call(func(ctx context.Context) (tea.Msg, error) {
+		res, err := m.svc.Search(ctx, id)
+		return searchMsg(res), err
+	})
+}
+
+func (m *model) retry(id string) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(m.ctx, callTimeout)
+		defer cancel()
+
+		newID, err := m.svc.RetryJob(ctx, id)
+		return actionMsg{text: fmt.Sprintf("retried %s as %s", id, newID), err: err}
+	}
+}
+
+func (m *model) cancel(id string) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(m.ctx, callTimeout)
+		defer cancel()
+
+		err := m.svc.CancelJob(ctx, id)
+		return actionMsg{text: "cancelled " + id, err: err}
+	}
+}
+
+func (m *model) delete(id string) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(m.ctx, callTimeout)
+		defer cancel()
+
+		err := m.svc.DeleteJob(ctx, id)
+		return actionMsg{text: "deleted " + id, err: err}
+	}
+}
@@ -0,0 +1,356 @@
+package tui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/kalbhor/tasqueue/v2"
+
+	"github.com/kalbhor/tasqueue-ui/internal/service"
+)
+
+// Colours match the web dashboard's palette
+var (
+	colorPrimary = lipgloss.Color("#2563eb")
+	colorSuccess = lipgloss.Color("#16a34a")
+	colorDanger  = lipgloss.Color("#dc2626")
+	colorWarning = lipgloss.Color("#ea580c")
+	colorMuted   = lipgloss.Color("#6b7280")
+
+	titleStyle     = lipgloss.NewStyle().Bold(true).Foreground(colorPrimary)
+	tabStyle       = lipgloss.NewStyle().Padding(0, 1).Foreground(colorMuted)
+	activeTabStyle = lipgloss.NewStyle().Padding(0, 1).Bold(true).Foreground(lipgloss.Color("#ffffff")).Background(colorPrimary)
+	headerStyle    = lipgloss.NewStyle().Bold(true)
+	selectedStyle  = lipgloss.NewStyle().Reverse(true)
+	mutedStyle     = lipgloss.NewStyle().Foreground(colorMuted)
+	errorStyle     = lipgloss.NewStyle().Foreground(colorDanger)
+	paneStyle      = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(colorPrimary).Padding(0, 1)
+	cardStyle      = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).Padding(0, 2).Width(18).Align(lipgloss.Center)
+)
+
+// statusStyles colour job statuses as the web dashboard's badges do
+var statusStyles = map[string]lipgloss.Style{
+	tasqueue.StatusStarted:    lipgloss.NewStyle().Foreground(colorPrimary),
+	tasqueue.StatusProcessing: lipgloss.NewStyle().Foreground(colorWarning),
+	tasqueue.StatusDone:       lipgloss.NewStyle().Foreground(colorSuccess),
+	tasqueue.StatusFailed:     lipgloss.NewStyle().Foreground(colorDanger),
+	tasqueue.StatusRetrying:   lipgloss.NewStyle().Foreground(colorWarning),
+}
+
+func (m *model) View() string {
+	if m.width == 0 {
+		return "loading..."
+	}
+
+	header := m.viewHeader()
+	footer := m.viewFooter()
+
+	var body string
+	if m.detail != nil {
+		body = m.viewDetail(*m.detail)
+	} else {
+		// Leave room for the header, footer and list headings
+		rows := m.height - lipgloss.Height(header) - lipgloss.Height(footer) - 4
+		switch m.view {
+		case viewDashboard:
+			body = m.viewDashboard()
+		case viewQueues:
+			body = m.viewQueues(rows)
+		case viewJobs:
+			body = m.viewJobs(rows)
+		case viewLookup:
+			body = m.viewLookup(rows)
+		}
+	}
+
+	gap := m.height - lipgloss.Height(header) - lipgloss.Height(body) - lipgloss.Height(footer)
+	return header + "\n" + body + strings.Repeat("\n", max(1, gap)) + footer
+}
+
+func (m *model) viewHeader() string {
+	tabs := make([]string, len(viewNames))
+	for i, name := range viewNames {
+		label := fmt.Sprintf("%d %s", i+1, name)
+		if view(i) == m.view {
+			tabs[i] = activeTabStyle.Render(label)
+		} else {
+			tabs[i] = tabStyle.Render(label)
+		}
+	}
+
+	updated := ""
+	if !m.updated.IsZero() {
+		updated = mutedStyle.Render("updated " + m.updated.Format("15:04:05"))
+	}
+
+	return titleStyle.Render("Tasqueue") + "  " + strings.Join(tabs, "") + "  " + updated + "\n"
+}
+
+func (m *model) viewFooter() string {
+	var help string
+	switch {
+	case m.confirm != "":
+		help = fmt.Sprintf("%s job %s? y to confirm, any other key to abort", m.confirm, m.detail.ID)
+	case m.lookupEditing:
+		help = "type a job, chain or group ID · enter search · esc cancel"
+	case m.detail != nil:
+		help = "r retry · c cancel · d delete · esc back · ctrl+c quit"
+	default:
+		help = "tab/1-4 switch · ↑↓ select · enter details · / lookup · R refresh · q quit"
+		switch m.view {
+		case viewQueues:
+			help = "[ ] queue · n/p page · " + help
+		case viewJobs:
+			help = "f failed · s successful · " + help
+		case viewLookup:
+			help = "i edit · " + help
+		}
+	}
+
+	line := mutedStyle.Render(help)
+	switch {
+	case m.err != nil:
+		line = errorStyle.Render("error: "+oneLine(m.err.Error())) + "\n" + line
+	case m.message != "":
+		line = m.message + "\n" + line
+	}
+	return line
+}
+
+func (m *model) viewDashboard() string {
+	s := m.stats
+	cards := lipgloss.JoinHorizontal(lipgloss.Top,
+		cardStyle.BorderForeground(colorPrimary).Render(fmt.Sprintf("%d\nPending", s.TotalPending)),
+		cardStyle.BorderForeground(colorSuccess).Render(fmt.Sprintf("%d\nSuccessful", s.TotalSuccess)),
+		cardStyle.BorderForeground(colorDanger).Render(fmt.Sprintf("%d\nFailed", s.TotalFailed)),
+	)
+
+	queues := make([]string, 0, len(s.QueueStats))
+	for q := range s.QueueStats {
+		queues = append(queues, q)
+	}
+	sort.Strings(queues)
+
+	var b strings.Builder
+	b.WriteString(cards + "\n\n")
+	b.WriteString(headerStyle.Render("Queue Statistics") + "\n")
+	for _, q := range queues {
+		fmt.Fprintf(&b, "  %-40s %d\n", q, s.QueueStats[q])
+	}
+	b.WriteString("\n" + headerStyle.Render("Registered Tasks") + "\n")
+	if len(s.RegisteredTasks) == 0 {
+		b.WriteString(mutedStyle.Render("  none") + "\n")
+	}
+	for _, t := range s.RegisteredTasks {
+		b.WriteString("  " + t + "\n")
+	}
+
+	return b.String()
+}
+
+func (m *model) viewQueues(rows int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s  %s\n\n", headerStyle.Render("Queue: "+m.selectedQueue()),
+		mutedStyle.Render(fmt.Sprintf("(%d of %d)", m.queueIdx+1, max(1, len(m.queues)))))
+	b.WriteString(m.viewJobList(m.pending.Jobs, rows))
+
+	p := m.pending
+	first := min(int64(p.Offset+1), p.Total)
+	last := int64(p.Offset) + int64(len(p.Jobs))
+	b.WriteString(mutedStyle.Render(fmt.Sprintf("\nShowing %d-%d of %d pending jobs", first, last, p.Total)))
+
+	return b.String()
+}
+
+func (m *model) viewJobs(rows int) string {
+	title := "Failed Jobs"
+	if m.jobStatus == tasqueue.StatusDone {
+		title = "Successful Jobs"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s  %s\n\n", headerStyle.Render(title),
+		mutedStyle.Render(fmt.Sprintf("(latest %d)", jobsLimit)))
+	b.WriteString(m.viewJobList(m.jobs, rows))
+
+	return b.String()
+}
+
+func (m *model) viewLookup(rows int) string {
+	var b strings.Builder
+
+	input := m.lookupInput
+	if m.lookupEditing {
+		input += "█"
+	}
+	b.WriteString(headerStyle.Render("ID: ") + input + "\n\n")
+
+	switch res := m.lookup; {
+	case res == nil:
+		b.WriteString(mutedStyle.Render("Press / or i to look up a job, chain or group"))
+	case res.Chain != nil:
+		fmt.Fprintf(&b, "Chain %s  %s  %s\n\n", res.Chain.ID, renderStatus(res.Chain.Status), chainProgress(*res.Chain))
+		b.WriteString(m.viewJobList(res.Chain.Jobs, rows-3))
+		if planned := plannedSteps(*res.Chain); planned != "" {
+			b.WriteString(mutedStyle.Render(planned) + "\n")
+		}
+	case res.Group != nil:
+		fmt.Fprintf(&b, "Group %s  %s\n\n", res.Group.ID, renderStatus(res.Group.Status))
+		b.WriteString(m.viewJobList(res.Group.Jobs, rows-2))
+	case res.Job != nil:
+		b.WriteString(mutedStyle.Render("Job found; press enter on it or look up another ID"))
+	}
+
+	return b.String()
+}
+
+// chainProgress describes the step a chain is blocked on
+func chainProgress(c service.ChainDetail) string {
+	if c.BlockedAt == 0 || c.BlockedAt > len(c.Steps) {
+		return fmt.Sprintf("%d of %d steps done", len(c.Steps), len(c.Steps))
+	}
+	st := c.Steps[c.BlockedAt-1]
+	return fmt.Sprintf("blocked on step %d of %d (%s, %s)", st.Position, len(c.Steps), st.Task, st.Status)
+}
+
+// plannedSteps lists the steps of a chain that aren't enqueued yet
+func plannedSteps(c service.ChainDetail) string {
+	var tasks []string
+	status := ""
+	for _, st := range c.Steps {
+		if st.Status == service.JobNotEnqueued || st.Status == service.JobSkipped {
+			tasks, status = append(tasks, st.Task), st.Status
+		}
+	}
+	if len(tasks) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d more steps %s: %s", len(tasks), strings.ReplaceAll(status, "_", " "), strings.Join(tasks, " → "))
+}
+
+// viewJobList renders a table of jobs, scrolled to keep the cursor visible
+func (m *model) viewJobList(jobs []tasqueue.JobMessage, rows int) string {
+	if len(jobs) == 0 {
+		return mutedStyle.Render("No jobs") + "\n"
+	}
+	rows = max(rows, 3)
+
+	start := 0
+	if m.cursor >= rows {
+		start = m.cursor - rows + 1
+	}
+	end := min(len(jobs), start+rows)
+
+	errWidth := max(10, m.width-36-16-12-8-21-10)
+
+	var b strings.Builder
+	b.WriteString(headerStyle.Render(fmt.Sprintf("%-36s  %-16s  %-12s  %-7s  %-19s  %s",
+		"ID", "TASK", "STATUS", "RETRIED", "PROCESSED AT", "ERROR")) + "\n")
+	for i := start; i < end; i++ {
+		job := jobs[i]
+		line := fmt.Sprintf("%-36s  %-16s  %-12s  %-7s  %-19s  %s",
+			job.ID, truncate(jobTask(job), 16), job.Status,
+			fmt.Sprintf("%d/%d", job.Retried, job.MaxRetry),
+			formatTime(job.ProcessedAt), truncate(job.PrevErr, errWidth))
+		if i == m.cursor {
+			line = selectedStyle.Render(line)
+		}
+		b.WriteString(line + "\n")
+	}
+	if len(jobs) > rows {
+		b.WriteString(mutedStyle.Render(fmt.Sprintf("%d/%d", m.cursor+1, len(jobs))) + "\n")
+	}
+
+	return b.String()
+}
+
+// viewDetail renders the job detail pane
+func (m *model) viewDetail(job service.JobDetail) string {
+	var b strings.Builder
+	field := func(name, value string) {
+		fmt.Fprintf(&b, "%s %s\n", headerStyle.Render(fmt.Sprintf("%-13s", name)), value)
+	}
+
+	field("ID", job.ID)
+	field("Task", jobTask(job.JobMessage))
+	field("Status", renderStatus(job.Status))
+	field("Queue", job.Queue)
+	field("Retried", fmt.Sprintf("%d/%d", job.Retried, job.MaxRetry))
+	field("Processed at", formatTime(job.ProcessedAt))
+	if job.Schedule != "" {
+		field("Schedule", job.Schedule)
+	}
+	if len(job.OnSuccessIDs) > 0 {
+		field("On success", strings.Join(job.OnSuccessIDs, ", "))
+	}
+	if job.PrevErr != "" {
+		field("Error", errorStyle.Render(job.PrevErr))
+	}
+	if job.Job != nil {
+		field("Payload", formatBytes(job.Job.Payload))
+	}
+	if len(job.ResultData) > 0 {
+		field("Result", formatBytes(job.ResultData))
+	}
+
+	return paneStyle.Width(max(40, m.width-4)).Render(strings.TrimRight(b.String(), "\n"))
+}
+
+// renderStatus colours a job status
+func renderStatus(status string) string {
+	if s, ok := statusStyles[status]; ok {
+		return s.Render(status)
+	}
+	return status
+}
+
+// jobTask returns the task name of a job message
+func jobTask(job tasqueue.JobMessage) string {
+	if job.Job == nil {
+		return "-"
+	}
+	return job.Job.Task
+}
+
+// formatTime formats a timestamp, or "-" when unset
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
+
+// formatBytes shows printable data as text and anything else as its size
+func formatBytes(b []byte) string {
+	if len(b) == 0 {
+		return "-"
+	}
+
+	s := string(b)
+	if utf8.ValidString(s) && strings.IndexFunc(s, func(r rune) bool {
+		return !unicode.IsPrint(r) && !unicode.IsSpace(r)
+	}) < 0 {
+		return s
+	}
+
+	return fmt.Sprintf("<%d bytes of binary data>", len(b))
+}
+
+// oneLine collapses whitespace so a value fits on one line
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// truncate shortens s to at most n runes, on a single line
+func truncate(s string, n int) string {
+	s = oneLine(s)
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n-1]) + "…"
+}
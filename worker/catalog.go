@@ -0,0 +1,58 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// CatalogKey is the Redis hash holding the task descriptions workers
+// publish, as JSON by task name
+const CatalogKey = "tq:ui:catalog"
+
+// TaskSpec describes a task in the UI's task catalog
+type TaskSpec struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Owner       string `json:"owner,omitempty"` // Team or person responsible for the task
+	Queue       string `json:"queue,omitempty"`
+
+	// Schema is the JSON Schema of the task's payload. The UI rejects jobs
+	// it would enqueue with a payload that doesn't match.
+	Schema json.RawMessage `json:"schema,omitempty"`
+}
+
+// Describe publishes task descriptions to the UI's task catalog, replacing
+// any previously published for the same tasks:
+//
+//	worker.Describe(ctx, rdb, worker.TaskSpec{Name: "resize", Owner: "media", Schema: resizeSchema})
+//
+// The Redis connection must be to the results store the UI reads.
+// Descriptions in the UI's -tasks-config file take precedence.
+func Describe(ctx context.Context, conn redis.UniversalClient, specs ...TaskSpec) error {
+	if len(specs) == 0 {
+		return nil
+	}
+
+	values := make([]interface{}, 0, 2*len(specs))
+	for _, spec := range specs {
+		if spec.Name == "" {
+			return fmt.Errorf("task name cannot be empty")
+		}
+		if len(spec.Schema) > 0 && !json.Valid(spec.Schema) {
+			return fmt.Errorf("task %s: schema is not valid JSON", spec.Name)
+		}
+		b, err := json.Marshal(spec)
+		if err != nil {
+			return fmt.Errorf("failed to encode task %s: %w", spec.Name, err)
+		}
+		values = append(values, spec.Name, b)
+	}
+
+	if err := conn.HSet(ctx, CatalogKey, values...).Err(); err != nil {
+		return fmt.Errorf("failed to publish tasks: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package worker
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"runtime"
+	"sort"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/kalbhor/tasqueue/v2"
+)
+
+const (
+	// WorkersKey is the Redis hash holding the last heartbeat of every
+	// worker, as JSON by ID. It outlives the heartbeats so the UI can list
+	// workers that recently died.
+	WorkersKey = "tq:ui:workers"
+
+	// HeartbeatInterval is how often a worker publishes its heartbeat
+	HeartbeatInterval = 10 * time.Second
+
+	// HeartbeatTTL is how long a heartbeat keeps a worker live
+	HeartbeatTTL = 3 * HeartbeatInterval
+)
+
+// HeartbeatKey returns the key holding a worker's latest heartbeat, which
+// expires HeartbeatTTL after it was published
+func HeartbeatKey(id string) string {
+	return "tq:ui:worker:" + id
+}
+
+// Info is what a worker publishes with each heartbeat
+type Info struct {
+	ID        string              `json:"id"`
+	Hostname  string              `json:"hostname"`
+	PID       int                 `json:"pid"`
+	Tasks     []tasqueue.TaskInfo `json:"tasks"`
+	Queues    []string            `json:"queues"`
+	StartedAt time.Time           `json:"started_at"`
+	SeenAt    time.Time           `json:"seen_at"`
+	Uptime    int64               `json:"uptime_seconds"`
+	Stopped   bool                `json:"stopped,omitempty"` // Set by the last heartbeat of a worker that shut down
+}
+
+// Heartbeat publishes a heartbeat to Redis every HeartbeatInterval until ctx
+// is done, then records the worker as stopped before returning. tasks are
+// the tasks the worker registered, with the options it registered them with:
+//
+//	go worker.Heartbeat(ctx, rdb, map[string]tasqueue.TaskOpts{"resize": opts})
+//
+// The Redis connection must be to the results store the UI reads. A failed
+// heartbeat is retried at the next interval. Workers that exit without
+// waiting for Heartbeat to return are listed as dead rather than stopped.
+func Heartbeat(ctx context.Context, conn redis.UniversalClient, tasks map[string]tasqueue.TaskOpts) {
+	info := newInfo(tasks)
+
+	t := time.NewTicker(HeartbeatInterval)
+	defer t.Stop()
+	for {
+		publish(ctx, conn, info)
+
+		select {
+		case <-ctx.Done():
+			info.Stopped = true
+			stopCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			publish(stopCtx, conn, info)
+			cancel()
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// newInfo describes this process and its tasks, applying tasqueue's defaults
+// to the task options
+func newInfo(tasks map[string]tasqueue.TaskOpts) Info {
+	host, _ := os.Hostname()
+	b := make([]byte, 4)
+	rand.Read(b)
+
+	info := Info{
+		ID:        host + "-" + hex.EncodeToString(b),
+		Hostname:  host,
+		PID:       os.Getpid(),
+		Tasks:     make([]tasqueue.TaskInfo, 0, len(tasks)),
+		StartedAt: time.Now(),
+	}
+
+	queues := make(map[string]bool)
+	for name, opts := range tasks {
+		if opts.Queue == "" {
+			opts.Queue = tasqueue.DefaultQueue
+		}
+		if opts.Concurrency == 0 {
+			opts.Concurrency = uint32(runtime.GOMAXPROCS(0))
+		}
+		info.Tasks = append(info.Tasks, tasqueue.TaskInfo{Name: name, Queue: opts.Queue, Concurrency: opts.Concurrency})
+		queues[opts.Queue] = true
+	}
+	sort.Slice(info.Tasks, func(i, j int) bool {
+		return info.Tasks[i].Name < info.Tasks[j].Name
+	})
+	for q := range queues {
+		info.Queues = append(info.Queues, q)
+	}
+	sort.Strings(info.Queues)
+
+	return info
+}
+
+// publish stores a heartbeat as of now. The heartbeat key of a stopped
+// worker is removed rather than refreshed.
+func publish(ctx context.Context, conn redis.UniversalClient, info Info) {
+	info.SeenAt = time.Now()
+	info.Uptime = int64(info.SeenAt.Sub(info.StartedAt).Seconds())
+	b, _ := json.Marshal(info)
+
+	p := conn.Pipeline()
+	p.HSet(ctx, WorkersKey, info.ID, b)
+	if info.Stopped {
+		p.Del(ctx, HeartbeatKey(info.ID))
+	} else {
+		p.Set(ctx, HeartbeatKey(info.ID), b, HeartbeatTTL)
+	}
+	p.Exec(ctx)
+}
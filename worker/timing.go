@@ -0,0 +1,47 @@
+// Package worker has helpers for Tasqueue workers that report extra
+// information to Tasqueue UI. Workers don't need it to be monitored; it
+// fills in what the job messages tasqueue stores don't record.
+package worker
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/kalbhor/tasqueue/v2"
+)
+
+// Timing records when a job's handler last ran
+type Timing struct {
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+}
+
+// Duration returns how long the handler ran
+func (t Timing) Duration() time.Duration {
+	return t.FinishedAt.Sub(t.StartedAt)
+}
+
+// TimingKey returns the key a job's timing is stored under in the results
+// store, relative to the store's prefix
+func TimingKey(id string) string {
+	return "timing:" + id
+}
+
+// Timed wraps a task handler to save how long each run takes in the results
+// store, so the UI can report processing times per task:
+//
+//	srv.RegisterTask("resize", worker.Timed(results, resize), opts)
+//
+// Failing to save a timing doesn't fail the job. Each retry overwrites the
+// timing of the previous attempt.
+func Timed(results tasqueue.Results, fn func([]byte, tasqueue.JobCtx) error) func([]byte, tasqueue.JobCtx) error {
+	return func(b []byte, c tasqueue.JobCtx) error {
+		start := time.Now()
+		err := fn(b, c)
+
+		t, _ := json.Marshal(Timing{StartedAt: start, FinishedAt: time.Now()})
+		results.Set(c, TimingKey(c.Meta.ID), t)
+
+		return err
+	}
+}